@@ -0,0 +1,95 @@
+// Package arrtest provides a stand-in *arr server for testing api clients and the logic built on them
+package arrtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+const APIKey = "arrtest-api-key"
+
+// Request is a request received by the Server
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+// Server is a httptest server that answers registered routes and records every request,
+// requests without the X-Api-Key header set to APIKey are rejected like the real apps do
+type Server struct {
+	*httptest.Server
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer starts a Server that is closed when the test finishes
+func NewServer(t testing.TB) *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Body:   body,
+	})
+	s.mu.Unlock()
+
+	if r.Header.Get("X-Api-Key") != APIKey {
+		http.Error(w, `{"message":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle registers handler for pattern, see http.ServeMux for the syntax e.g. "GET /api/v3/tag"
+func (s *Server) Handle(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// JSON registers pattern to always answer with status and body encoded as json
+func (s *Server) JSON(pattern string, status int, body any) {
+	s.Handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, status, body)
+	})
+}
+
+// Requests returns a copy of all requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the received requests matching method and path
+func (s *Server) RequestsTo(method, path string) []Request {
+	var res []Request
+	for _, req := range s.Requests() {
+		if req.Method == method && req.Path == path {
+			res = append(res, req)
+		}
+	}
+	return res
+}
+
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
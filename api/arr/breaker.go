@@ -0,0 +1,102 @@
+package arr
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending a request while the instance is considered down
+var ErrCircuitOpen = errors.New("circuit breaker is open, instance is unavailable")
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// Breaker stops requests to an instance after consecutive failures,
+// once OpenDuration has passed a single trial request is let through to probe the instance
+type Breaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func NewBreaker() *Breaker {
+	return &Breaker{
+		FailureThreshold: defaultFailureThreshold,
+		OpenDuration:     defaultOpenDuration,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.OpenDuration {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// a trial request is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Cancel releases the trial request of a half open breaker that was cancelled by the caller,
+// the next request after OpenDuration probes the instance again
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// Open reports whether requests are currently being rejected
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == breakerOpen && b.now().Sub(b.openedAt) < b.OpenDuration
+}
@@ -0,0 +1,171 @@
+// Package arr contains the http plumbing shared by the Sonarr and Radarr api clients
+package arr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"resty.dev/v3"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Client performs authenticated json requests against an *arr api,
+// idempotent requests are retried on transient failures and all requests share a circuit breaker
+type Client struct {
+	http    *resty.Client
+	retry   RetryPolicy
+	breaker *Breaker
+}
+
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		http: resty.New().
+			SetBaseURL(baseURL).
+			SetHeader("X-Api-Key", apiKey).
+			SetHeader("Accept", "application/json").
+			SetTimeout(defaultTimeout).
+			SetDebug(false),
+		retry:   DefaultRetryPolicy,
+		breaker: NewBreaker(),
+	}
+}
+
+func (c *Client) SetRetryPolicy(policy RetryPolicy) *Client {
+	c.retry = policy
+	return c
+}
+
+// Breaker returns the circuit breaker of the instance
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// Healthy reports whether the circuit breaker currently lets requests through
+func (c *Client) Healthy() bool {
+	return !c.breaker.Open()
+}
+
+// Ping checks that the instance is reachable and the api key is valid
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Get(ctx, "/api/v3/system/status", nil, nil)
+}
+
+func (c *Client) Get(ctx context.Context, path string, query url.Values, result any) error {
+	return c.Do(ctx, http.MethodGet, path, query, nil, result)
+}
+
+// Post sends a post request once, use PostIdempotent for requests that are safe to repeat
+func (c *Client) Post(ctx context.Context, path string, body, result any) error {
+	return c.do(ctx, http.MethodPost, path, nil, body, result, false)
+}
+
+// PostIdempotent is Post for requests that are safe to retry e.g. search and rescan commands
+func (c *Client) PostIdempotent(ctx context.Context, path string, body, result any) error {
+	return c.do(ctx, http.MethodPost, path, nil, body, result, true)
+}
+
+func (c *Client) Put(ctx context.Context, path string, body, result any) error {
+	return c.Do(ctx, http.MethodPut, path, nil, body, result)
+}
+
+func (c *Client) Delete(ctx context.Context, path string, query url.Values) error {
+	return c.Do(ctx, http.MethodDelete, path, query, nil, nil)
+}
+
+// Do sends a request and decodes the json response into result if it is not nil,
+// non 2xx responses are returned as *Error. Every method except POST is retried.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	return c.do(ctx, method, path, query, body, result, method != http.MethodPost)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any, idempotent bool) error {
+	attempts := 1
+	if idempotent {
+		attempts = max(c.retry.MaxAttempts, 1)
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if sleepErr := sleep(ctx, c.retry.delay(attempt-1)); sleepErr != nil {
+				return err
+			}
+		}
+
+		if !c.breaker.Allow() {
+			return fmt.Errorf("%s %s: %w", method, path, ErrCircuitOpen)
+		}
+
+		err = c.send(ctx, method, path, query, body, result)
+		if ctx.Err() != nil {
+			// cancelled by the caller, says nothing about the instance
+			c.breaker.Cancel()
+			return err
+		}
+		if !isTransient(err) {
+			c.breaker.Success()
+			return err
+		}
+		c.breaker.Failure()
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	req := c.http.R().SetContext(ctx)
+	if query != nil {
+		req.SetQueryParamsFromValues(query)
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+
+	res, err := req.Execute(method, path)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if res.IsError() {
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: res.StatusCode(),
+			Body:       res.String(),
+		}
+	}
+
+	if result == nil || len(res.Bytes()) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(res.Bytes(), result); err != nil {
+		return fmt.Errorf("%s %s: unable to decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// GetJSON performs a get request and decodes the response as T
+func GetJSON[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	var res T
+	if err := c.Get(ctx, path, query, &res); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// PostJSON performs a post request and decodes the response as T,
+// the request is retried if idempotent is true
+func PostJSON[T any](ctx context.Context, c *Client, path string, body any, idempotent bool) (T, error) {
+	var res T
+	if err := c.do(ctx, http.MethodPost, path, nil, body, &res, idempotent); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package arr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func newTestClient(t *testing.T) (*Client, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	return NewClient(server.URL, arrtest.APIKey).SetRetryPolicy(fastRetry), server
+}
+
+// flaky answers with status for the first n requests and 200 afterwards
+func flaky(n int32, status int) http.HandlerFunc {
+	var calls atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			arrtest.WriteJSON(w, status, map[string]string{"message": "restarting"})
+			return
+		}
+		arrtest.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("PUT /api/v3/episode/monitor", flaky(2, http.StatusServiceUnavailable))
+
+	err := cli.Put(context.Background(), "/api/v3/episode/monitor", map[string]any{}, nil)
+	require.NoError(t, err)
+	assert.Len(t, server.RequestsTo(http.MethodPut, "/api/v3/episode/monitor"), 3)
+}
+
+func TestClient_DoesNotRetryPost(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("POST /api/v3/release", flaky(1, http.StatusBadGateway))
+
+	err := cli.Post(context.Background(), "/api/v3/release", map[string]any{}, nil)
+	assert.Equal(t, http.StatusBadGateway, StatusCode(err))
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/release"), 1)
+
+	err = cli.PostIdempotent(context.Background(), "/api/v3/release", map[string]any{}, nil)
+	assert.NoError(t, err)
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("DELETE /api/v3/episodefile/1", flaky(5, http.StatusNotFound))
+
+	err := cli.Delete(context.Background(), "/api/v3/episodefile/1", nil)
+	assert.True(t, IsNotFound(err))
+	assert.Len(t, server.Requests(), 1)
+	assert.True(t, cli.Healthy())
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/system/status", flaky(8, http.StatusInternalServerError))
+	cli.breaker.OpenDuration = time.Hour
+
+	// two requests with 4 attempts each exhaust the default threshold of 5
+	_ = cli.Get(context.Background(), "/api/v3/system/status", nil, nil)
+	err := cli.Get(context.Background(), "/api/v3/system/status", nil, nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "got %v", err)
+	assert.False(t, cli.Healthy())
+	assert.Len(t, server.Requests(), defaultFailureThreshold)
+
+	// after the open duration a single trial request closes the breaker again
+	now := time.Now()
+	cli.breaker.now = func() time.Time { return now.Add(2 * time.Hour) }
+	server.Handle("GET /api/v3/tag", flaky(0, http.StatusOK))
+	require.NoError(t, cli.Get(context.Background(), "/api/v3/tag", nil, nil))
+	assert.True(t, cli.Healthy())
+}
+
+func TestClient_CircuitBreakerCancelledTrial(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/system/status", flaky(8, http.StatusInternalServerError))
+	cli.breaker.OpenDuration = time.Hour
+
+	_ = cli.Get(context.Background(), "/api/v3/system/status", nil, nil)
+	_ = cli.Get(context.Background(), "/api/v3/system/status", nil, nil)
+	require.False(t, cli.Healthy())
+
+	now := time.Now()
+	cli.breaker.now = func() time.Time { return now.Add(2 * time.Hour) }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, cli.Get(ctx, "/api/v3/tag", nil, nil), context.Canceled)
+
+	// the cancelled trial does not keep the breaker half open
+	server.Handle("GET /api/v3/tag", flaky(0, http.StatusOK))
+	require.NoError(t, cli.Get(context.Background(), "/api/v3/tag", nil, nil))
+	assert.True(t, cli.Healthy())
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt < 10; attempt++ {
+		d := policy.delay(attempt)
+		limit := min(policy.BaseDelay<<(attempt-1), policy.MaxDelay)
+		assert.Positive(t, d)
+		assert.LessOrEqual(t, d, limit)
+	}
+}
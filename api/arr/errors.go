@@ -0,0 +1,41 @@
+package arr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is returned for every response with a non 2xx status code
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Body is the raw response body, *arr apps usually send a json error message
+	Body string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s failed with status code %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+}
+
+// StatusCode returns the status code of err if it is an *Error, 0 otherwise
+func StatusCode(err error) int {
+	var arrErr *Error
+	if errors.As(err, &arrErr) {
+		return arrErr.StatusCode
+	}
+	return 0
+}
+
+// IsClientError reports whether err is a 4xx response that fails the same way when sent again,
+// timeouts and rate limits are not included
+func IsClientError(err error) bool {
+	code := StatusCode(err)
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
@@ -0,0 +1,74 @@
+package arr
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+const defaultPageSize = 50
+
+// Page is a single page of a paged list endpoint like history, queue or blocklist
+type Page[T any] struct {
+	Page          int    `json:"page"`
+	PageSize      int    `json:"pageSize"`
+	SortKey       string `json:"sortKey"`
+	SortDirection string `json:"sortDirection"`
+	TotalRecords  int    `json:"totalRecords"`
+	Records       []T    `json:"records"`
+}
+
+// HasNext reports whether there are records after this page
+func (p *Page[T]) HasNext() bool {
+	return p.Page*p.PageSize < p.TotalRecords
+}
+
+type PageOptions struct {
+	// Page is 1 based, 0 defaults to the first page
+	Page          int
+	PageSize      int
+	SortKey       string
+	SortDirection string
+}
+
+// Values encodes the options as query parameters, extra is merged into the result
+func (p PageOptions) Values(extra url.Values) url.Values {
+	values := url.Values{}
+	for k, v := range extra {
+		values[k] = v
+	}
+
+	page := max(p.Page, 1)
+	pageSize := p.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	values.Set("page", strconv.Itoa(page))
+	values.Set("pageSize", strconv.Itoa(pageSize))
+	if p.SortKey != "" {
+		values.Set("sortKey", p.SortKey)
+	}
+	if p.SortDirection != "" {
+		values.Set("sortDirection", p.SortDirection)
+	}
+	return values
+}
+
+// All fetches every page starting at opts.Page and returns the combined records
+func All[T any](ctx context.Context, opts PageOptions, fetch func(context.Context, PageOptions) (*Page[T], error)) ([]T, error) {
+	opts.Page = max(opts.Page, 1)
+
+	var records []T
+	for {
+		page, err := fetch(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Records...)
+
+		if !page.HasNext() || len(page.Records) == 0 {
+			return records, nil
+		}
+		opts.Page++
+	}
+}
@@ -0,0 +1,72 @@
+package arr
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how often idempotent requests are retried,
+// delays grow exponentially from BaseDelay up to MaxDelay with full jitter
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// NoRetry sends every request exactly once
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// delay returns the wait before the given retry, attempt starts at 1
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransient reports whether err is worth retrying and counts against the breaker,
+// 4xx responses other than 408 and 429 are caused by the request and are returned as is
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var arrErr *Error
+	if errors.As(err, &arrErr) {
+		switch arrErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		default:
+			return arrErr.StatusCode >= 500
+		}
+	}
+
+	// network errors, timeouts and broken responses
+	return true
+}
@@ -0,0 +1,107 @@
+package arr
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Tag struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
+type Language struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Quality struct {
+	Quality struct {
+		ID         int    `json:"id"`
+		Name       string `json:"name"`
+		Source     string `json:"source"`
+		Resolution int    `json:"resolution"`
+	} `json:"quality"`
+}
+
+type CustomFormat struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// MediaInfo is the summary of the streams of a file as analyzed by the *arr app
+type MediaInfo struct {
+	AudioBitrate          int     `json:"audioBitrate"`
+	AudioChannels         float64 `json:"audioChannels"`
+	AudioCodec            string  `json:"audioCodec"`
+	AudioLanguages        string  `json:"audioLanguages"`
+	AudioStreamCount      int     `json:"audioStreamCount"`
+	VideoBitDepth         int     `json:"videoBitDepth"`
+	VideoBitrate          int     `json:"videoBitrate"`
+	VideoCodec            string  `json:"videoCodec"`
+	VideoDynamicRangeType string  `json:"videoDynamicRangeType"`
+	Resolution            string  `json:"resolution"`
+	RunTime               string  `json:"runTime"`
+	ScanType              string  `json:"scanType"`
+	Subtitles             string  `json:"subtitles"`
+}
+
+type RootFolder struct {
+	ID         int    `json:"id"`
+	Path       string `json:"path"`
+	Accessible bool   `json:"accessible"`
+	FreeSpace  int64  `json:"freeSpace"`
+}
+
+type SystemStatus struct {
+	AppName      string    `json:"appName"`
+	InstanceName string    `json:"instanceName"`
+	Version      string    `json:"version"`
+	BuildTime    time.Time `json:"buildTime"`
+	StartTime    time.Time `json:"startTime"`
+	OsName       string    `json:"osName"`
+	IsDocker     bool      `json:"isDocker"`
+	UrlBase      string    `json:"urlBase"`
+}
+
+// CommandRequest starts a command, Body holds the command specific fields e.g. episodeIds
+type CommandRequest struct {
+	Name string
+	Body map[string]any
+}
+
+func (c CommandRequest) MarshalJSON() ([]byte, error) {
+	body := map[string]any{}
+	for k, v := range c.Body {
+		body[k] = v
+	}
+	body["name"] = c.Name
+	return json.Marshal(body)
+}
+
+type Command struct {
+	ID                  int       `json:"id"`
+	Name                string    `json:"name"`
+	CommandName         string    `json:"commandName"`
+	Status              string    `json:"status"`
+	Result              string    `json:"result"`
+	Message             string    `json:"message"`
+	Queued              time.Time `json:"queued"`
+	Started             time.Time `json:"started"`
+	Ended               time.Time `json:"ended"`
+	Trigger             string    `json:"trigger"`
+	StateChangeTime     time.Time `json:"stateChangeTime"`
+	SendUpdatesToClient bool      `json:"sendUpdatesToClient"`
+}
+
+// GrabRequest pushes a release from the release endpoint to the download client
+type GrabRequest struct {
+	GUID      string `json:"guid"`
+	IndexerID int    `json:"indexerId"`
+}
+
+type QueueDeleteOptions struct {
+	RemoveFromClient bool
+	Blocklist        bool
+	SkipRedownload   bool
+}
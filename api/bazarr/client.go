@@ -0,0 +1,155 @@
+// Package bazarr is a minimal client for the Bazarr api
+package bazarr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"resty.dev/v3"
+)
+
+// searches run synchronously in bazarr and query every provider, they easily take a minute
+const defaultTimeout = 3 * time.Minute
+
+// ErrNotFound is returned when bazarr does not know the episode or movie, e.g. it has not synced with the *arr app yet
+var ErrNotFound = errors.New("not found in bazarr")
+
+// Subtitle is a subtitle bazarr knows for a file, embedded subtitles have no path
+type Subtitle struct {
+	Name   string  `json:"name"`
+	Code2  string  `json:"code2"`
+	Code3  string  `json:"code3"`
+	Path   *string `json:"path"`
+	Forced bool    `json:"forced"`
+	HI     bool    `json:"hi"`
+}
+
+type Episode struct {
+	SonarrSeriesID  int        `json:"sonarrSeriesId"`
+	SonarrEpisodeID int        `json:"sonarrEpisodeId"`
+	Subtitles       []Subtitle `json:"subtitles"`
+	Missing         []Subtitle `json:"missing_subtitles"`
+}
+
+type Movie struct {
+	RadarrID  int        `json:"radarrId"`
+	Subtitles []Subtitle `json:"subtitles"`
+	Missing   []Subtitle `json:"missing_subtitles"`
+}
+
+// SearchOptions select the kind of subtitle bazarr searches for, language is an ISO 639-1 code
+type SearchOptions struct {
+	Language string
+	Forced   bool
+	HI       bool
+}
+
+type Client struct {
+	http *resty.Client
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		http: resty.New().
+			SetBaseURL(baseURL).
+			SetHeader("X-API-KEY", apiKey).
+			SetTimeout(defaultTimeout),
+	}
+}
+
+// Episode returns the subtitles bazarr knows for the episode
+func (c *Client) Episode(ctx context.Context, episodeID int) (*Episode, error) {
+	var res struct {
+		Data []Episode `json:"data"`
+	}
+	err := c.get(ctx, "/api/episodes", map[string]string{"episodeid[]": strconv.Itoa(episodeID)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range res.Data {
+		if ep.SonarrEpisodeID == episodeID {
+			return &ep, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Movie returns the subtitles bazarr knows for the movie
+func (c *Client) Movie(ctx context.Context, radarrID int) (*Movie, error) {
+	var res struct {
+		Data []Movie `json:"data"`
+	}
+	err := c.get(ctx, "/api/movies", map[string]string{"radarrid[]": strconv.Itoa(radarrID)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	for _, movie := range res.Data {
+		if movie.RadarrID == radarrID {
+			return &movie, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SearchEpisode searches the providers and downloads the best subtitle for the episode
+func (c *Client) SearchEpisode(ctx context.Context, seriesID, episodeID int, opts SearchOptions) error {
+	return c.search(ctx, "/api/episodes/subtitles", map[string]string{
+		"seriesid":  strconv.Itoa(seriesID),
+		"episodeid": strconv.Itoa(episodeID),
+	}, opts)
+}
+
+// SearchMovie searches the providers and downloads the best subtitle for the movie
+func (c *Client) SearchMovie(ctx context.Context, radarrID int, opts SearchOptions) error {
+	return c.search(ctx, "/api/movies/subtitles", map[string]string{
+		"radarrid": strconv.Itoa(radarrID),
+	}, opts)
+}
+
+func (c *Client) search(ctx context.Context, path string, params map[string]string, opts SearchOptions) error {
+	res, err := c.http.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetQueryParam("language", opts.Language).
+		SetQueryParam("forced", pythonBool(opts.Forced)).
+		SetQueryParam("hi", pythonBool(opts.HI)).
+		Patch(path)
+	if err != nil {
+		return fmt.Errorf("bazarr %s: %w", path, err)
+	}
+	return checkResponse(path, res)
+}
+
+func (c *Client) get(ctx context.Context, path string, params map[string]string, result any) error {
+	res, err := c.http.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetResult(result).
+		Get(path)
+	if err != nil {
+		return fmt.Errorf("bazarr %s: %w", path, err)
+	}
+	return checkResponse(path, res)
+}
+
+func checkResponse(path string, res *resty.Response) error {
+	switch {
+	case res.StatusCode() == http.StatusNotFound:
+		return fmt.Errorf("bazarr %s: %w", path, ErrNotFound)
+	case res.IsError():
+		return fmt.Errorf("bazarr %s failed with status code %d: %s", path, res.StatusCode(), res.String())
+	}
+	return nil
+}
+
+// pythonBool formats a bool the way bazarr parses query flags
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
@@ -0,0 +1,114 @@
+package bazarr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "bazarr-key"
+
+// fakeBazarr is a stand-in for the bazarr api recording the subtitle searches
+type fakeBazarr struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	searches []url.Values
+}
+
+func newFakeBazarr(t *testing.T) *fakeBazarr {
+	f := &fakeBazarr{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/episodes", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("episodeid[]") != "13947" {
+			writeJSON(w, map[string]any{"data": []any{}})
+			return
+		}
+		writeJSON(w, map[string]any{"data": []any{map[string]any{
+			"sonarrSeriesId":  118,
+			"sonarrEpisodeId": 13947,
+			"subtitles": []any{
+				map[string]any{"name": "English", "code2": "en", "code3": "eng", "path": "/tv/Show/ep.en.srt", "forced": false, "hi": false},
+				map[string]any{"name": "Japanese", "code2": "ja", "code3": "jpn", "path": nil, "forced": true, "hi": false},
+			},
+			"missing_subtitles": []any{map[string]any{"name": "Spanish", "code2": "es", "code3": "spa"}},
+		}}})
+	})
+	mux.HandleFunc("GET /api/movies", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"data": []any{}})
+	})
+	search := func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.searches = append(f.searches, r.URL.Query())
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}
+	mux.HandleFunc("PATCH /api/episodes/subtitles", search)
+	mux.HandleFunc("PATCH /api/movies/subtitles", search)
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-KEY") != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestClient_Episode(t *testing.T) {
+	server := newFakeBazarr(t)
+	cli := New(server.URL, testAPIKey)
+
+	ep, err := cli.Episode(context.Background(), 13947)
+	require.NoError(t, err)
+	require.Len(t, ep.Subtitles, 2)
+	assert.Equal(t, "eng", ep.Subtitles[0].Code3)
+	assert.Nil(t, ep.Subtitles[1].Path)
+	assert.True(t, ep.Subtitles[1].Forced)
+	assert.Equal(t, "spa", ep.Missing[0].Code3)
+
+	_, err = cli.Episode(context.Background(), 1)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = cli.Movie(context.Background(), 1)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_Search(t *testing.T) {
+	server := newFakeBazarr(t)
+	cli := New(server.URL, testAPIKey)
+
+	require.NoError(t, cli.SearchEpisode(context.Background(), 118, 13947, SearchOptions{Language: "en"}))
+	require.NoError(t, cli.SearchMovie(context.Background(), 7, SearchOptions{Language: "es", HI: true}))
+
+	require.Len(t, server.searches, 2)
+	assert.Equal(t, url.Values{
+		"seriesid": {"118"}, "episodeid": {"13947"}, "language": {"en"}, "forced": {"False"}, "hi": {"False"},
+	}, server.searches[0])
+	assert.Equal(t, url.Values{
+		"radarrid": {"7"}, "language": {"es"}, "forced": {"False"}, "hi": {"True"},
+	}, server.searches[1])
+}
+
+func TestClient_Unauthorized(t *testing.T) {
+	server := newFakeBazarr(t)
+	cli := New(server.URL, "wrong")
+
+	err := cli.SearchEpisode(context.Background(), 118, 13947, SearchOptions{Language: "en"})
+	assert.ErrorContains(t, err, "status code 401")
+}
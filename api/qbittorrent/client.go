@@ -0,0 +1,162 @@
+// Package qbittorrent is a minimal client for the qBittorrent Web API v2
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"resty.dev/v3"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// ErrNotFound is returned when qBittorrent does not know the torrent
+var ErrNotFound = errors.New("torrent not found")
+
+// File is a single file of a torrent, Name is relative to the torrent save path
+type File struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	Size     int64   `json:"size"`
+	Progress float64 `json:"progress"`
+	Priority int     `json:"priority"`
+}
+
+type Torrent struct {
+	Hash     string  `json:"hash"`
+	Name     string  `json:"name"`
+	State    string  `json:"state"`
+	Progress float64 `json:"progress"`
+	Size     int64   `json:"size"`
+	Category string  `json:"category"`
+}
+
+// Client logs in on the first request and again whenever the session expires
+type Client struct {
+	http     *resty.Client
+	username string
+	password string
+
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+func New(baseURL, username, password string) *Client {
+	return &Client{
+		http: resty.New().
+			SetBaseURL(baseURL).
+			// qBittorrent rejects requests whose Referer does not match its host when CSRF protection is on
+			SetHeader("Referer", strings.TrimSuffix(baseURL, "/")).
+			SetTimeout(defaultTimeout),
+		username: username,
+		password: password,
+	}
+}
+
+// Login starts a session, the session cookie is kept by the client
+func (c *Client) Login(ctx context.Context) error {
+	res, err := c.http.R().
+		SetContext(ctx).
+		SetFormData(map[string]string{"username": c.username, "password": c.password}).
+		Post("/api/v2/auth/login")
+	if err != nil {
+		return fmt.Errorf("qbittorrent login: %w", err)
+	}
+	if res.IsError() || strings.TrimSpace(res.String()) != "Ok." {
+		return fmt.Errorf("qbittorrent login failed with status code %d: %s", res.StatusCode(), res.String())
+	}
+
+	c.mu.Lock()
+	c.loggedIn = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Files lists the files of the torrent, the list is empty until qBittorrent has the torrent metadata
+func (c *Client) Files(ctx context.Context, hash string) ([]File, error) {
+	var files []File
+	err := c.do(ctx, http.MethodGet, "/api/v2/torrents/files", map[string]string{"hash": normalizeHash(hash)}, &files)
+	return files, err
+}
+
+// Torrent returns the torrent with the hash
+func (c *Client) Torrent(ctx context.Context, hash string) (*Torrent, error) {
+	var torrents []Torrent
+	err := c.do(ctx, http.MethodGet, "/api/v2/torrents/info", map[string]string{"hashes": normalizeHash(hash)}, &torrents)
+	if err != nil {
+		return nil, err
+	}
+	if len(torrents) == 0 {
+		return nil, ErrNotFound
+	}
+	return &torrents[0], nil
+}
+
+// Delete removes the torrent, along with the downloaded data if deleteFiles is set
+func (c *Client) Delete(ctx context.Context, hash string, deleteFiles bool) error {
+	form := map[string]string{
+		"hashes":      normalizeHash(hash),
+		"deleteFiles": fmt.Sprint(deleteFiles),
+	}
+	return c.do(ctx, http.MethodPost, "/api/v2/torrents/delete", form, nil)
+}
+
+// do sends the request, logging in first if needed and once more if the session expired.
+// params are sent as the query for GET and as a form otherwise
+func (c *Client) do(ctx context.Context, method, path string, params map[string]string, result any) error {
+	c.mu.Lock()
+	loggedIn := c.loggedIn
+	c.mu.Unlock()
+	if !loggedIn {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	res, err := c.send(ctx, method, path, params)
+	if err == nil && res.StatusCode() == http.StatusForbidden {
+		if err = c.Login(ctx); err != nil {
+			return err
+		}
+		res, err = c.send(ctx, method, path, params)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	switch {
+	case res.StatusCode() == http.StatusNotFound:
+		return fmt.Errorf("%s %s: %w", method, path, ErrNotFound)
+	case res.IsError():
+		return fmt.Errorf("%s %s failed with status code %d: %s", method, path, res.StatusCode(), res.String())
+	}
+
+	if result == nil || len(res.Bytes()) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(res.Bytes(), result); err != nil {
+		return fmt.Errorf("%s %s: unable to decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, params map[string]string) (*resty.Response, error) {
+	req := c.http.R().SetContext(ctx)
+	if method == http.MethodGet {
+		req.SetQueryParams(params)
+	} else {
+		req.SetFormData(params)
+	}
+	return req.Execute(method, path)
+}
+
+// normalizeHash lowercases the hash, the *arr apps report torrent hashes in uppercase
+func normalizeHash(hash string) string {
+	return strings.ToLower(hash)
+}
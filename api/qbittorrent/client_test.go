@@ -0,0 +1,153 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUser     = "admin"
+	testPassword = "adminadmin"
+	testHash     = "9f3b1c7a2e4d6f8091a3b5c7d9e1f3a5b7c9d1e3"
+)
+
+// fakeQbittorrent is a stand-in for the qBittorrent Web API that tracks sessions like the real one
+type fakeQbittorrent struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	logins   int
+	files    map[string][]File
+	deleted  []string
+}
+
+func newFakeQbittorrent(t *testing.T) *fakeQbittorrent {
+	f := &fakeQbittorrent{
+		sessions: map[string]bool{},
+		files: map[string][]File{
+			testHash: {
+				{Index: 0, Name: "Show.S01E01.GERMAN.1080p.WEB.h264-GROUP/Show.S01E01.GERMAN.1080p.WEB.h264-GROUP.mkv", Size: 1 << 30},
+				{Index: 1, Name: "Show.S01E01.GERMAN.1080p.WEB.h264-GROUP/group.nfo", Size: 1 << 10},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.logins++
+		if r.FormValue("username") != testUser || r.FormValue("password") != testPassword {
+			_, _ = w.Write([]byte("Fails."))
+			return
+		}
+		sid := fmt.Sprintf("sid%d", f.logins)
+		f.sessions[sid] = true
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: sid, Path: "/"})
+		_, _ = w.Write([]byte("Ok."))
+	})
+	mux.HandleFunc("GET /api/v2/torrents/files", f.authed(func(w http.ResponseWriter, r *http.Request) {
+		files, ok := f.files[r.URL.Query().Get("hash")]
+		if !ok {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(files)
+	}))
+	mux.HandleFunc("GET /api/v2/torrents/info", f.authed(func(w http.ResponseWriter, r *http.Request) {
+		var torrents []Torrent
+		if _, ok := f.files[r.URL.Query().Get("hashes")]; ok {
+			torrents = append(torrents, Torrent{Hash: r.URL.Query().Get("hashes"), State: "downloading", Progress: 0.1})
+		}
+		_ = json.NewEncoder(w).Encode(torrents)
+	}))
+	mux.HandleFunc("POST /api/v2/torrents/delete", f.authed(func(w http.ResponseWriter, r *http.Request) {
+		f.deleted = append(f.deleted, r.FormValue("hashes")+":"+r.FormValue("deleteFiles"))
+	}))
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeQbittorrent) authed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		cookie, err := r.Cookie("SID")
+		if err != nil || !f.sessions[cookie.Value] {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// expireSessions logs every client out like a qBittorrent restart does
+func (f *fakeQbittorrent) expireSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = map[string]bool{}
+}
+
+func TestClient_Files(t *testing.T) {
+	server := newFakeQbittorrent(t)
+	cli := New(server.URL, testUser, testPassword)
+
+	files, err := cli.Files(context.Background(), "9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Contains(t, files[0].Name, "GERMAN")
+	assert.Equal(t, 1, server.logins)
+}
+
+func TestClient_RelogsAfterSessionExpired(t *testing.T) {
+	server := newFakeQbittorrent(t)
+	cli := New(server.URL, testUser, testPassword)
+
+	_, err := cli.Torrent(context.Background(), testHash)
+	require.NoError(t, err)
+	server.expireSessions()
+
+	torrent, err := cli.Torrent(context.Background(), testHash)
+	require.NoError(t, err)
+	assert.Equal(t, "downloading", torrent.State)
+	assert.Equal(t, 2, server.logins)
+}
+
+func TestClient_NotFound(t *testing.T) {
+	server := newFakeQbittorrent(t)
+	cli := New(server.URL, testUser, testPassword)
+
+	_, err := cli.Files(context.Background(), "unknown")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = cli.Torrent(context.Background(), "unknown")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_Delete(t *testing.T) {
+	server := newFakeQbittorrent(t)
+	cli := New(server.URL, testUser, testPassword)
+
+	require.NoError(t, cli.Delete(context.Background(), testHash, true))
+	assert.Equal(t, []string{testHash + ":true"}, server.deleted)
+}
+
+func TestClient_InvalidCredentials(t *testing.T) {
+	server := newFakeQbittorrent(t)
+	cli := New(server.URL, testUser, "wrong")
+
+	_, err := cli.Files(context.Background(), testHash)
+	assert.ErrorContains(t, err, "login failed")
+}
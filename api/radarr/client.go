@@ -0,0 +1,152 @@
+// Package radarr is a typed client for the Radarr v3 api
+package radarr
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/RA341/warden/api/arr"
+)
+
+type Client struct {
+	*arr.Client
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{Client: arr.NewClient(baseURL, apiKey)}
+}
+
+func (c *Client) GetMovies(ctx context.Context) ([]Movie, error) {
+	return arr.GetJSON[[]Movie](ctx, c.Client, "/api/v3/movie", nil)
+}
+
+func (c *Client) GetMovie(ctx context.Context, id int) (*Movie, error) {
+	return arr.GetJSON[*Movie](ctx, c.Client, "/api/v3/movie/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) MonitorMovies(ctx context.Context, movieIDs []int, monitored bool) error {
+	body := map[string]any{
+		"movieIds":  movieIDs,
+		"monitored": monitored,
+	}
+	return c.Put(ctx, "/api/v3/movie/editor", body, nil)
+}
+
+// AddTags adds the tags to the movies, their other tags are kept
+func (c *Client) AddTags(ctx context.Context, movieIDs []int, tagIDs []int) error {
+	body := map[string]any{
+		"movieIds":  movieIDs,
+		"tags":      tagIDs,
+		"applyTags": "add",
+	}
+	return c.Put(ctx, "/api/v3/movie/editor", body, nil)
+}
+
+func (c *Client) GetMovieFile(ctx context.Context, id int) (*MovieFile, error) {
+	return arr.GetJSON[*MovieFile](ctx, c.Client, "/api/v3/moviefile/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetMovieFiles(ctx context.Context, movieID int) ([]MovieFile, error) {
+	query := url.Values{"movieId": {strconv.Itoa(movieID)}}
+	return arr.GetJSON[[]MovieFile](ctx, c.Client, "/api/v3/moviefile", query)
+}
+
+func (c *Client) DeleteMovieFile(ctx context.Context, id int) error {
+	return c.Delete(ctx, "/api/v3/moviefile/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetHistory(ctx context.Context, opts HistoryOptions) (*arr.Page[HistoryRecord], error) {
+	query := url.Values{}
+	if opts.MovieID != 0 {
+		query.Set("movieIds", strconv.Itoa(opts.MovieID))
+	}
+	if opts.EventType != "" {
+		query.Set("eventType", opts.EventType)
+	}
+
+	return arr.GetJSON[*arr.Page[HistoryRecord]](ctx, c.Client, "/api/v3/history", opts.Values(query))
+}
+
+// MarkHistoryFailed marks a grab as failed, radarr blocklists the release and searches again if redownloading failed downloads is enabled
+func (c *Client) MarkHistoryFailed(ctx context.Context, id int) error {
+	return c.Post(ctx, "/api/v3/history/failed/"+strconv.Itoa(id), nil, nil)
+}
+
+func (c *Client) GetQueue(ctx context.Context, opts arr.PageOptions) (*arr.Page[QueueRecord], error) {
+	return arr.GetJSON[*arr.Page[QueueRecord]](ctx, c.Client, "/api/v3/queue", opts.Values(nil))
+}
+
+// GetAllQueue returns the records of every queue page
+func (c *Client) GetAllQueue(ctx context.Context) ([]QueueRecord, error) {
+	return arr.All(ctx, arr.PageOptions{PageSize: 200}, c.GetQueue)
+}
+
+func (c *Client) DeleteQueueItem(ctx context.Context, id int, opts arr.QueueDeleteOptions) error {
+	query := url.Values{
+		"removeFromClient": {strconv.FormatBool(opts.RemoveFromClient)},
+		"blocklist":        {strconv.FormatBool(opts.Blocklist)},
+		"skipRedownload":   {strconv.FormatBool(opts.SkipRedownload)},
+	}
+	return c.Delete(ctx, "/api/v3/queue/"+strconv.Itoa(id), query)
+}
+
+func (c *Client) GetBlocklist(ctx context.Context, opts arr.PageOptions) (*arr.Page[BlocklistItem], error) {
+	return arr.GetJSON[*arr.Page[BlocklistItem]](ctx, c.Client, "/api/v3/blocklist", opts.Values(nil))
+}
+
+func (c *Client) DeleteBlocklistItem(ctx context.Context, id int) error {
+	return c.Delete(ctx, "/api/v3/blocklist/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetTags(ctx context.Context) ([]arr.Tag, error) {
+	return arr.GetJSON[[]arr.Tag](ctx, c.Client, "/api/v3/tag", nil)
+}
+
+func (c *Client) CreateTag(ctx context.Context, label string) (*arr.Tag, error) {
+	return arr.PostJSON[*arr.Tag](ctx, c.Client, "/api/v3/tag", arr.Tag{Label: label}, false)
+}
+
+func (c *Client) GetRootFolders(ctx context.Context) ([]arr.RootFolder, error) {
+	return arr.GetJSON[[]arr.RootFolder](ctx, c.Client, "/api/v3/rootfolder", nil)
+}
+
+// SendCommand starts a command, commands are retried since repeating a search or rescan is harmless
+func (c *Client) SendCommand(ctx context.Context, cmd arr.CommandRequest) (*arr.Command, error) {
+	return arr.PostJSON[*arr.Command](ctx, c.Client, "/api/v3/command", cmd, true)
+}
+
+func (c *Client) GetCommand(ctx context.Context, id int) (*arr.Command, error) {
+	return arr.GetJSON[*arr.Command](ctx, c.Client, "/api/v3/command/"+strconv.Itoa(id), nil)
+}
+
+// MoviesSearch searches all indexers for the movies
+func (c *Client) MoviesSearch(ctx context.Context, movieIDs ...int) (*arr.Command, error) {
+	return c.SendCommand(ctx, arr.CommandRequest{
+		Name: "MoviesSearch",
+		Body: map[string]any{"movieIds": movieIDs},
+	})
+}
+
+// RescanMovie makes radarr re-read the files of the movie from disk
+func (c *Client) RescanMovie(ctx context.Context, movieID int) (*arr.Command, error) {
+	return c.SendCommand(ctx, arr.CommandRequest{
+		Name: "RescanMovie",
+		Body: map[string]any{"movieId": movieID},
+	})
+}
+
+// GetReleases runs an interactive search for the movie
+func (c *Client) GetReleases(ctx context.Context, movieID int) ([]Release, error) {
+	query := url.Values{"movieId": {strconv.Itoa(movieID)}}
+	return arr.GetJSON[[]Release](ctx, c.Client, "/api/v3/release", query)
+}
+
+// GrabRelease sends a release returned by GetReleases to the download client
+func (c *Client) GrabRelease(ctx context.Context, release arr.GrabRequest) error {
+	return c.Post(ctx, "/api/v3/release", release, nil)
+}
+
+func (c *Client) GetSystemStatus(ctx context.Context) (*arr.SystemStatus, error) {
+	return arr.GetJSON[*arr.SystemStatus](ctx, c.Client, "/api/v3/system/status", nil)
+}
@@ -0,0 +1,76 @@
+package radarr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	return New(server.URL, arrtest.APIKey), server
+}
+
+func TestClient_GetMovieFile(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("GET /api/v3/moviefile/{id}", http.StatusOK, map[string]any{
+		"id":        42,
+		"movieId":   7,
+		"path":      "/movies/Alien (1979)/Alien.mkv",
+		"languages": []map[string]any{{"id": 1, "name": "English"}},
+		"mediaInfo": map[string]any{"audioLanguages": "eng", "subtitles": "eng/fre"},
+	})
+
+	file, err := cli.GetMovieFile(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, 7, file.MovieID)
+	assert.Equal(t, "eng/fre", file.MediaInfo.Subtitles)
+}
+
+func TestClient_Error(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("GET /api/v3/movie/{id}", http.StatusNotFound, map[string]string{"message": "Movie with ID 3 does not exist"})
+
+	movie, err := cli.GetMovie(context.Background(), 3)
+	assert.Nil(t, movie)
+	assert.True(t, arr.IsNotFound(err))
+	assert.ErrorContains(t, err, "GET /api/v3/movie/3 failed with status code 404")
+}
+
+func TestClient_MoviesSearch(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 9, Name: "MoviesSearch"})
+
+	_, err := cli.MoviesSearch(context.Background(), 7)
+	require.NoError(t, err)
+
+	reqs := server.RequestsTo(http.MethodPost, "/api/v3/command")
+	require.Len(t, reqs, 1)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &body))
+	assert.Equal(t, "MoviesSearch", body["name"])
+	assert.Equal(t, []any{float64(7)}, body["movieIds"])
+}
+
+func TestClient_GetHistory(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/history", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "7", r.URL.Query().Get("movieIds"))
+		assert.Equal(t, "grabbed", r.URL.Query().Get("eventType"))
+		arrtest.WriteJSON(w, http.StatusOK, arr.Page[HistoryRecord]{
+			Page: 1, PageSize: 50, TotalRecords: 1,
+			Records: []HistoryRecord{{ID: 1, MovieID: 7, SourceTitle: "Alien.1979.1080p"}},
+		})
+	})
+
+	page, err := cli.GetHistory(context.Background(), HistoryOptions{MovieID: 7, EventType: "grabbed"})
+	require.NoError(t, err)
+	assert.False(t, page.HasNext())
+	assert.Equal(t, "Alien.1979.1080p", page.Records[0].SourceTitle)
+}
@@ -0,0 +1,109 @@
+package radarr
+
+import (
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+)
+
+type Movie struct {
+	ID               int          `json:"id"`
+	Title            string       `json:"title"`
+	Year             int          `json:"year"`
+	Path             string       `json:"path"`
+	RootFolderPath   string       `json:"rootFolderPath"`
+	TmdbID           int          `json:"tmdbId"`
+	ImdbID           string       `json:"imdbId"`
+	Tags             []int        `json:"tags"`
+	Monitored        bool         `json:"monitored"`
+	HasFile          bool         `json:"hasFile"`
+	MovieFileID      int          `json:"movieFileId"`
+	OriginalLanguage arr.Language `json:"originalLanguage"`
+	InCinemas        time.Time    `json:"inCinemas"`
+	PhysicalRelease  time.Time    `json:"physicalRelease"`
+	DigitalRelease   time.Time    `json:"digitalRelease"`
+	Added            time.Time    `json:"added"`
+}
+
+type MovieFile struct {
+	ID            int                `json:"id"`
+	MovieID       int                `json:"movieId"`
+	RelativePath  string             `json:"relativePath"`
+	Path          string             `json:"path"`
+	Size          int64              `json:"size"`
+	DateAdded     time.Time          `json:"dateAdded"`
+	SceneName     string             `json:"sceneName"`
+	ReleaseGroup  string             `json:"releaseGroup"`
+	Quality       arr.Quality        `json:"quality"`
+	Languages     []arr.Language     `json:"languages"`
+	MediaInfo     *arr.MediaInfo     `json:"mediaInfo"`
+	CustomFormats []arr.CustomFormat `json:"customFormats"`
+}
+
+type HistoryRecord struct {
+	ID          int               `json:"id"`
+	MovieID     int               `json:"movieId"`
+	SourceTitle string            `json:"sourceTitle"`
+	EventType   string            `json:"eventType"`
+	Date        time.Time         `json:"date"`
+	DownloadID  string            `json:"downloadId"`
+	Languages   []arr.Language    `json:"languages"`
+	Quality     arr.Quality       `json:"quality"`
+	Data        map[string]string `json:"data"`
+}
+
+type QueueRecord struct {
+	ID                    int            `json:"id"`
+	MovieID               int            `json:"movieId"`
+	Title                 string         `json:"title"`
+	Status                string         `json:"status"`
+	TrackedDownloadStatus string         `json:"trackedDownloadStatus"`
+	TrackedDownloadState  string         `json:"trackedDownloadState"`
+	DownloadID            string         `json:"downloadId"`
+	Protocol              string         `json:"protocol"`
+	DownloadClient        string         `json:"downloadClient"`
+	Indexer               string         `json:"indexer"`
+	OutputPath            string         `json:"outputPath"`
+	Size                  float64        `json:"size"`
+	SizeLeft              float64        `json:"sizeleft"`
+	Languages             []arr.Language `json:"languages"`
+	Quality               arr.Quality    `json:"quality"`
+}
+
+type BlocklistItem struct {
+	ID          int            `json:"id"`
+	MovieID     int            `json:"movieId"`
+	SourceTitle string         `json:"sourceTitle"`
+	Date        time.Time      `json:"date"`
+	Protocol    string         `json:"protocol"`
+	Indexer     string         `json:"indexer"`
+	Message     string         `json:"message"`
+	Languages   []arr.Language `json:"languages"`
+	Quality     arr.Quality    `json:"quality"`
+}
+
+type Release struct {
+	GUID              string             `json:"guid"`
+	Title             string             `json:"title"`
+	IndexerID         int                `json:"indexerId"`
+	Indexer           string             `json:"indexer"`
+	Protocol          string             `json:"protocol"`
+	Size              int64              `json:"size"`
+	Seeders           int                `json:"seeders"`
+	Approved          bool               `json:"approved"`
+	Rejected          bool               `json:"rejected"`
+	Rejections        []string           `json:"rejections"`
+	DownloadAllowed   bool               `json:"downloadAllowed"`
+	Languages         []arr.Language     `json:"languages"`
+	Quality           arr.Quality        `json:"quality"`
+	CustomFormats     []arr.CustomFormat `json:"customFormats"`
+	CustomFormatScore int                `json:"customFormatScore"`
+	MovieID           int                `json:"movieId"`
+}
+
+// HistoryOptions filters the history endpoint, zero values are not sent
+type HistoryOptions struct {
+	arr.PageOptions
+	MovieID   int
+	EventType string
+}
@@ -0,0 +1,138 @@
+// Package sabnzbd is a minimal client for the SABnzbd api
+package sabnzbd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"resty.dev/v3"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// ErrNotFound is returned when SABnzbd does not know the download
+var ErrNotFound = errors.New("download not found")
+
+// Slot is a download in the queue
+type Slot struct {
+	NzoID      string `json:"nzo_id"`
+	Filename   string `json:"filename"`
+	Status     string `json:"status"`
+	Category   string `json:"cat"`
+	Percentage string `json:"percentage"`
+	MB         string `json:"mb"`
+	MBLeft     string `json:"mbleft"`
+}
+
+// File is a file of a queued download, names come from the nzb and may be obfuscated
+type File struct {
+	NzfID    string `json:"nzf_id"`
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	MB       string `json:"mb"`
+	MBLeft   string `json:"mbleft"`
+}
+
+type Client struct {
+	http   *resty.Client
+	apiKey string
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		http:   resty.New().SetBaseURL(baseURL).SetTimeout(defaultTimeout),
+		apiKey: apiKey,
+	}
+}
+
+// Queue returns the downloads in the queue
+func (c *Client) Queue(ctx context.Context) ([]Slot, error) {
+	var res struct {
+		Queue struct {
+			Slots []Slot `json:"slots"`
+		} `json:"queue"`
+	}
+	err := c.call(ctx, map[string]string{"mode": "queue"}, &res)
+	return res.Queue.Slots, err
+}
+
+// Slot returns the queued download with the id
+func (c *Client) Slot(ctx context.Context, nzoID string) (*Slot, error) {
+	slots, err := c.Queue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range slots {
+		if slot.NzoID == nzoID {
+			return &slot, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Files lists the files of a queued download
+func (c *Client) Files(ctx context.Context, nzoID string) ([]File, error) {
+	if _, err := c.Slot(ctx, nzoID); err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Files []File `json:"files"`
+	}
+	err := c.call(ctx, map[string]string{"mode": "get_files", "value": nzoID}, &res)
+	return res.Files, err
+}
+
+// Delete removes the download from the queue, along with the downloaded data if deleteFiles is set
+func (c *Client) Delete(ctx context.Context, nzoID string, deleteFiles bool) error {
+	params := map[string]string{"mode": "queue", "name": "delete", "value": nzoID}
+	if deleteFiles {
+		params["del_files"] = "1"
+	}
+
+	var res struct {
+		NzoIDs []string `json:"nzo_ids"`
+	}
+	if err := c.call(ctx, params, &res); err != nil {
+		return err
+	}
+	if len(res.NzoIDs) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// call sends an api request, SABnzbd reports errors with status 200 and {"status": false, "error": "..."}
+func (c *Client) call(ctx context.Context, params map[string]string, result any) error {
+	res, err := c.http.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetQueryParam("apikey", c.apiKey).
+		SetQueryParam("output", "json").
+		Get("/api")
+	if err != nil {
+		return fmt.Errorf("sabnzbd %s: %w", params["mode"], err)
+	}
+	if res.IsError() {
+		return fmt.Errorf("sabnzbd %s failed with status code %d: %s", params["mode"], res.StatusCode(), res.String())
+	}
+
+	var status struct {
+		Status *bool  `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err = json.Unmarshal(res.Bytes(), &status); err != nil {
+		return fmt.Errorf("sabnzbd %s: unable to decode response: %w", params["mode"], err)
+	}
+	if status.Status != nil && !*status.Status {
+		return fmt.Errorf("sabnzbd %s: %s", params["mode"], status.Error)
+	}
+
+	if err = json.Unmarshal(res.Bytes(), result); err != nil {
+		return fmt.Errorf("sabnzbd %s: unable to decode response: %w", params["mode"], err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package sabnzbd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAPIKey = "sab-key"
+	testNzoID  = "SABnzbd_nzo_p86tgx"
+)
+
+// fakeSabnzbd is a stand-in for the SABnzbd api answering the modes used by the client
+type fakeSabnzbd struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	slots   []Slot
+	files   map[string][]File
+	deleted []string
+}
+
+func newFakeSabnzbd(t *testing.T) *fakeSabnzbd {
+	f := &fakeSabnzbd{
+		slots: []Slot{{NzoID: testNzoID, Filename: "Movie.2019.1080p.BluRay.x264-GROUP", Status: "Downloading"}},
+		files: map[string][]File{
+			testNzoID: {
+				{NzfID: "SABnzbd_nzf_1", Filename: "Movie.2019.iTALiAN.1080p.BluRay.x264-GROUP.mkv", Status: "active"},
+				{NzfID: "SABnzbd_nzf_2", Filename: "Movie.2019.iTALiAN.1080p.BluRay.x264-GROUP.par2", Status: "active"},
+			},
+		},
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		query := r.URL.Query()
+		if query.Get("apikey") != testAPIKey {
+			writeJSON(w, map[string]any{"status": false, "error": "API Key Incorrect"})
+			return
+		}
+
+		switch query.Get("mode") {
+		case "queue":
+			if query.Get("name") == "delete" {
+				f.delete(w, query.Get("value"), query.Get("del_files"))
+				return
+			}
+			writeJSON(w, map[string]any{"queue": map[string]any{"slots": f.slots}})
+		case "get_files":
+			writeJSON(w, map[string]any{"files": f.files[query.Get("value")]})
+		default:
+			writeJSON(w, map[string]any{"status": false, "error": "not implemented"})
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeSabnzbd) delete(w http.ResponseWriter, nzoID, delFiles string) {
+	idx := slices.IndexFunc(f.slots, func(s Slot) bool { return s.NzoID == nzoID })
+	if idx == -1 {
+		writeJSON(w, map[string]any{"status": true, "nzo_ids": []string{}})
+		return
+	}
+	f.slots = slices.Delete(f.slots, idx, idx+1)
+	f.deleted = append(f.deleted, nzoID+":"+delFiles)
+	writeJSON(w, map[string]any{"status": true, "nzo_ids": []string{nzoID}})
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestClient_Files(t *testing.T) {
+	server := newFakeSabnzbd(t)
+	cli := New(server.URL, testAPIKey)
+
+	files, err := cli.Files(context.Background(), testNzoID)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "Movie.2019.iTALiAN.1080p.BluRay.x264-GROUP.mkv", files[0].Filename)
+
+	_, err = cli.Files(context.Background(), "SABnzbd_nzo_unknown")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_Delete(t *testing.T) {
+	server := newFakeSabnzbd(t)
+	cli := New(server.URL, testAPIKey)
+
+	require.NoError(t, cli.Delete(context.Background(), testNzoID, true))
+	assert.Equal(t, []string{testNzoID + ":1"}, server.deleted)
+
+	err := cli.Delete(context.Background(), testNzoID, true)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_ApiError(t *testing.T) {
+	server := newFakeSabnzbd(t)
+	cli := New(server.URL, "wrong")
+
+	_, err := cli.Queue(context.Background())
+	assert.ErrorContains(t, err, "API Key Incorrect")
+}
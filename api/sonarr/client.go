@@ -0,0 +1,164 @@
+// Package sonarr is a typed client for the Sonarr v3 api, which is also served by Sonarr v4
+package sonarr
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/RA341/warden/api/arr"
+)
+
+type Client struct {
+	*arr.Client
+}
+
+func New(baseURL, apiKey string) *Client {
+	return &Client{Client: arr.NewClient(baseURL, apiKey)}
+}
+
+func (c *Client) GetAllSeries(ctx context.Context) ([]Series, error) {
+	return arr.GetJSON[[]Series](ctx, c.Client, "/api/v3/series", nil)
+}
+
+func (c *Client) GetSeries(ctx context.Context, id int) (*Series, error) {
+	return arr.GetJSON[*Series](ctx, c.Client, "/api/v3/series/"+strconv.Itoa(id), nil)
+}
+
+// AddTags adds the tags to the series, their other tags are kept
+func (c *Client) AddTags(ctx context.Context, seriesIDs []int, tagIDs []int) error {
+	body := map[string]any{
+		"seriesIds": seriesIDs,
+		"tags":      tagIDs,
+		"applyTags": "add",
+	}
+	return c.Put(ctx, "/api/v3/series/editor", body, nil)
+}
+
+func (c *Client) GetEpisodes(ctx context.Context, seriesID int) ([]Episode, error) {
+	query := url.Values{"seriesId": {strconv.Itoa(seriesID)}}
+	return arr.GetJSON[[]Episode](ctx, c.Client, "/api/v3/episode", query)
+}
+
+func (c *Client) GetEpisode(ctx context.Context, id int) (*Episode, error) {
+	return arr.GetJSON[*Episode](ctx, c.Client, "/api/v3/episode/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) MonitorEpisodes(ctx context.Context, episodeIDs []int, monitored bool) error {
+	body := map[string]any{
+		"episodeIds": episodeIDs,
+		"monitored":  monitored,
+	}
+	return c.Put(ctx, "/api/v3/episode/monitor", body, nil)
+}
+
+func (c *Client) GetEpisodeFile(ctx context.Context, id int) (*EpisodeFile, error) {
+	return arr.GetJSON[*EpisodeFile](ctx, c.Client, "/api/v3/episodefile/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetEpisodeFiles(ctx context.Context, seriesID int) ([]EpisodeFile, error) {
+	query := url.Values{"seriesId": {strconv.Itoa(seriesID)}}
+	return arr.GetJSON[[]EpisodeFile](ctx, c.Client, "/api/v3/episodefile", query)
+}
+
+func (c *Client) DeleteEpisodeFile(ctx context.Context, id int) error {
+	return c.Delete(ctx, "/api/v3/episodefile/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetHistory(ctx context.Context, opts HistoryOptions) (*arr.Page[HistoryRecord], error) {
+	query := url.Values{}
+	if opts.EpisodeID != 0 {
+		query.Set("episodeId", strconv.Itoa(opts.EpisodeID))
+	}
+	if opts.SeriesID != 0 {
+		query.Set("seriesId", strconv.Itoa(opts.SeriesID))
+	}
+	if opts.EventType != "" {
+		query.Set("eventType", opts.EventType)
+	}
+
+	return arr.GetJSON[*arr.Page[HistoryRecord]](ctx, c.Client, "/api/v3/history", opts.Values(query))
+}
+
+// MarkHistoryFailed marks a grab as failed, sonarr blocklists the release and searches again if redownloading failed downloads is enabled
+func (c *Client) MarkHistoryFailed(ctx context.Context, id int) error {
+	return c.Post(ctx, "/api/v3/history/failed/"+strconv.Itoa(id), nil, nil)
+}
+
+func (c *Client) GetQueue(ctx context.Context, opts arr.PageOptions) (*arr.Page[QueueRecord], error) {
+	return arr.GetJSON[*arr.Page[QueueRecord]](ctx, c.Client, "/api/v3/queue", opts.Values(nil))
+}
+
+// GetAllQueue returns the records of every queue page
+func (c *Client) GetAllQueue(ctx context.Context) ([]QueueRecord, error) {
+	return arr.All(ctx, arr.PageOptions{PageSize: 200}, c.GetQueue)
+}
+
+func (c *Client) DeleteQueueItem(ctx context.Context, id int, opts arr.QueueDeleteOptions) error {
+	query := url.Values{
+		"removeFromClient": {strconv.FormatBool(opts.RemoveFromClient)},
+		"blocklist":        {strconv.FormatBool(opts.Blocklist)},
+		"skipRedownload":   {strconv.FormatBool(opts.SkipRedownload)},
+	}
+	return c.Delete(ctx, "/api/v3/queue/"+strconv.Itoa(id), query)
+}
+
+func (c *Client) GetBlocklist(ctx context.Context, opts arr.PageOptions) (*arr.Page[BlocklistItem], error) {
+	return arr.GetJSON[*arr.Page[BlocklistItem]](ctx, c.Client, "/api/v3/blocklist", opts.Values(nil))
+}
+
+func (c *Client) DeleteBlocklistItem(ctx context.Context, id int) error {
+	return c.Delete(ctx, "/api/v3/blocklist/"+strconv.Itoa(id), nil)
+}
+
+func (c *Client) GetTags(ctx context.Context) ([]arr.Tag, error) {
+	return arr.GetJSON[[]arr.Tag](ctx, c.Client, "/api/v3/tag", nil)
+}
+
+func (c *Client) CreateTag(ctx context.Context, label string) (*arr.Tag, error) {
+	return arr.PostJSON[*arr.Tag](ctx, c.Client, "/api/v3/tag", arr.Tag{Label: label}, false)
+}
+
+func (c *Client) GetRootFolders(ctx context.Context) ([]arr.RootFolder, error) {
+	return arr.GetJSON[[]arr.RootFolder](ctx, c.Client, "/api/v3/rootfolder", nil)
+}
+
+// SendCommand starts a command, commands are retried since repeating a search or rescan is harmless
+func (c *Client) SendCommand(ctx context.Context, cmd arr.CommandRequest) (*arr.Command, error) {
+	return arr.PostJSON[*arr.Command](ctx, c.Client, "/api/v3/command", cmd, true)
+}
+
+func (c *Client) GetCommand(ctx context.Context, id int) (*arr.Command, error) {
+	return arr.GetJSON[*arr.Command](ctx, c.Client, "/api/v3/command/"+strconv.Itoa(id), nil)
+}
+
+// EpisodeSearch searches all indexers for the episodes
+func (c *Client) EpisodeSearch(ctx context.Context, episodeIDs ...int) (*arr.Command, error) {
+	return c.SendCommand(ctx, arr.CommandRequest{
+		Name: "EpisodeSearch",
+		Body: map[string]any{"episodeIds": episodeIDs},
+	})
+}
+
+// RescanSeries makes sonarr re-read the files of the series from disk
+func (c *Client) RescanSeries(ctx context.Context, seriesID int) (*arr.Command, error) {
+	return c.SendCommand(ctx, arr.CommandRequest{
+		Name: "RescanSeries",
+		Body: map[string]any{"seriesId": seriesID},
+	})
+}
+
+// GetReleases runs an interactive search for the episode
+func (c *Client) GetReleases(ctx context.Context, episodeID int) ([]Release, error) {
+	query := url.Values{"episodeId": {strconv.Itoa(episodeID)}}
+	return arr.GetJSON[[]Release](ctx, c.Client, "/api/v3/release", query)
+}
+
+// GrabRelease sends a release returned by GetReleases to the download client
+func (c *Client) GrabRelease(ctx context.Context, release arr.GrabRequest) error {
+	return c.Post(ctx, "/api/v3/release", release, nil)
+}
+
+func (c *Client) GetSystemStatus(ctx context.Context) (*arr.SystemStatus, error) {
+	return arr.GetJSON[*arr.SystemStatus](ctx, c.Client, "/api/v3/system/status", nil)
+}
@@ -0,0 +1,134 @@
+package sonarr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	return New(server.URL, arrtest.APIKey), server
+}
+
+func TestClient_GetEpisodeFile(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/episodefile/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"id": 11729,
+			"seriesId": 12,
+			"path": "/tv/Show/Season 01/Show - S01E01.mkv",
+			"languages": [{"id": 8, "name": "Japanese"}],
+			"mediaInfo": {"audioLanguages": "jpn/eng", "subtitles": "eng"}
+		}`))
+	})
+
+	file, err := cli.GetEpisodeFile(context.Background(), 11729)
+	require.NoError(t, err)
+	assert.Equal(t, 11729, file.ID)
+	assert.Equal(t, []arr.Language{{ID: 8, Name: "Japanese"}}, file.Languages)
+	assert.Equal(t, "jpn/eng", file.MediaInfo.AudioLanguages)
+}
+
+func TestClient_Error(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusNotFound, map[string]string{"message": "NotFound"})
+
+	err := cli.DeleteEpisodeFile(context.Background(), 1)
+
+	var arrErr *arr.Error
+	require.ErrorAs(t, err, &arrErr)
+	assert.Equal(t, http.StatusNotFound, arrErr.StatusCode)
+	assert.Equal(t, http.MethodDelete, arrErr.Method)
+	assert.Contains(t, arrErr.Body, "NotFound")
+	assert.True(t, arr.IsNotFound(err))
+
+	unauthorized := New(server.URL, "wrong-key")
+	_, err = unauthorized.GetTags(context.Background())
+	assert.Equal(t, http.StatusUnauthorized, arr.StatusCode(err))
+}
+
+func TestClient_ContextCancel(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/system/status", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := cli.GetSystemStatus(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+func TestClient_GetAllQueue(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.Handle("GET /api/v3/queue", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		records := []QueueRecord{{ID: 1}, {ID: 2}}
+		if page == "2" {
+			records = []QueueRecord{{ID: 3}}
+		}
+		arrtest.WriteJSON(w, http.StatusOK, arr.Page[QueueRecord]{
+			Page:         map[string]int{"1": 1, "2": 2}[page],
+			PageSize:     2,
+			TotalRecords: 3,
+			Records:      records,
+		})
+	})
+
+	queue, err := arr.All(context.Background(), arr.PageOptions{PageSize: 2}, cli.GetQueue)
+	require.NoError(t, err)
+	assert.Equal(t, []QueueRecord{{ID: 1}, {ID: 2}, {ID: 3}}, queue)
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/queue"), 2)
+}
+
+func TestClient_EpisodeSearch(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 5, Name: "EpisodeSearch", Status: "queued"})
+
+	cmd, err := cli.EpisodeSearch(context.Background(), 13947)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cmd.ID)
+
+	reqs := server.RequestsTo(http.MethodPost, "/api/v3/command")
+	require.Len(t, reqs, 1)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &body))
+	assert.Equal(t, "EpisodeSearch", body["name"])
+	assert.Equal(t, []any{float64(13947)}, body["episodeIds"])
+}
+
+func TestClient_DeleteQueueItem(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("DELETE /api/v3/queue/{id}", http.StatusOK, nil)
+
+	err := cli.DeleteQueueItem(context.Background(), 7, arr.QueueDeleteOptions{RemoveFromClient: true, Blocklist: true})
+	require.NoError(t, err)
+
+	reqs := server.RequestsTo(http.MethodDelete, "/api/v3/queue/7")
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "blocklist=true&removeFromClient=true&skipRedownload=false", reqs[0].Query)
+}
+
+func TestClient_AddTags(t *testing.T) {
+	cli, server := newTestClient(t)
+	server.JSON("PUT /api/v3/series/editor", http.StatusAccepted, nil)
+
+	require.NoError(t, cli.AddTags(context.Background(), []int{118}, []int{4}))
+
+	reqs := server.RequestsTo(http.MethodPut, "/api/v3/series/editor")
+	require.Len(t, reqs, 1)
+	assert.JSONEq(t, `{"seriesIds":[118],"tags":[4],"applyTags":"add"}`, string(reqs[0].Body))
+}
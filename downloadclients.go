@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RA341/warden/api/qbittorrent"
+	"github.com/RA341/warden/api/sabnzbd"
+)
+
+type DownloadClientType = string
+
+const (
+	QBITTORRENT DownloadClientType = "qbittorrent"
+	SABNZBD     DownloadClientType = "sabnzbd"
+)
+
+// errDownloadGone is returned by a DownloadClient when the download no longer exists
+var errDownloadGone = errors.New("download no longer exists")
+
+// DownloadClientConfig configures a download client used by an *arr instance,
+// it is keyed by the name of the download client in the *arr app
+type DownloadClientConfig struct {
+	Type     DownloadClientType `json:"type"`
+	URL      string             `json:"url"`
+	Username string             `json:"username,omitempty"`
+	Password string             `json:"password,omitempty"`
+	ApiKey   string             `json:"api_key,omitempty"`
+}
+
+// DownloadClient exposes the files of an in progress download
+type DownloadClient interface {
+	// Files returns the names of the files in the download, empty while they are not known yet
+	Files(ctx context.Context, downloadID string) ([]string, error)
+	// Remove cancels the download and deletes its data
+	Remove(ctx context.Context, downloadID string) error
+}
+
+func newDownloadClient(conf *DownloadClientConfig) (DownloadClient, error) {
+	switch strings.ToLower(conf.Type) {
+	case QBITTORRENT:
+		return &qbittorrentClient{api: qbittorrent.New(conf.URL, conf.Username, conf.Password)}, nil
+	case SABNZBD:
+		return &sabnzbdClient{api: sabnzbd.New(conf.URL, conf.ApiKey)}, nil
+	default:
+		return nil, fmt.Errorf("unknown download client type %q", conf.Type)
+	}
+}
+
+type qbittorrentClient struct {
+	api *qbittorrent.Client
+}
+
+func (q *qbittorrentClient) Files(ctx context.Context, downloadID string) ([]string, error) {
+	files, err := q.api.Files(ctx, downloadID)
+	if errors.Is(err, qbittorrent.ErrNotFound) {
+		return nil, errDownloadGone
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names, nil
+}
+
+func (q *qbittorrentClient) Remove(ctx context.Context, downloadID string) error {
+	return q.api.Delete(ctx, downloadID, true)
+}
+
+type sabnzbdClient struct {
+	api *sabnzbd.Client
+}
+
+func (s *sabnzbdClient) Files(ctx context.Context, downloadID string) ([]string, error) {
+	files, err := s.api.Files(ctx, downloadID)
+	if errors.Is(err, sabnzbd.ErrNotFound) {
+		return nil, errDownloadGone
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Filename)
+	}
+	return names, nil
+}
+
+func (s *sabnzbdClient) Remove(ctx context.Context, downloadID string) error {
+	return s.api.Delete(ctx, downloadID, true)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/release"
+	"github.com/rs/zerolog/log"
+)
+
+// webhook event types sent by sonarr and radarr
+const (
+	eventGrab     = "Grab"
+	eventDownload = "Download"
+	eventTest     = "Test"
+)
+
+const (
+	queueAttempts     = 6
+	defaultQueueDelay = 20 * time.Second
+)
+
+var errNotQueued = errors.New("download is not in the queue")
+
+// webhookEventType returns the eventType of a webhook, payloads without one are treated as downloads
+func webhookEventType(payload []byte) string {
+	var event struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil || event.EventType == "" {
+		return eventDownload
+	}
+	return event.EventType
+}
+
+// webhookRelease is the release of a Grab webhook
+type webhookRelease struct {
+	ReleaseTitle  string   `json:"releaseTitle"`
+	Indexer       string   `json:"indexer"`
+	CustomFormats []string `json:"customFormats"`
+}
+
+// GrabInfo is a grabbed release along with the item it was grabbed for
+type GrabInfo struct {
+	// MediaIDs are the episode ids or the movie id
+	MediaIDs []int
+	// Path is the full path of the series or movie folder
+	Path             string
+	TvdbID           int
+	TmdbID           int
+	ImdbID           string
+	Tags             []string
+	TagIDs           []int
+	OriginalLanguage string
+	ReleaseTitle     string
+	CustomFormats    []string
+	DownloadID       string
+	// DownloadClient is the name of the download client in the *arr app
+	DownloadClient     string
+	DownloadClientType string
+	// ReleaseDate is when the newest grabbed episode aired or the movie was released, zero until it is loaded
+	ReleaseDate time.Time
+}
+
+// grabViolation returns why the release certainly cannot satisfy the profile,
+// releases are only rejected when their title names the audio languages and none of them are unlisted.
+// Ambiguous titles are left to the check after the import
+func (p *Profile) grabViolation(title string, customFormats []string) (string, bool) {
+	parsed := release.Parse(title)
+	if parsed.Ambiguous {
+		return "", false
+	}
+	audio := parsed.Audio
+	unlisted := parsed.HasUnlistedAudio()
+	for _, cf := range customFormats {
+		cfParsed := release.Parse(cf)
+		audio = append(audio, cfParsed.Audio...)
+		unlisted = unlisted || cfParsed.HasUnlistedAudio()
+	}
+	audio = lang.NormalizeAll(audio)
+
+	if unlisted || len(audio) == 0 {
+		return "", false
+	}
+
+	var missing []string
+	for _, required := range lang.NormalizeAll(p.RequiredLanguagesAudio) {
+		if !isSubset(audio, []string{required}) {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("release audio %s is missing %s", strings.Join(audio, ","), strings.Join(missing, ",")), true
+}
+
+// findQueueItems polls fetch until the download shows up in the queue, the *arr apps only add a grab
+// to the queue once they have polled the download client
+func findQueueItems(ctx context.Context, delay time.Duration, fetch func(context.Context) ([]int, error)) ([]int, error) {
+	for attempt := 1; ; attempt++ {
+		ids, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			return ids, nil
+		}
+		if attempt >= queueAttempts {
+			return nil, errNotQueued
+		}
+
+		log.Debug().Msgf("download is not queued yet, retrying in %s", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sameDownload compares download ids, sonarr and radarr uppercase torrent hashes but not every client does
+func sameDownload(a, b string) bool {
+	return a != "" && strings.EqualFold(a, b)
+}
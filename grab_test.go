@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/RA341/warden/api/sonarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sonarrGrabPayload = `{
+  "eventType": "Grab",
+  "series": {
+    "id": 118,
+    "title": "Show",
+    "path": "/media/anime/Show",
+    "tvdbId": 433563,
+    "tags": ["anime"],
+    "originalLanguage": {"id": 8, "name": "Japanese"}
+  },
+  "episodes": [{"id": 13947}, {"id": 13948}],
+  "release": {
+    "quality": "WEBDL-1080p",
+    "releaseTitle": "Show.S01E01E02.GERMAN.1080p.WEB.h264-GROUP",
+    "indexer": "Indexer",
+    "size": 1402938112,
+    "customFormats": ["German"],
+    "customFormatScore": 0
+  },
+  "downloadClient": "qBittorrent",
+  "downloadId": "9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3"
+}`
+
+func TestWebhookEventType(t *testing.T) {
+	assert.Equal(t, eventGrab, webhookEventType([]byte(sonarrGrabPayload)))
+	assert.Equal(t, eventTest, webhookEventType([]byte(`{"eventType": "Test"}`)))
+	assert.Equal(t, eventDownload, webhookEventType([]byte(`{"series": {}}`)))
+}
+
+func TestSonarr_IgnoresOtherEvents(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesAudio: []string{"jpn"}, RejectGrabs: true})
+
+	for _, event := range []string{"Rename", "EpisodeFileDelete", "SeriesDelete", "Health"} {
+		payload := strings.Replace(sonarrGrabPayload, `"eventType": "Grab"`, `"eventType": "`+event+`"`, 1)
+		require.NoError(t, cli.ProcessWebhook([]byte(payload)))
+	}
+	assert.Empty(t, server.Requests())
+}
+
+func TestProfile_GrabViolation(t *testing.T) {
+	prof := &Profile{RequiredLanguagesAudio: []string{"jpn"}}
+
+	tests := []struct {
+		title   string
+		formats []string
+		reject  bool
+	}{
+		{"Show.S01E01.GERMAN.1080p.WEB.h264-GROUP", nil, true},
+		{"Show.S01E01.GERMAN.JAPANESE.1080p.WEB.h264-GROUP", nil, false},
+		{"Show.S01E01.German.DL.1080p.WEB.h264-GROUP", nil, false},
+		{"Show.S01E01.MULTi.1080p.WEB.h264-GROUP", nil, false},
+		{"[SubsPlease] Show - 01 (1080p)", nil, false},
+		{"[Group] Show - 01 (English Dub) [1080p]", nil, true},
+		{"Show.S01E01.1080p.WEB.h264-GROUP", []string{"German"}, true},
+		{"Show.S01E01.1080p.WEB.h264-GROUP", []string{"Anime Dual Audio"}, false},
+		{"Dan.Da.Dan.S01E01.1080p.WEB.H264-GRP", nil, false},
+		{"The.Chi.S06E01.1080p.WEB.h264-GRP", nil, false},
+		{"Dan Da Dan", nil, false},
+		{"Show.S01E01.ITA.1080p.WEB.h264-GROUP", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			_, reject := prof.grabViolation(tt.title, tt.formats)
+			assert.Equal(t, tt.reject, reject)
+		})
+	}
+}
+
+func TestFindQueueItems_WaitsForQueue(t *testing.T) {
+	var calls atomic.Int32
+	ids, err := findQueueItems(context.Background(), time.Millisecond, func(ctx context.Context) ([]int, error) {
+		if calls.Add(1) < 3 {
+			return nil, nil
+		}
+		return []int{4}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, ids)
+
+	_, err = findQueueItems(context.Background(), time.Millisecond, func(ctx context.Context) ([]int, error) {
+		return nil, nil
+	})
+	assert.True(t, errors.Is(err, errNotQueued))
+}
+
+func newGrabCheckSonarr(t *testing.T, prof *Profile) (*SonarrInst, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/queue", http.StatusOK, arr.Page[sonarr.QueueRecord]{
+		Page: 1, PageSize: 200, TotalRecords: 3,
+		Records: []sonarr.QueueRecord{
+			{ID: 1, EpisodeID: 13947, DownloadID: "9f3b1c7a2e4d6f8091a3b5c7d9e1f3a5b7c9d1e3"},
+			{ID: 2, EpisodeID: 13948, DownloadID: "9f3b1c7a2e4d6f8091a3b5c7d9e1f3a5b7c9d1e3"},
+			{ID: 3, EpisodeID: 500, DownloadID: "OTHER"},
+		},
+	})
+	server.JSON("DELETE /api/v3/queue/{id}", http.StatusOK, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath:    server.URL,
+		ApiKey:      arrtest.APIKey,
+		LanguageMap: map[string]*Profile{"/media/anime": prof},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.queueDelay = time.Millisecond
+	return cli, server
+}
+
+func TestSonarr_RejectsGrab(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesAudio: []string{"jpn"}, RejectGrabs: true})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(sonarrGrabPayload)))
+
+	deletes := server.RequestsTo(http.MethodDelete, "/api/v3/queue/1")
+	require.Len(t, deletes, 1)
+	query, err := url.ParseQuery(deletes[0].Query)
+	require.NoError(t, err)
+	assert.Equal(t, "true", query.Get("blocklist"))
+	assert.Equal(t, "true", query.Get("removeFromClient"))
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/queue/2"), 1)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/queue/3"))
+
+	searches := server.RequestsTo(http.MethodPost, "/api/v3/command")
+	require.Len(t, searches, 1)
+	assert.JSONEq(t, `{"name": "EpisodeSearch", "episodeIds": [13947, 13948]}`, string(searches[0].Body))
+}
+
+func TestSonarr_AcceptsGrabOfNewEpisode(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesAudio: []string{"jpn"}, RejectGrabs: true, EnforceAfter: 14 * 24 * time.Hour})
+	server.JSON("GET /api/v3/episode/13947", http.StatusOK, map[string]any{"id": 13947, "airDateUtc": time.Now().Add(-30 * 24 * time.Hour)})
+	server.JSON("GET /api/v3/episode/13948", http.StatusOK, map[string]any{"id": 13948, "airDateUtc": time.Now().Add(-24 * time.Hour)})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(sonarrGrabPayload)))
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/queue/1"), "the newest episode aired a day ago")
+	assert.Empty(t, cli.tracker.store.Keys())
+}
+
+func TestSonarr_GrabCheckDisabled(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesAudio: []string{"jpn"}})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(sonarrGrabPayload)))
+
+	assert.Empty(t, server.Requests())
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	grabInspectInterval = 30 * time.Second
+	// trackedGrabTTL is how long a grab is inspected before warden gives up on it
+	trackedGrabTTL = 6 * time.Hour
+)
+
+// videoExtensions are the files inspected in a download, samples and extras share them but are named like the release
+var videoExtensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".wmv", ".mov", ".webm"}
+
+// TrackedGrab is a grabbed release whose title was inconclusive,
+// its files are inspected in the download client until they are known
+type TrackedGrab struct {
+	Instance           string           `json:"instance"`
+	DownloadID         string           `json:"download_id"`
+	DownloadClient     string           `json:"download_client"`
+	DownloadClientType string           `json:"download_client_type"`
+	MediaIDs           []int            `json:"media_ids"`
+	ReleaseTitle       string           `json:"release_title"`
+	Release            *ReleaseCriteria `json:"release"`
+	Created            time.Time        `json:"created"`
+}
+
+func (g *TrackedGrab) Key() string {
+	return g.Instance + ":" + strings.ToLower(g.DownloadID)
+}
+
+// grabTracker inspects the files of tracked grabs and rejects the ones that cannot satisfy their profile
+type grabTracker struct {
+	instance string
+	store    *jsonStore[TrackedGrab]
+	// clients are keyed by the lowercase name of the download client in the *arr app
+	clients map[string]DownloadClient
+	types   map[string]DownloadClientType
+	// reject removes the download from the *arr queue with blocklisting and searches again
+	reject func(context.Context, *GrabInfo) error
+}
+
+func newGrabTracker(inst *ArrInstance, reject func(context.Context, *GrabInfo) error) *grabTracker {
+	t := &grabTracker{
+		instance: inst.name,
+		store:    inst.state.Grabs,
+		clients:  map[string]DownloadClient{},
+		types:    map[string]DownloadClientType{},
+		reject:   reject,
+	}
+	for name, conf := range inst.DownloadClients {
+		if conf == nil {
+			continue
+		}
+		client, err := newDownloadClient(conf)
+		if err != nil {
+			log.Error().Err(err).Msgf("Ignoring download client %s", name)
+			continue
+		}
+		t.clients[strings.ToLower(name)] = client
+		t.types[strings.ToLower(name)] = strings.ToLower(conf.Type)
+	}
+	return t
+}
+
+// client finds the download client by its name in the *arr app, falling back to the only client of the same type
+func (t *grabTracker) client(name, clientType string) (DownloadClient, bool) {
+	if client, ok := t.clients[strings.ToLower(name)]; ok {
+		return client, true
+	}
+
+	var match DownloadClient
+	for key, typ := range t.types {
+		if typ != strings.ToLower(clientType) {
+			continue
+		}
+		if match != nil {
+			return nil, false
+		}
+		match = t.clients[key]
+	}
+	return match, match != nil
+}
+
+// Track starts inspecting the grab, returns false if its download client is not configured
+func (t *grabTracker) Track(info *GrabInfo, prof *Profile) bool {
+	if _, ok := t.client(info.DownloadClient, info.DownloadClientType); !ok {
+		return false
+	}
+
+	grab := TrackedGrab{
+		Instance:           t.instance,
+		DownloadID:         info.DownloadID,
+		DownloadClient:     info.DownloadClient,
+		DownloadClientType: info.DownloadClientType,
+		MediaIDs:           info.MediaIDs,
+		ReleaseTitle:       info.ReleaseTitle,
+		Release:            newReleaseCriteria(prof, info.OriginalLanguage),
+		Created:            time.Now(),
+	}
+	t.store.Put(grab.Key(), grab)
+	log.Debug().Str("release", info.ReleaseTitle).Msg("Tracking grab until its files are known")
+	return true
+}
+
+// Untrack stops inspecting the download, e.g. once it has been imported
+func (t *grabTracker) Untrack(downloadID string) {
+	if downloadID == "" {
+		return
+	}
+	t.store.Delete(t.instance + ":" + strings.ToLower(downloadID))
+}
+
+func (t *grabTracker) tracked() []TrackedGrab {
+	var res []TrackedGrab
+	for _, key := range t.store.Keys() {
+		grab, ok := t.store.Get(key)
+		if ok && grab.Instance == t.instance {
+			res = append(res, grab)
+		}
+	}
+	return res
+}
+
+// inspectAll inspects every tracked grab once
+func (t *grabTracker) inspectAll(ctx context.Context) {
+	for _, grab := range t.tracked() {
+		if done := t.inspect(ctx, grab); done {
+			t.store.Delete(grab.Key())
+		}
+	}
+}
+
+// inspect checks the files of the download, returns true once the grab no longer needs tracking
+func (t *grabTracker) inspect(ctx context.Context, grab TrackedGrab) bool {
+	if time.Since(grab.Created) > trackedGrabTTL {
+		log.Debug().Str("release", grab.ReleaseTitle).Msg("Giving up on grab, its files never became known")
+		return true
+	}
+	if grab.Release == nil {
+		log.Warn().Str("release", grab.ReleaseTitle).Msg("Dropping tracked grab without release criteria")
+		return true
+	}
+
+	client, ok := t.client(grab.DownloadClient, grab.DownloadClientType)
+	if !ok {
+		return true
+	}
+
+	files, err := client.Files(ctx, grab.DownloadID)
+	if errors.Is(err, errDownloadGone) {
+		return true
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("release", grab.ReleaseTitle).Msg("Unable to list download files")
+		return false
+	}
+	if len(files) == 0 {
+		// torrents have no file list until the metadata is downloaded
+		return false
+	}
+
+	prof := &Profile{RequiredLanguagesAudio: grab.Release.RequiredLanguagesAudio}
+	for _, file := range files {
+		if !slices.Contains(videoExtensions, strings.ToLower(path.Ext(file))) {
+			continue
+		}
+		reason, reject := prof.grabViolation(file, nil)
+		if !reject {
+			continue
+		}
+
+		log.Info().Str("release", grab.ReleaseTitle).Str("file", file).Msgf("Rejecting download, %s", reason)
+		t.cancel(ctx, grab, client)
+		return true
+	}
+
+	log.Debug().Str("release", grab.ReleaseTitle).Msg("Download files may satisfy the profile")
+	return true
+}
+
+// cancel rejects the download through the *arr app so the release is blocklisted and searched again,
+// if the app no longer knows it the download is only removed from the client directly
+func (t *grabTracker) cancel(ctx context.Context, grab TrackedGrab, client DownloadClient) {
+	err := t.reject(ctx, &GrabInfo{MediaIDs: grab.MediaIDs, DownloadID: grab.DownloadID, ReleaseTitle: grab.ReleaseTitle})
+	if err == nil {
+		return
+	}
+
+	log.Warn().Err(err).Msg("Unable to reject download through the queue, removing it from the download client")
+	if err = client.Remove(ctx, grab.DownloadID); err != nil {
+		log.Error().Err(err).Msg("Unable to remove download")
+		return
+	}
+	log.Warn().
+		Str("release", grab.ReleaseTitle).
+		Ints("ids", grab.MediaIDs).
+		Msg("Removed download from the client without blocklisting the release or searching again, it may be grabbed again")
+}
+
+// watch inspects tracked grabs every grabInspectInterval until ctx is cancelled
+func (t *grabTracker) watch(ctx context.Context) {
+	if len(t.clients) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(grabInspectInterval)
+	defer ticker.Stop()
+
+	for {
+		t.inspectAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
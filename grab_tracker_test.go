@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/RA341/warden/api/sonarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inconclusiveGrabPayload = `{
+  "eventType": "Grab",
+  "series": {"id": 118, "path": "/media/anime/Show", "tvdbId": 433563},
+  "episodes": [{"id": 13947}],
+  "release": {"releaseTitle": "Show.S01E01.1080p.WEB.h264-GROUP"},
+  "downloadClient": "qBittorrent",
+  "downloadClientType": "qBittorrent",
+  "downloadId": "9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3"
+}`
+
+// fakeDownloadClient serves a fixed file list per download
+type fakeDownloadClient struct {
+	mu      sync.Mutex
+	files   map[string][]string
+	removed []string
+}
+
+func (f *fakeDownloadClient) Files(_ context.Context, downloadID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	files, ok := f.files[downloadID]
+	if !ok {
+		return nil, errDownloadGone
+	}
+	return files, nil
+}
+
+func (f *fakeDownloadClient) Remove(_ context.Context, downloadID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removed = append(f.removed, downloadID)
+	return nil
+}
+
+func newTrackingSonarr(t *testing.T, queue []sonarr.QueueRecord, files []string) (*SonarrInst, *arrtest.Server, *fakeDownloadClient) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/queue", http.StatusOK, arr.Page[sonarr.QueueRecord]{
+		Page: 1, PageSize: 200, TotalRecords: len(queue), Records: queue,
+	})
+	server.JSON("DELETE /api/v3/queue/{id}", http.StatusOK, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {RequiredLanguagesAudio: []string{"jpn"}, RejectGrabs: true},
+		},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.queueDelay = time.Millisecond
+
+	client := &fakeDownloadClient{files: map[string][]string{
+		"9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3": files,
+	}}
+	cli.tracker.clients["qbittorrent"] = client
+	cli.tracker.types["qbittorrent"] = QBITTORRENT
+	return cli, server, client
+}
+
+func TestGrabTracker_RejectsDownloadFiles(t *testing.T) {
+	queue := []sonarr.QueueRecord{{ID: 1, EpisodeID: 13947, DownloadID: "9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3"}}
+	cli, server, client := newTrackingSonarr(t, queue, []string{
+		"Show.S01E01.1080p.WEB.h264-GROUP/Show.S01E01.GERMAN.1080p.WEB.h264-GROUP.mkv",
+		"Show.S01E01.1080p.WEB.h264-GROUP/Show.S01E01.GERMAN.1080p.WEB.h264-GROUP.nfo",
+	})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(inconclusiveGrabPayload)))
+	require.Len(t, cli.tracker.tracked(), 1)
+	assert.Empty(t, server.Requests())
+
+	cli.tracker.inspectAll(context.Background())
+
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/queue/1"), 1)
+	searches := server.RequestsTo(http.MethodPost, "/api/v3/command")
+	require.Len(t, searches, 1)
+	assert.JSONEq(t, `{"name": "EpisodeSearch", "episodeIds": [13947]}`, string(searches[0].Body))
+	assert.Empty(t, client.removed)
+	assert.Empty(t, cli.tracker.tracked())
+}
+
+func TestGrabTracker_WaitsForFiles(t *testing.T) {
+	cli, server, _ := newTrackingSonarr(t, nil, nil)
+
+	require.NoError(t, cli.ProcessWebhook([]byte(inconclusiveGrabPayload)))
+	cli.tracker.inspectAll(context.Background())
+
+	assert.Len(t, cli.tracker.tracked(), 1)
+	assert.Empty(t, server.Requests())
+
+	cli.tracker.Untrack("9f3b1c7a2e4d6f8091a3b5c7d9e1f3a5b7c9d1e3")
+	assert.Empty(t, cli.tracker.tracked())
+}
+
+func TestGrabTracker_AcceptsMatchingFiles(t *testing.T) {
+	cli, server, _ := newTrackingSonarr(t, nil, []string{"Show.S01E01.JAPANESE.1080p.WEB.h264-GROUP.mkv"})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(inconclusiveGrabPayload)))
+	cli.tracker.inspectAll(context.Background())
+
+	assert.Empty(t, cli.tracker.tracked())
+	assert.Empty(t, server.Requests())
+}
+
+func TestGrabTracker_RemovesFromClientWhenNotQueued(t *testing.T) {
+	cli, _, client := newTrackingSonarr(t, nil, []string{"Show.S01E01.GERMAN.1080p.WEB.h264-GROUP.mkv"})
+
+	require.NoError(t, cli.ProcessWebhook([]byte(inconclusiveGrabPayload)))
+	cli.tracker.inspectAll(context.Background())
+
+	assert.Equal(t, []string{"9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3"}, client.removed)
+	assert.Empty(t, cli.tracker.tracked())
+}
+
+func TestGrabTracker_DropsGrabWithoutRelease(t *testing.T) {
+	cli, server, client := newTrackingSonarr(t, nil, []string{"Show.S01E01.GERMAN.1080p.WEB.h264-GROUP.mkv"})
+	grab := TrackedGrab{
+		Instance:       cli.tracker.instance,
+		DownloadID:     "9F3B1C7A2E4D6F8091A3B5C7D9E1F3A5B7C9D1E3",
+		DownloadClient: "qBittorrent",
+		MediaIDs:       []int{13947},
+		Created:        time.Now(),
+	}
+	cli.tracker.store.Put(grab.Key(), grab)
+
+	cli.tracker.inspectAll(context.Background())
+	assert.Empty(t, cli.tracker.tracked())
+	assert.Empty(t, server.Requests())
+	assert.Empty(t, client.removed)
+}
+
+func TestGrabTracker_ClientByType(t *testing.T) {
+	tracker := newGrabTracker(&ArrInstance{
+		state: newMemoryState(),
+		DownloadClients: map[string]*DownloadClientConfig{
+			"torrents": {Type: QBITTORRENT, URL: "http://localhost:8080"},
+			"usenet":   {Type: SABNZBD, URL: "http://localhost:8085"},
+		},
+	}, nil)
+
+	_, ok := tracker.client("Torrents", "")
+	assert.True(t, ok)
+	_, ok = tracker.client("qBit", "QBittorrent")
+	assert.True(t, ok)
+	_, ok = tracker.client("nzbget", "NzbGet")
+	assert.False(t, ok)
+}
@@ -0,0 +1,153 @@
+// Package lang normalizes the language codes and names used by the *arr apps,
+// release names and profiles to ISO 639-2/B codes
+package lang
+
+import (
+	"strings"
+)
+
+// Language describes a single language, Code is the ISO 639-2/B code used by the *arr media info
+type Language struct {
+	Code string
+	// Alpha2 is the ISO 639-1 code if the language has one
+	Alpha2 string
+	// Alpha3T is the ISO 639-2/T code when it differs from Code
+	Alpha3T string
+	// Names are lowercase english names, the first one is the display name used by the *arr apps
+	Names []string
+}
+
+// Undetermined is used by the *arr apps when a stream has no language tag
+const Undetermined = "und"
+
+var languages = []Language{
+	{Code: "eng", Alpha2: "en", Names: []string{"english"}},
+	{Code: "jpn", Alpha2: "ja", Names: []string{"japanese"}},
+	{Code: "fre", Alpha2: "fr", Alpha3T: "fra", Names: []string{"french"}},
+	{Code: "ger", Alpha2: "de", Alpha3T: "deu", Names: []string{"german"}},
+	{Code: "spa", Alpha2: "es", Names: []string{"spanish", "spanish (latino)", "castilian", "latino"}},
+	{Code: "ita", Alpha2: "it", Names: []string{"italian"}},
+	{Code: "por", Alpha2: "pt", Names: []string{"portuguese", "portuguese (brazil)", "brazilian"}},
+	{Code: "rus", Alpha2: "ru", Names: []string{"russian"}},
+	{Code: "kor", Alpha2: "ko", Names: []string{"korean"}},
+	{Code: "chi", Alpha2: "zh", Alpha3T: "zho", Names: []string{"chinese", "mandarin", "cantonese"}},
+	{Code: "ara", Alpha2: "ar", Names: []string{"arabic"}},
+	{Code: "hin", Alpha2: "hi", Names: []string{"hindi"}},
+	{Code: "dut", Alpha2: "nl", Alpha3T: "nld", Names: []string{"dutch", "flemish"}},
+	{Code: "swe", Alpha2: "sv", Names: []string{"swedish"}},
+	{Code: "nor", Alpha2: "no", Names: []string{"norwegian", "norwegian bokmal"}},
+	{Code: "nob", Alpha2: "nb", Names: []string{"bokmal"}},
+	{Code: "dan", Alpha2: "da", Names: []string{"danish"}},
+	{Code: "fin", Alpha2: "fi", Names: []string{"finnish"}},
+	{Code: "ice", Alpha2: "is", Alpha3T: "isl", Names: []string{"icelandic"}},
+	{Code: "pol", Alpha2: "pl", Names: []string{"polish"}},
+	{Code: "cze", Alpha2: "cs", Alpha3T: "ces", Names: []string{"czech"}},
+	{Code: "slo", Alpha2: "sk", Alpha3T: "slk", Names: []string{"slovak"}},
+	{Code: "slv", Alpha2: "sl", Names: []string{"slovenian", "slovene"}},
+	{Code: "hun", Alpha2: "hu", Names: []string{"hungarian"}},
+	{Code: "rum", Alpha2: "ro", Alpha3T: "ron", Names: []string{"romanian"}},
+	{Code: "bul", Alpha2: "bg", Names: []string{"bulgarian"}},
+	{Code: "gre", Alpha2: "el", Alpha3T: "ell", Names: []string{"greek"}},
+	{Code: "tur", Alpha2: "tr", Names: []string{"turkish"}},
+	{Code: "heb", Alpha2: "he", Names: []string{"hebrew"}},
+	{Code: "per", Alpha2: "fa", Alpha3T: "fas", Names: []string{"persian", "farsi"}},
+	{Code: "ukr", Alpha2: "uk", Names: []string{"ukrainian"}},
+	{Code: "hrv", Alpha2: "hr", Names: []string{"croatian"}},
+	{Code: "srp", Alpha2: "sr", Names: []string{"serbian"}},
+	{Code: "bos", Alpha2: "bs", Names: []string{"bosnian"}},
+	{Code: "mac", Alpha2: "mk", Alpha3T: "mkd", Names: []string{"macedonian"}},
+	{Code: "alb", Alpha2: "sq", Alpha3T: "sqi", Names: []string{"albanian"}},
+	{Code: "lit", Alpha2: "lt", Names: []string{"lithuanian"}},
+	{Code: "lav", Alpha2: "lv", Names: []string{"latvian"}},
+	{Code: "est", Alpha2: "et", Names: []string{"estonian"}},
+	{Code: "cat", Alpha2: "ca", Names: []string{"catalan"}},
+	{Code: "baq", Alpha2: "eu", Alpha3T: "eus", Names: []string{"basque"}},
+	{Code: "glg", Alpha2: "gl", Names: []string{"galician"}},
+	{Code: "tha", Alpha2: "th", Names: []string{"thai"}},
+	{Code: "vie", Alpha2: "vi", Names: []string{"vietnamese"}},
+	{Code: "ind", Alpha2: "id", Names: []string{"indonesian"}},
+	{Code: "may", Alpha2: "ms", Alpha3T: "msa", Names: []string{"malay"}},
+	{Code: "tgl", Alpha2: "tl", Names: []string{"tagalog", "filipino"}},
+	{Code: "tam", Alpha2: "ta", Names: []string{"tamil"}},
+	{Code: "tel", Alpha2: "te", Names: []string{"telugu"}},
+	{Code: "kan", Alpha2: "kn", Names: []string{"kannada"}},
+	{Code: "mal", Alpha2: "ml", Names: []string{"malayalam"}},
+	{Code: "ben", Alpha2: "bn", Names: []string{"bengali", "bangla"}},
+	{Code: "mar", Alpha2: "mr", Names: []string{"marathi"}},
+	{Code: "urd", Alpha2: "ur", Names: []string{"urdu"}},
+	{Code: "pan", Alpha2: "pa", Names: []string{"punjabi"}},
+	{Code: "geo", Alpha2: "ka", Alpha3T: "kat", Names: []string{"georgian"}},
+	{Code: "arm", Alpha2: "hy", Alpha3T: "hye", Names: []string{"armenian"}},
+	{Code: "aze", Alpha2: "az", Names: []string{"azerbaijani"}},
+	{Code: "kaz", Alpha2: "kk", Names: []string{"kazakh"}},
+	{Code: "uzb", Alpha2: "uz", Names: []string{"uzbek"}},
+	{Code: "mon", Alpha2: "mn", Names: []string{"mongolian"}},
+	{Code: "afr", Alpha2: "af", Names: []string{"afrikaans"}},
+	{Code: "swa", Alpha2: "sw", Names: []string{"swahili"}},
+	{Code: "wel", Alpha2: "cy", Alpha3T: "cym", Names: []string{"welsh"}},
+	{Code: "gle", Alpha2: "ga", Names: []string{"irish"}},
+	{Code: "lat", Alpha2: "la", Names: []string{"latin"}},
+	{Code: Undetermined, Names: []string{"unknown", "undetermined"}},
+}
+
+var index = buildIndex()
+
+func buildIndex() map[string]*Language {
+	idx := map[string]*Language{}
+	for i := range languages {
+		l := &languages[i]
+		idx[l.Code] = l
+		if l.Alpha2 != "" {
+			idx[l.Alpha2] = l
+		}
+		if l.Alpha3T != "" {
+			idx[l.Alpha3T] = l
+		}
+		for _, name := range l.Names {
+			idx[name] = l
+		}
+	}
+	return idx
+}
+
+// Lookup finds a language by ISO 639-1, ISO 639-2/B or /T code or english name, case-insensitively
+func Lookup(val string) (Language, bool) {
+	l, ok := index[strings.ToLower(strings.TrimSpace(val))]
+	if !ok {
+		return Language{}, false
+	}
+	return *l, true
+}
+
+// Normalize returns the ISO 639-2/B code for val, unknown values are returned trimmed and lowercased
+func Normalize(val string) string {
+	if l, ok := Lookup(val); ok {
+		return l.Code
+	}
+	return strings.ToLower(strings.TrimSpace(val))
+}
+
+// NormalizeAll normalizes every value, dropping empty ones and duplicates while keeping the order
+func NormalizeAll(vals []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, val := range vals {
+		code := Normalize(val)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		res = append(res, code)
+	}
+	return res
+}
+
+// Name returns the display name of a language code, the code itself if it is unknown
+func Name(code string) string {
+	l, ok := Lookup(code)
+	if !ok {
+		return code
+	}
+	name := l.Names[0]
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,39 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"eng":                 "eng",
+		"en":                  "eng",
+		"English":             "eng",
+		" JPN ":               "jpn",
+		"deu":                 "ger",
+		"de":                  "ger",
+		"fra":                 "fre",
+		"Portuguese (Brazil)": "por",
+		"Spanish (Latino)":    "spa",
+		"zho":                 "chi",
+		"Unknown":             "und",
+		"klingon":             "klingon",
+		"":                    "",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Normalize(in), in)
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	assert.Equal(t, []string{"eng", "jpn"}, NormalizeAll([]string{"en", "English", "", "jpn", "eng"}))
+	assert.Nil(t, NormalizeAll(nil))
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "Japanese", Name("jpn"))
+	assert.Equal(t, "German", Name("de"))
+	assert.Equal(t, "xx", Name("xx"))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/RA341/warden/lang"
+)
+
+// languageList decodes the different shapes languages arrive in depending on the *arr version:
+// an array of codes, a single slash delimited string like "eng/jpn",
+// or {id,name} language objects either alone or in an array.
+// Decoded languages are normalized to ISO 639-2/B codes.
+type languageList []string
+
+type languageObject struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (l *languageList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*l = nil
+		return nil
+	}
+
+	var items []json.RawMessage
+	switch data[0] {
+	case '[':
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("invalid language list: %w", err)
+		}
+	default:
+		items = []json.RawMessage{data}
+	}
+
+	res := languageList{}
+	for _, item := range items {
+		langs, err := decodeLanguage(item)
+		if err != nil {
+			return err
+		}
+		res = append(res, langs...)
+	}
+
+	*l = lang.NormalizeAll(res)
+	if *l == nil {
+		*l = languageList{}
+	}
+	return nil
+}
+
+func decodeLanguage(item json.RawMessage) ([]string, error) {
+	var str string
+	if err := json.Unmarshal(item, &str); err == nil {
+		return splitLanguages(str), nil
+	}
+
+	var obj languageObject
+	if err := json.Unmarshal(item, &obj); err != nil {
+		return nil, fmt.Errorf("invalid language %s: %w", item, err)
+	}
+	if obj.Name == "" {
+		return nil, nil
+	}
+	return []string{obj.Name}, nil
+}
+
+// splitLanguages splits the slash delimited language list used by the *arr apps e.g. "eng/jpn",
+// the result is normalized to ISO 639-2/B codes
+func splitLanguages(val string) []string {
+	var res []string
+	for _, l := range strings.Split(val, "/") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			res = append(res, l)
+		}
+	}
+	return lang.NormalizeAll(res)
+}
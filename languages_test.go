@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageList_Unmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want languageList
+	}{
+		{"null", `null`, nil},
+		{"empty array", `[]`, languageList{}},
+		{"empty string", `""`, languageList{}},
+		{"codes", `["eng", "jpn"]`, languageList{"eng", "jpn"}},
+		{"slash delimited", `"eng/jpn"`, languageList{"eng", "jpn"}},
+		{"slash delimited with spaces", `"English / Japanese"`, languageList{"eng", "jpn"}},
+		{"slash delimited in array", `["eng/jpn", "ger"]`, languageList{"eng", "jpn", "ger"}},
+		{"alpha2 and terminologic codes", `["en", "deu", "fra"]`, languageList{"eng", "ger", "fre"}},
+		{"duplicates", `["eng", "English", "en"]`, languageList{"eng"}},
+		{"single object", `{"id": 8, "name": "Japanese"}`, languageList{"jpn"}},
+		{"objects", `[{"id": 1, "name": "English"}, {"id": 4, "name": "German"}]`, languageList{"eng", "ger"}},
+		{"object without name", `[{"id": 0}]`, languageList{}},
+		{"mixed", `["eng", {"id": 8, "name": "Japanese"}]`, languageList{"eng", "jpn"}},
+		{"unknown kept lowercase", `["Klingon"]`, languageList{"klingon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got languageList
+			require.NoError(t, json.Unmarshal([]byte(tt.json), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLanguageList_UnmarshalInvalid(t *testing.T) {
+	for _, data := range []string{`42`, `[true]`, `{"id": "x"}`} {
+		var got languageList
+		assert.Error(t, json.Unmarshal([]byte(data), &got), data)
+	}
+}
@@ -2,16 +2,30 @@ package main
 
 import (
 	"fmt"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"io"
 	"net/http"
+	"os"
 )
 
 const (
 	targetHeader = "warden-key"
+	// apiKeyHeader carries the api key of the instance on requests that change its files
+	apiKeyHeader = "X-Api-Key"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "quarantine" {
+		// only problems are logged, the output is the command result
+		log.Logger = ConsoleLogger().Level(zerolog.WarnLevel)
+		if err := runQuarantineCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	printInfo()
 	log.Logger = ConsoleLogger()
 	fileType := parseConfigType()
@@ -19,6 +33,7 @@ func main() {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/webhook", handlePayload(pm))
+	registerQuarantineRoutes(mux, pm)
 
 	port := "8080"
 	addr := fmt.Sprintf(":%s", port)
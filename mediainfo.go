@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/tracks"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	mediaInfoAttempts     = 4
+	defaultMediaInfoDelay = 15 * time.Second
+)
+
+var errMediaInfoPending = errors.New("media info analysis is still pending")
+
+// isAnalysed reports whether the *arr app has finished reading the streams of a file,
+// freshly imported files have no media info until the analysis completes.
+// Any video or stream field counts, a file without audio is analysed too
+func isAnalysed(info *arr.MediaInfo) bool {
+	return info != nil && *info != (arr.MediaInfo{})
+}
+
+// fetchAnalysedMediaInfo polls fetch until the file has been analysed, waiting delay between attempts
+func fetchAnalysedMediaInfo(ctx context.Context, delay time.Duration, fetch func(context.Context) (*arr.MediaInfo, error)) (*arr.MediaInfo, error) {
+	for attempt := 1; ; attempt++ {
+		info, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if isAnalysed(info) {
+			return info, nil
+		}
+		if attempt >= mediaInfoAttempts {
+			return nil, errMediaInfoPending
+		}
+
+		log.Debug().Msgf("media info is not analysed yet, retrying in %s", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// applyAuthoritative replaces the webhook languages with the ones from the api, logging any difference
+func applyAuthoritative(info *arr.MediaInfo, audios, subs *[]string) {
+	apiAudios := splitLanguages(info.AudioLanguages)
+	apiSubs := splitLanguages(info.Subtitles)
+
+	if !slices.Equal(apiAudios, *audios) || !slices.Equal(apiSubs, *subs) {
+		log.Warn().
+			Strs("webhookAudio", *audios).
+			Strs("apiAudio", apiAudios).
+			Strs("webhookSubs", *subs).
+			Strs("apiSubs", apiSubs).
+			Msg("Webhook media info differs from the api, using the api")
+	}
+
+	*audios = apiAudios
+	*subs = apiSubs
+}
+
+// mediaInfoError describes why the authoritative media info could not be loaded
+func mediaInfoError(kind, fileID string, err error) error {
+	if arr.IsNotFound(err) {
+		return fmt.Errorf("%s %s no longer exists, it was probably replaced: %w", kind, fileID, err)
+	}
+	return fmt.Errorf("unable to load media info of %s %s: %w", kind, fileID, err)
+}
+
+// readFileTracks replaces the webhook languages with the ones of the tracks in the file
+func readFileTracks(path string, audios, subs *[]string) ([]tracks.Track, error) {
+	if path == "" {
+		return nil, errors.New("webhook has no file path")
+	}
+	all, err := tracks.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileAudios := lang.NormalizeAll(tracks.Languages(all, tracks.Audio))
+	fileSubs := lang.NormalizeAll(tracks.Languages(all, tracks.Subtitle))
+	if !slices.Equal(fileAudios, lang.NormalizeAll(*audios)) || !slices.Equal(fileSubs, lang.NormalizeAll(*subs)) {
+		log.Debug().
+			Strs("webhookAudio", *audios).
+			Strs("fileAudio", fileAudios).
+			Strs("webhookSubs", *subs).
+			Strs("fileSubs", fileSubs).
+			Msg("Webhook media info differs from the file tracks, using the file")
+	}
+
+	*audios = fileAudios
+	*subs = fileSubs
+	return all, nil
+}
+
+// webhookFilePath returns the full path of the imported file, older webhooks only send it relative to the media folder
+func webhookFilePath(folder, path, relativePath string) string {
+	if path == "" && relativePath != "" && folder != "" {
+		path = filepath.Join(folder, relativePath)
+	}
+	return filepath.ToSlash(path)
+}
+
+// readSidecars lists the subtitle files next to the media file, a directory that cannot be read counts as none
+func readSidecars(path string) []tracks.Track {
+	if path == "" {
+		return nil
+	}
+	sidecars, err := tracks.Sidecars(path)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to list sidecar subtitles")
+		return nil
+	}
+	if len(sidecars) > 0 {
+		log.Debug().Strs("subs", tracks.Languages(sidecars, tracks.Subtitle)).Msg("Found sidecar subtitles")
+	}
+	return sidecars
+}
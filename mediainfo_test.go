@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLanguages(t *testing.T) {
+	assert.Equal(t, []string{"eng", "jpn"}, splitLanguages("eng/jpn"))
+	assert.Equal(t, []string{"eng", "jpn"}, splitLanguages(" eng / jpn /"))
+	assert.Nil(t, splitLanguages(""))
+}
+
+func TestSonarr_UsesAuthoritativeMediaInfo(t *testing.T) {
+	server := arrtest.NewServer(t)
+	var calls atomic.Int32
+	server.Handle("GET /api/v3/episodefile/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// the first response is from before sonarr analysed the file
+		if calls.Add(1) == 1 {
+			arrtest.WriteJSON(w, http.StatusOK, map[string]any{"id": 11729, "mediaInfo": nil})
+			return
+		}
+		arrtest.WriteJSON(w, http.StatusOK, map[string]any{
+			"id": 11729,
+			"mediaInfo": arr.MediaInfo{
+				AudioLanguages:   "jpn/eng",
+				AudioStreamCount: 2,
+				Subtitles:        "eng",
+			},
+		})
+	})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {RequiredLanguagesAudio: []string{"eng"}, RequiredLanguagesSubs: []string{"eng"}},
+		},
+	})
+	cli.mediaInfoDelay = time.Millisecond
+
+	info := &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		Audios:        []string{"jpn"},
+	}
+	cli.RunCheck(context.Background(), info)
+
+	assert.Equal(t, []string{"jpn", "eng"}, info.Audios)
+	assert.Equal(t, []string{"eng"}, info.Subtitles)
+	assert.EqualValues(t, 2, calls.Load())
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), "compliant file must not be deleted")
+}
+
+func TestFetchAnalysedMediaInfo_Pending(t *testing.T) {
+	var calls int
+	_, err := fetchAnalysedMediaInfo(context.Background(), time.Millisecond, func(ctx context.Context) (*arr.MediaInfo, error) {
+		calls++
+		return &arr.MediaInfo{}, nil
+	})
+
+	require.ErrorIs(t, err, errMediaInfoPending)
+	assert.Equal(t, mediaInfoAttempts, calls)
+}
+
+func TestSonarr_RemediatesFileWithoutAudio(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{VideoCodec: "x265", Resolution: "1920x1080", RunTime: "23:40"},
+	})
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {RequiredLanguagesAudio: []string{"jpn"}},
+		},
+	})
+	cli.mediaInfoDelay = time.Millisecond
+
+	cli.RunCheck(context.Background(), &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+	})
+
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/episodefile/11729"), 1, "a file without audio is analysed")
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+}
+
+func TestSonarr_InspectsFileTracks(t *testing.T) {
+	server := arrtest.NewServer(t)
+	cli := NewSonarr(&ArrInstance{
+		BasePath:     server.URL,
+		ApiKey:       arrtest.APIKey,
+		InspectFiles: true,
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {RequiredLanguagesAudio: []string{"jpn", "eng"}, RequiredLanguagesSubs: []string{"spa"}},
+		},
+	})
+
+	// testdata/tracks.mkv has japanese and english audio, english and spanish subtitles
+	info := &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		FilePath:      "testdata/tracks.mkv",
+		Audios:        []string{"jpn"},
+	}
+	cli.RunCheck(context.Background(), info)
+
+	assert.Equal(t, []string{"jpn", "eng"}, info.Audios)
+	assert.Equal(t, []string{"eng", "spa"}, info.Subtitles)
+	assert.Len(t, info.Tracks, 5)
+	assert.Empty(t, server.Requests(), "media info must not be loaded from the api")
+}
+
+func TestSonarr_InspectFallsBackToMediaInfo(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{AudioLanguages: "jpn", AudioStreamCount: 1, Subtitles: "eng"},
+	})
+	cli := NewSonarr(&ArrInstance{
+		BasePath:     server.URL,
+		ApiKey:       arrtest.APIKey,
+		InspectFiles: true,
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {RequiredLanguagesAudio: []string{"jpn"}},
+		},
+	})
+
+	info := &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		FilePath:      "testdata/missing.mkv",
+	}
+	cli.RunCheck(context.Background(), info)
+
+	assert.Equal(t, []string{"jpn"}, info.Audios)
+	assert.Nil(t, info.Tracks)
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/episodefile/11729"), 1)
+}
+
+func TestWebhookFilePath(t *testing.T) {
+	assert.Equal(t, "/tv/Show/Season 01/ep.mkv", webhookFilePath("/tv/Show", "/tv/Show/Season 01/ep.mkv", "Season 01/ep.mkv"))
+	assert.Equal(t, "/tv/Show/Season 01/ep.mkv", webhookFilePath("/tv/Show", "", "Season 01/ep.mkv"))
+	assert.Empty(t, webhookFilePath("/tv/Show", "", ""))
+}
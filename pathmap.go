@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// PathMapping translates a path prefix reported by the *arr app to where warden sees the same directory,
+// e.g. sonarr reports /tv while warden mounts the library at /media/shows
+type PathMapping struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+}
+
+// PathMappings are the mappings of an instance, the longest matching prefix wins
+type PathMappings []PathMapping
+
+// ToLocal translates a path reported by the *arr app, paths without a matching mapping are returned as is
+func (m PathMappings) ToLocal(p string) string {
+	return m.translate(p, func(pm PathMapping) (string, string) { return pm.Remote, pm.Local })
+}
+
+// ToRemote translates a local path back to the path the *arr app uses
+func (m PathMappings) ToRemote(p string) string {
+	return m.translate(p, func(pm PathMapping) (string, string) { return pm.Local, pm.Remote })
+}
+
+func (m PathMappings) translate(p string, direction func(PathMapping) (from, to string)) string {
+	if p == "" {
+		return p
+	}
+	slashed := toSlash(p)
+
+	best, bestLen := "", -1
+	for _, pm := range m {
+		from, to := direction(pm)
+		from = cleanPrefix(from)
+		if from == "" || len(from) <= bestLen || !hasPathPrefix(slashed, from) {
+			continue
+		}
+		best, bestLen = cleanPrefix(to)+strings.TrimPrefix(slashed, from), len(from)
+	}
+	if bestLen < 0 {
+		return p
+	}
+	if best == "" {
+		return "/"
+	}
+	return best
+}
+
+// cleanPrefix converts a mapping prefix to a slash separated path without a trailing slash, "/" becomes ""
+func cleanPrefix(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path.Clean(toSlash(p)), "/")
+}
+
+// toSlash converts windows separators regardless of the os warden runs on, the *arr app may run on windows
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMappings(t *testing.T) {
+	mappings := PathMappings{
+		{Remote: "/tv", Local: "/media/shows"},
+		{Remote: "/tv/anime/", Local: "/mnt/anime"},
+		{Remote: `D:\Movies`, Local: "/media/movies"},
+		{Remote: "/data", Local: "/"},
+	}
+
+	tests := []struct {
+		remote string
+		local  string
+	}{
+		{"/tv/Show/Season 01/ep.mkv", "/media/shows/Show/Season 01/ep.mkv"},
+		{"/tv", "/media/shows"},
+		{"/tv/anime/Show", "/mnt/anime/Show"},
+		{`D:\Movies\Alien (1979)\Alien.mkv`, "/media/movies/Alien (1979)/Alien.mkv"},
+		{"/data/Show", "/Show"},
+		{"/tvshows/Show", "/tvshows/Show"},
+		{"/other/Show", "/other/Show"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			assert.Equal(t, tt.local, mappings.ToLocal(tt.remote))
+		})
+	}
+
+	assert.Equal(t, "/tv/Show", mappings.ToRemote("/media/shows/Show"))
+	assert.Equal(t, "/tv/anime/Show", mappings.ToRemote("/mnt/anime/Show"))
+}
+
+func TestProfileMatcher_PathMappings(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"/media/shows/anime": {Extends: "local"},
+		"/tv/kids":           {Extends: "remote"},
+	}).WithPathMappings(PathMappings{{Remote: "/tv", Local: "/media/shows"}})
+
+	match, ok := matcher.Match(ProfileQuery{Path: "/tv/anime/Show"})
+	assert.True(t, ok)
+	assert.Equal(t, "local", match.Profile.Extends)
+
+	match, ok = matcher.Match(ProfileQuery{Path: "/tv/kids/Show"})
+	assert.True(t, ok)
+	assert.Equal(t, "remote", match.Profile.Extends)
+
+	_, ok = matcher.Match(ProfileQuery{Path: "/movies/anime/Show"})
+	assert.False(t, ok)
+}
+
+func TestSonarr_InspectsMappedPath(t *testing.T) {
+	server := arrtest.NewServer(t)
+	cli := NewSonarr(&ArrInstance{
+		BasePath:     server.URL,
+		ApiKey:       arrtest.APIKey,
+		InspectFiles: true,
+		PathMappings: PathMappings{{Remote: "/tv/Show/Season 01", Local: "testdata"}},
+		LanguageMap: map[string]*Profile{
+			"./testdata": {RequiredLanguagesAudio: []string{"jpn"}},
+		},
+	})
+
+	info := &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/tv/Show/Season 01",
+		FilePath:      "/tv/Show/Season 01/tracks.mkv",
+	}
+	cli.RunCheck(context.Background(), info)
+
+	assert.Len(t, info.Tracks, 5)
+	assert.Empty(t, server.Requests())
+}
@@ -1,5 +1,12 @@
 package main
 
+import (
+	"time"
+
+	"github.com/RA341/warden/lang"
+	"github.com/rs/zerolog/log"
+)
+
 type InstanceType = string
 
 const (
@@ -7,9 +14,100 @@ const (
 	RADARR InstanceType = "radarr"
 )
 
+type MergeMode = string
+
+const (
+	// MergeReplace uses the profile as is, lower priority matches are ignored
+	MergeReplace MergeMode = "replace"
+	// MergeMerge combines the profile with the next lower priority match
+	MergeMerge MergeMode = "merge"
+)
+
+type SearchMode = string
+
+const (
+	// SearchModeSearch lets the *arr app search for and pick a replacement
+	SearchModeSearch SearchMode = "search"
+	// SearchModeGrab picks the best release that could satisfy the profile and downloads it directly
+	SearchModeGrab SearchMode = "grab"
+)
+
+type DefaultTrackAction = string
+
+const (
+	// DefaultTrackReport logs files with the wrong default track and keeps them
+	DefaultTrackReport DefaultTrackAction = "report"
+	// DefaultTrackReject replaces files with the wrong default track like files missing a language
+	DefaultTrackReject DefaultTrackAction = "reject"
+)
+
 type Profile struct {
+	// Extends names a profile from the top-level profiles section,
+	// any field not set here is inherited from it
+	Extends                string   `json:"extends,omitempty"`
 	RequiredLanguagesAudio []string `json:"required_languages_audio"`
 	RequiredLanguagesSubs  []string `json:"required_languages_sub"`
+	// Priority orders profiles matching the same item, higher is applied first
+	Priority int `json:"priority,omitempty"`
+	// Merge is either replace (default) or merge
+	Merge MergeMode `json:"merge,omitempty"`
+	// Exempt items are never checked or modified
+	Exempt bool `json:"exempt,omitempty"`
+	// PreflightSearch keeps a failing file unless an interactive search finds a release that could satisfy the profile
+	PreflightSearch bool `json:"preflight_search,omitempty"`
+	// SearchMode is either search (default) or grab
+	SearchMode SearchMode `json:"search_mode,omitempty"`
+	// RejectGrabs removes grabbed releases from the queue when their title shows they cannot satisfy the profile
+	RejectGrabs bool `json:"reject_grabs,omitempty"`
+	// RequireFullSubs does not count forced subtitles, they only translate signs, songs and foreign dialogue
+	RequireFullSubs bool `json:"require_full_subs,omitempty"`
+	// IgnoreSdhSubs does not count subtitles for the deaf and hard of hearing
+	IgnoreSdhSubs bool `json:"ignore_sdh_subs,omitempty"`
+	// ExcludeCommentary does not count commentary and audio description tracks as audio languages
+	ExcludeCommentary bool `json:"exclude_commentary,omitempty"`
+	// DefaultAudio are the languages the default audio track may have
+	DefaultAudio []string `json:"default_audio,omitempty"`
+	// DefaultSubs are the languages the default subtitle track may have, "none" allows files without one
+	DefaultSubs []string `json:"default_subs,omitempty"`
+	// DefaultTrackAction is either report (default) or reject
+	DefaultTrackAction DefaultTrackAction `json:"default_track_action,omitempty"`
+	// SubtitleSearch asks bazarr for missing subtitles before replacing a file whose audio satisfies the profile
+	SubtitleSearch bool `json:"subtitle_search,omitempty"`
+	// SubtitleWait is how long bazarr gets before the file is rechecked, defaults to 15m
+	SubtitleWait time.Duration `json:"subtitle_wait,omitempty"`
+	// GracePeriod delays the remediation of a failing file, it is checked again once the period is over
+	GracePeriod time.Duration `json:"grace_period,omitempty"`
+	// EnforceAfter accepts any file until the episode aired or the movie was released this long ago,
+	// a failing file is checked again once the item is old enough
+	EnforceAfter time.Duration `json:"enforce_after,omitempty"`
+	// Remediation are the actions run in order on a file failing the profile, defaults to delete-search
+	Remediation []RemediationAction `json:"remediation,omitempty"`
+
+	// set holds the names of the fields written in the config, only unset fields are inherited or merged.
+	// Profiles built in code have none and count their non zero fields as set
+	set map[string]bool
+}
+
+// Satisfied reports whether the audio and subtitle languages meet the profile requirements,
+// languages are compared by ISO 639-2/B code so "en", "eng" and "English" are equivalent
+func (p *Profile) Satisfied(audios, subs []string) bool {
+	if !isSubset(lang.NormalizeAll(audios), lang.NormalizeAll(p.RequiredLanguagesAudio)) {
+		log.Info().Msgf("Found missing audio languages, \nneed: %v \ngot:%v", p.RequiredLanguagesAudio, audios)
+		return false
+	}
+	if !isSubset(lang.NormalizeAll(subs), lang.NormalizeAll(p.RequiredLanguagesSubs)) {
+		log.Info().Msgf("Found missing subtitles languages, \nneed: %v \ngot: %v", p.RequiredLanguagesSubs, subs)
+		return false
+	}
+	return true
+}
+
+// remediationSteps returns the steps that replace a file failing the profile
+func (p *Profile) remediationSteps() []RemediationStep {
+	if p.SearchMode == SearchModeGrab {
+		return []RemediationStep{StepDelete, StepMonitor, StepGrab}
+	}
+	return []RemediationStep{StepDelete, StepMonitor, StepSearch}
 }
 
 type ArrInstance struct {
@@ -17,7 +115,25 @@ type ArrInstance struct {
 	BasePath    string              `json:"base_path"`
 	ApiKey      string              `json:"api_key"`
 	LanguageMap map[string]*Profile `json:"language_map"`
-	arrClient   ArrClient
+	// DownloadClients are keyed by the name of the download client in the *arr app
+	DownloadClients map[string]*DownloadClientConfig `json:"download_clients,omitempty"`
+	// InspectFiles reads the tracks from the media files instead of the *arr media info, warden needs read access to them
+	InspectFiles bool `json:"inspect_files"`
+	// SidecarSubtitles counts subtitle files next to the media file, e.g. Show - S01E01.en.srt
+	SidecarSubtitles bool `json:"sidecar_subtitles"`
+	// Bazarr is asked for missing subtitles by profiles with subtitle_search
+	Bazarr *BazarrConfig `json:"bazarr,omitempty"`
+	// PathMappings translate the paths reported by the *arr app to the paths warden sees
+	PathMappings PathMappings `json:"path_mappings,omitempty"`
+	// QuarantineDir receives the files of the quarantine remediation action
+	QuarantineDir string `json:"quarantine_dir,omitempty"`
+	// QuarantineRetention purges quarantined files older than it, files are kept until purged by hand when unset
+	QuarantineRetention time.Duration `json:"quarantine_retention,omitempty"`
+	arrClient           ArrClient
+	matcher             *ProfileMatcher
+	// name is the nickname of the instance in the config
+	name  string
+	state *State
 }
 
 // InitClient sets up a ArrClient instance based on the type of inst
@@ -26,12 +142,23 @@ func (ar *ArrInstance) InitClient() {
 	if ar.arrClient == nil {
 		switch ar.InstType {
 		case SONARR:
-			ar.arrClient = NewSonarr(ar.BasePath, ar.ApiKey, func(s string) (*Profile, bool) {
-				val, ok := ar.LanguageMap[s]
-				return val, ok
-			})
+			ar.arrClient = NewSonarr(ar)
 		case RADARR:
-			ar.arrClient = NewRadarr()
+			ar.arrClient = NewRadarr(ar)
 		}
 	}
 }
+
+// withDefaults fills in the runtime fields that are not set when loading the config
+func (ar *ArrInstance) withDefaults() *ArrInstance {
+	if ar.matcher == nil {
+		ar.matcher = NewProfileMatcher(ar.LanguageMap).WithPathMappings(ar.PathMappings)
+	}
+	if ar.state == nil {
+		ar.state = newMemoryState()
+	}
+	if ar.name == "" {
+		ar.name = ar.BasePath
+	}
+	return ar
+}
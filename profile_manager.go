@@ -1,25 +1,32 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type ProfileManager struct {
 	profileMap *Map[string, *ArrInstance]
 	v          *viper.Viper
+	state      *State
 }
 
 func NewProfileManager(profileFileType string) *ProfileManager {
 	v := createViperInstance(profileFileType)
-	profs := loadProfiles(v)
+	state := NewState(defaultStateDir)
+	profs := loadProfiles(v, state)
 	profMan := &ProfileManager{
 		profileMap: profs,
 		v:          v,
+		state:      state,
 	}
+	profMan.startClients()
 
 	v.OnConfigChange(func(e fsnotify.Event) {
 		log.Info().Msgf("config file changed, refershing profiles: %s", e.Name)
@@ -51,8 +58,28 @@ func (pm *ProfileManager) WriteAndSave() {
 
 func (pm *ProfileManager) ReloadProfiles() {
 	log.Debug().Msg("Reloading profiles")
+	pm.stopClients()
 	pm.profileMap.Clear()
-	pm.profileMap = loadProfiles(pm.v)
+	pm.profileMap = loadProfiles(pm.v, pm.state)
+	pm.startClients()
+}
+
+func (pm *ProfileManager) startClients() {
+	pm.profileMap.Range(func(_ string, inst *ArrInstance) bool {
+		if inst.arrClient != nil {
+			inst.arrClient.Start()
+		}
+		return true
+	})
+}
+
+func (pm *ProfileManager) stopClients() {
+	pm.profileMap.Range(func(_ string, inst *ArrInstance) bool {
+		if inst.arrClient != nil {
+			inst.arrClient.Stop()
+		}
+		return true
+	})
 }
 
 func createViperInstance(fType string) *viper.Viper {
@@ -82,18 +109,32 @@ func createViperInstance(fType string) *viper.Viper {
 	return v
 }
 
-func loadProfiles(v *viper.Viper) *Map[string, *ArrInstance] {
+func loadProfiles(v *viper.Viper, state *State) *Map[string, *ArrInstance] {
 	instanceMap := Map[string, *ArrInstance]{}
+	templates := loadTemplates(v)
+	regexKeys := rawRegexKeys(v)
 	// Get all top-level keys (profile nicknames)
 	profileNames := v.AllSettings()
 	// Unmarshal each profile
 	for nickname := range profileNames {
+		if nickname == profilesKey {
+			continue
+		}
+
 		var instance ArrInstance
-		err := v.UnmarshalKey(nickname, &instance)
+		err := v.UnmarshalKey(nickname, &instance, configDecoder)
 		if err != nil {
 			log.Warn().Msgf("Error unmarshaling profile %s: %s", nickname, err)
 			continue
 		}
+		instance.name = nickname
+		instance.state = state
+		restoreRegexKeys(instance.LanguageMap, regexKeys[nickname])
+		resolveLanguageMap(nickname, &instance, templates)
+		instance.matcher = NewProfileMatcher(instance.LanguageMap).WithPathMappings(instance.PathMappings)
+		for _, warning := range instance.matcher.Lint() {
+			log.Warn().Msgf("%s: %s", nickname, warning)
+		}
 		instanceMap.Store(nickname, &instance)
 		instance.InitClient()
 		log.Info().Interface("inst", instance).Msgf("Loaded instance %s", nickname)
@@ -106,6 +147,94 @@ func loadProfiles(v *viper.Viper) *Map[string, *ArrInstance] {
 	return &instanceMap
 }
 
+// rawRegexKeys reads the re: language map keys of every instance as written in the config file, keyed by the lowercase
+// nickname and key. Viper lowercases keys, which would turn escapes like \D into \d and invert the expression
+func rawRegexKeys(v *viper.Viper) map[string]map[string]string {
+	path := v.ConfigFileUsed()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to read the config file, re: keys are used lowercased")
+		return nil
+	}
+	decoder, err := viper.NewCodecRegistry().Decoder(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to decode the config file, re: keys are used lowercased")
+		return nil
+	}
+	raw := map[string]any{}
+	if err = decoder.Decode(data, raw); err != nil {
+		log.Warn().Err(err).Msg("Unable to decode the config file, re: keys are used lowercased")
+		return nil
+	}
+
+	res := map[string]map[string]string{}
+	for nickname, inst := range raw {
+		fields, _ := inst.(map[string]any)
+		for field, languageMap := range fields {
+			entries, ok := languageMap.(map[string]any)
+			if !ok || !strings.EqualFold(field, "language_map") {
+				continue
+			}
+			for key := range entries {
+				lower := strings.ToLower(key)
+				if !strings.HasPrefix(lower, regexRulePrefix) {
+					continue
+				}
+				if res[strings.ToLower(nickname)] == nil {
+					res[strings.ToLower(nickname)] = map[string]string{}
+				}
+				res[strings.ToLower(nickname)][lower] = key
+			}
+		}
+	}
+	return res
+}
+
+// restoreRegexKeys renames the lowercased re: keys of the language map back to their original form
+func restoreRegexKeys(languageMap map[string]*Profile, original map[string]string) {
+	for lower, key := range original {
+		prof, ok := languageMap[lower]
+		if !ok || key == lower {
+			continue
+		}
+		delete(languageMap, lower)
+		languageMap[key] = prof
+	}
+}
+
+// loadTemplates reads and resolves the named profiles in the profiles section
+func loadTemplates(v *viper.Viper) map[string]*Profile {
+	raw := map[string]*Profile{}
+	err := v.UnmarshalKey(profilesKey, &raw, configDecoder)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error unmarshaling profiles section")
+		return map[string]*Profile{}
+	}
+
+	templates, errs := resolveTemplates(raw)
+	for name, err := range errs {
+		log.Error().Err(err).Msgf("Rejecting profile %s", name)
+	}
+	return templates
+}
+
+// resolveLanguageMap replaces every language map entry with its fully resolved profile,
+// entries referencing an unknown or rejected profile are dropped
+func resolveLanguageMap(nickname string, instance *ArrInstance, templates map[string]*Profile) {
+	for key, prof := range instance.LanguageMap {
+		res, err := resolveProfile(prof, templates)
+		if err != nil {
+			log.Error().Err(err).Msgf("Ignoring language map entry %s for %s", key, nickname)
+			delete(instance.LanguageMap, key)
+			continue
+		}
+		instance.LanguageMap[key] = res
+	}
+}
+
 func createExample(v *viper.Viper) {
 	nick := "some-meaningful-nickname"
 	inst := ArrInstance{
@@ -114,14 +243,34 @@ func createExample(v *viper.Viper) {
 		ApiKey:   "your_api_key_here",
 		LanguageMap: map[string]*Profile{
 			"/media/shows": {
-				RequiredLanguagesAudio: []string{"en", "fr"},
-				RequiredLanguagesSubs:  []string{"en", "kr"},
+				Extends: "english",
 			},
 			"/media/kdramas": {
+				Extends:                "english",
 				RequiredLanguagesAudio: []string{"en", "kr"},
-				RequiredLanguagesSubs:  []string{"en", "kr"},
 			},
 		},
 	}
-	v.SetDefault(nick, inst)
+	profiles := map[string]*Profile{
+		"english": {
+			RequiredLanguagesAudio: []string{"en"},
+			RequiredLanguagesSubs:  []string{"en"},
+		},
+	}
+	v.SetDefault(profilesKey, toConfigMap(profiles))
+	v.SetDefault(nick, toConfigMap(inst))
+}
+
+// toConfigMap converts a config struct to a map so it is written using its json keys
+func toConfigMap(val any) map[string]any {
+	res := map[string]any{}
+	data, err := json.Marshal(val)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to convert config value")
+		return res
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		log.Error().Err(err).Msg("unable to convert config value")
+	}
+	return res
 }
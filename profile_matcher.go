@@ -0,0 +1,384 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type ruleKind int
+
+// kinds are declared in order of precedence, lower wins
+const (
+	ruleID ruleKind = iota
+	ruleTag
+	rulePrefix
+	ruleGlob
+	ruleRegex
+)
+
+const (
+	regexRulePrefix = "re:"
+	globRulePrefix  = "glob:"
+	globMetaChars   = "*?["
+)
+
+// prefixes of language map keys that refer to a single series or movie
+var idRulePrefixes = []string{"tvdb:", "tmdb:", "imdb:"}
+
+func (k ruleKind) String() string {
+	switch k {
+	case ruleID:
+		return "id"
+	case ruleTag:
+		return "tag"
+	case rulePrefix:
+		return "prefix"
+	case ruleGlob:
+		return "glob"
+	case ruleRegex:
+		return "regex"
+	default:
+		return "unknown"
+	}
+}
+
+// ProfileQuery holds the attributes of a media item that language map keys are matched against
+type ProfileQuery struct {
+	// IDs are the external ids of the item formatted as <source>:<id>, see mediaIDs
+	IDs  []string
+	Tags []string
+	// Path is the full path of the series or movie folder
+	Path string
+}
+
+// MatchedRule is a language map key that contributed to a ProfileMatch
+type MatchedRule struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"`
+}
+
+// ProfileMatch is the resolved profile along with the language map keys that selected it,
+// rules are ordered from highest to lowest priority
+type ProfileMatch struct {
+	Profile *Profile
+	Rules   []MatchedRule
+}
+
+type profileRule struct {
+	key     string
+	kind    ruleKind
+	profile *Profile
+	// value is the normalized tag or path prefix
+	value   string
+	pattern *regexp.Regexp
+	// specificity orders rules of the same kind, higher wins
+	specificity int
+}
+
+// ProfileMatcher selects a profile from an instance language map, keys are interpreted as:
+//
+//   - `tvdb:<id>`, `tmdb:<id>` or `imdb:<id>` override for a single series or movie
+//   - `re:<expr>` regular expression matched against the full path
+//   - `glob:<pattern>` or any path containing *, ? or [ as a glob, * does not cross directories while ** does
+//   - any other key containing a slash as a directory prefix, the longest matching prefix wins
+//   - everything else as a tag
+//
+// id overrides always take precedence, otherwise profiles with a higher priority are applied first, on equal priority
+// tags take precedence over prefixes, then globs, then regular expressions.
+// A profile in merge mode is combined with the next matching profile,
+// matching stops at the first profile in replace mode.
+// Keys are case-insensitive since viper lowercases them on load, re: keys are read back from the config file as written
+// so escapes like \D keep their meaning.
+// Path keys may be written as the *arr app reports the path or as warden sees it through the path mappings.
+type ProfileMatcher struct {
+	rules    []*profileRule
+	mappings PathMappings
+}
+
+func NewProfileMatcher(languageMap map[string]*Profile) *ProfileMatcher {
+	matcher := &ProfileMatcher{}
+	for key, prof := range languageMap {
+		rule, err := parseProfileRule(key, prof)
+		if err != nil {
+			log.Error().Err(err).Msgf("Ignoring invalid language map key %s", key)
+			continue
+		}
+		if prof.Merge != "" && prof.Merge != MergeReplace && prof.Merge != MergeMerge {
+			log.Warn().Msgf("Unknown merge mode %q for language map key %s, using %s", prof.Merge, key, MergeReplace)
+		}
+		matcher.rules = append(matcher.rules, rule)
+	}
+
+	slices.SortFunc(matcher.rules, compareRules)
+	return matcher
+}
+
+// WithPathMappings also matches path keys against the local form of the query path
+func (m *ProfileMatcher) WithPathMappings(mappings PathMappings) *ProfileMatcher {
+	m.mappings = mappings
+	return m
+}
+
+// compareRules orders rules by precedence, ties are broken on the key to keep matching deterministic
+func compareRules(a, b *profileRule) int {
+	return cmp.Or(
+		compareBool(a.kind == ruleID, b.kind == ruleID),
+		cmp.Compare(b.profile.Priority, a.profile.Priority),
+		cmp.Compare(a.kind, b.kind),
+		cmp.Compare(b.specificity, a.specificity),
+		cmp.Compare(a.key, b.key),
+	)
+}
+
+// compareBool orders true before false
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func parseProfileRule(key string, prof *Profile) (*profileRule, error) {
+	if prof == nil {
+		return nil, fmt.Errorf("empty profile")
+	}
+
+	rule := &profileRule{key: key, profile: prof}
+	lower := strings.ToLower(key)
+
+	switch {
+	case isIDKey(lower):
+		rule.kind = ruleID
+		rule.value = strings.ReplaceAll(lower, " ", "")
+	case strings.HasPrefix(lower, regexRulePrefix):
+		expr := key[len(regexRulePrefix):]
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		rule.kind = ruleRegex
+		rule.pattern = re
+	case strings.HasPrefix(lower, globRulePrefix) ||
+		(isPathKey(lower) && strings.ContainsAny(lower, globMetaChars)):
+		glob := normalizeMatchPath(strings.TrimPrefix(lower, globRulePrefix))
+		re, err := globToRegexp(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob: %w", err)
+		}
+		rule.kind = ruleGlob
+		rule.pattern = re
+		rule.specificity = strings.IndexAny(glob, globMetaChars)
+	case isPathKey(lower):
+		rule.kind = rulePrefix
+		rule.value = normalizeMatchPath(lower)
+		rule.specificity = len(rule.value)
+	default:
+		rule.kind = ruleTag
+		rule.value = lower
+	}
+
+	return rule, nil
+}
+
+// Match returns the profile for the query, combining profiles in merge mode
+func (m *ProfileMatcher) Match(query ProfileQuery) (*ProfileMatch, bool) {
+	var paths []string
+	if query.Path != "" {
+		paths = append(paths, normalizeMatchPath(query.Path))
+		if local := m.mappings.ToLocal(query.Path); local != query.Path {
+			paths = append(paths, normalizeMatchPath(local))
+		}
+	}
+	tags := make([]string, len(query.Tags))
+	for i, tag := range query.Tags {
+		tags[i] = strings.ToLower(tag)
+	}
+	ids := make([]string, len(query.IDs))
+	for i, id := range query.IDs {
+		ids[i] = strings.ToLower(id)
+	}
+
+	var result *ProfileMatch
+	for _, rule := range m.rules {
+		if !rule.matches(ids, tags, paths) {
+			continue
+		}
+
+		if result == nil {
+			prof := *rule.profile
+			result = &ProfileMatch{Profile: &prof}
+		} else {
+			result.Profile.merge(rule.profile)
+		}
+		result.Rules = append(result.Rules, MatchedRule{Key: rule.key, Kind: rule.kind.String()})
+
+		if rule.profile.Merge != MergeMerge {
+			break
+		}
+	}
+
+	return result, result != nil
+}
+
+// TagKeys returns the language map keys that are matched against tags
+func (m *ProfileMatcher) TagKeys() []string {
+	var keys []string
+	for _, rule := range m.rules {
+		if rule.kind == ruleTag {
+			keys = append(keys, rule.value)
+		}
+	}
+	return keys
+}
+
+// Lint returns a warning for each pair of language map keys that could match
+// the same item where only the key order decides which profile is used,
+// and for each remediation action that would be skipped
+func (m *ProfileMatcher) Lint() []string {
+	var warnings []string
+	for i, a := range m.rules {
+		for _, warning := range a.profile.lintRemediation() {
+			warnings = append(warnings, fmt.Sprintf("%s key %q: %s", a.kind, a.key, warning))
+		}
+		for _, b := range m.rules[i+1:] {
+			if !a.ambiguousWith(b) {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf(
+				"%s keys %q and %q could match the same item with equal priority, %q wins by key order; set a priority or merge mode to make this explicit",
+				a.kind, a.key, b.key, a.key,
+			))
+		}
+	}
+	return warnings
+}
+
+func (r *profileRule) ambiguousWith(other *profileRule) bool {
+	if r.profile.Merge == MergeMerge || other.profile.Merge == MergeMerge {
+		return false
+	}
+	if r.profile.Priority != other.profile.Priority || r.kind != other.kind || r.specificity != other.specificity {
+		return false
+	}
+
+	switch r.kind {
+	case ruleID, rulePrefix:
+		// ids and prefixes of equal length only overlap when they are the same
+		return r.value == other.value
+	default:
+		// tags can be combined on one item, globs and regular expressions may overlap
+		return true
+	}
+}
+
+// matches checks the rule against the item, paths are the normalized remote and local forms of the media path
+func (r *profileRule) matches(ids, tags, paths []string) bool {
+	switch r.kind {
+	case ruleID:
+		return slices.Contains(ids, r.value)
+	case ruleTag:
+		return slices.Contains(tags, r.value)
+	case rulePrefix:
+		return slices.ContainsFunc(paths, func(p string) bool { return hasPathPrefix(p, r.value) })
+	case ruleGlob, ruleRegex:
+		return slices.ContainsFunc(paths, r.pattern.MatchString)
+	default:
+		return false
+	}
+}
+
+func isIDKey(key string) bool {
+	for _, prefix := range idRulePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaIDs formats the external ids of an item for ProfileQuery, zero or empty ids are skipped
+func mediaIDs(tvdbID, tmdbID int, imdbID string) []string {
+	var ids []string
+	if tvdbID != 0 {
+		ids = append(ids, "tvdb:"+strconv.Itoa(tvdbID))
+	}
+	if tmdbID != 0 {
+		ids = append(ids, "tmdb:"+strconv.Itoa(tmdbID))
+	}
+	if imdbID != "" {
+		ids = append(ids, "imdb:"+imdbID)
+	}
+	return ids
+}
+
+func isPathKey(key string) bool {
+	return strings.ContainsAny(key, `/\`)
+}
+
+// normalizeMatchPath converts p to a clean lowercase slash separated path without a trailing slash
+func normalizeMatchPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = strings.ReplaceAll(filepath.ToSlash(p), `\`, "/")
+	return strings.ToLower(path.Clean(p))
+}
+
+// hasPathPrefix reports whether prefix is p or one of its parent directories
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "/" {
+		return strings.HasPrefix(p, "/")
+	}
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// globToRegexp compiles a glob into a regex that matches the directory
+// described by the glob and everything below it
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := slices.Index(runes[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated character class in %s", glob)
+			}
+			class := string(runes[i+1 : i+end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("(/.*)?$")
+	return regexp.Compile(sb.String())
+}
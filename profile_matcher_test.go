@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileMatcher_Match(t *testing.T) {
+	profiles := map[string]*Profile{
+		"anime":                           {Extends: "tag"},
+		"/media/tv":                       {Extends: "tv"},
+		"/media/tv/anime/":                {Extends: "tv-anime"},
+		"/media/tv/*/seasonal":            {Extends: "glob-seasonal"},
+		"glob:/media/**/kids":             {Extends: "glob-kids"},
+		`re:^/media/movies/.*\(19\d\d\)$`: {Extends: "regex-old"},
+	}
+	matcher := NewProfileMatcher(profiles)
+
+	tests := []struct {
+		name  string
+		query ProfileQuery
+		want  string
+		kind  string
+	}{
+		{"tag wins over path", ProfileQuery{Tags: []string{"Anime"}, Path: "/media/tv/Show"}, "anime", "tag"},
+		{"prefix", ProfileQuery{Path: "/media/tv/Show"}, "/media/tv", "prefix"},
+		{"longest prefix", ProfileQuery{Path: "/media/tv/anime/Show"}, "/media/tv/anime/", "prefix"},
+		{"nested longest prefix", ProfileQuery{Path: "/media/tv/anime/seasonal/Show"}, "/media/tv/anime/", "prefix"},
+		{"trailing slash", ProfileQuery{Path: "/media/tv/"}, "/media/tv", "prefix"},
+		{"case insensitive", ProfileQuery{Path: "/Media/TV/Show"}, "/media/tv", "prefix"},
+		{"windows separators", ProfileQuery{Path: `\media\tv\Show`}, "/media/tv", "prefix"},
+		{"sibling is not prefix", ProfileQuery{Path: "/media/tvshows/Show"}, "", ""},
+		{"glob miss", ProfileQuery{Path: "/media/other/kdrama/seasonal/Show"}, "", ""},
+		{"double star glob", ProfileQuery{Path: "/media/a/b/kids/Show"}, "glob:/media/**/kids", "glob"},
+		{"regex", ProfileQuery{Path: "/media/movies/Alien (1979)"}, `re:^/media/movies/.*\(19\d\d\)$`, "regex"},
+		{"regex miss", ProfileQuery{Path: "/media/movies/Dune (2021)"}, "", ""},
+		{"no path", ProfileQuery{}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := matcher.Match(tt.query)
+			if tt.want == "" {
+				assert.False(t, ok, "unexpected match %+v", match)
+				return
+			}
+			if assert.True(t, ok) {
+				assert.Equal(t, []MatchedRule{{Key: tt.want, Kind: tt.kind}}, match.Rules)
+				assert.Equal(t, profiles[tt.want].Extends, match.Profile.Extends)
+			}
+		})
+	}
+}
+
+func TestProfileMatcher_GlobPrecedence(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"/media/*":             {},
+		"/media/tv/*/seasonal": {},
+	})
+
+	match, ok := matcher.Match(ProfileQuery{Path: "/media/tv/anime/seasonal/Show"})
+	assert.True(t, ok)
+	assert.Equal(t, "/media/tv/*/seasonal", match.Rules[0].Key)
+}
+
+func TestProfileMatcher_InvalidKeys(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"re:([":       {},
+		"/media/[abc": {},
+	})
+	assert.Empty(t, matcher.rules)
+}
+
+func TestProfileMatcher_Priority(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"anime":     {RequiredLanguagesAudio: []string{"jpn"}},
+		"/media/tv": {RequiredLanguagesAudio: []string{"eng"}, Priority: 10},
+	})
+
+	match, ok := matcher.Match(ProfileQuery{Tags: []string{"anime"}, Path: "/media/tv/Show"})
+	assert.True(t, ok)
+	assert.Equal(t, "/media/tv", match.Rules[0].Key)
+	assert.Len(t, match.Rules, 1)
+	assert.Equal(t, []string{"eng"}, match.Profile.RequiredLanguagesAudio)
+}
+
+func TestProfileMatcher_Merge(t *testing.T) {
+	path := &Profile{RequiredLanguagesAudio: []string{"eng"}, RequiredLanguagesSubs: []string{"eng"}}
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"/media/tv": path,
+		"anime":     {RequiredLanguagesSubs: []string{"jpn", "eng"}, Priority: 5, Merge: MergeMerge},
+		"/media":    {RequiredLanguagesAudio: []string{"ger"}},
+	})
+
+	match, ok := matcher.Match(ProfileQuery{Tags: []string{"anime"}, Path: "/media/tv/Show"})
+	assert.True(t, ok)
+	assert.Equal(t, []MatchedRule{{Key: "anime", Kind: "tag"}, {Key: "/media/tv", Kind: "prefix"}}, match.Rules)
+	assert.Equal(t, []string{"eng"}, match.Profile.RequiredLanguagesAudio)
+	assert.Equal(t, []string{"jpn", "eng"}, match.Profile.RequiredLanguagesSubs)
+	// the matched profiles must not be modified by merging
+	assert.Equal(t, []string{"eng"}, path.RequiredLanguagesSubs)
+
+	match, ok = matcher.Match(ProfileQuery{Tags: []string{"anime"}, Path: "/other/Show"})
+	assert.True(t, ok)
+	assert.Len(t, match.Rules, 1)
+	assert.Nil(t, match.Profile.RequiredLanguagesAudio)
+}
+
+func TestProfileMatcher_Lint(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"anime":      {},
+		"kdrama":     {},
+		"kids":       {Priority: 1},
+		"subs":       {Merge: MergeMerge},
+		"/media/tv":  {},
+		"/media/tv/": {},
+		"/media/abc": {},
+		"re:foo":     {},
+		"re:bar":     {},
+	})
+
+	warnings := matcher.Lint()
+	assert.Len(t, warnings, 3)
+	assert.Contains(t, warnings[0], `"anime" and "kdrama"`)
+	assert.Contains(t, warnings[1], `"/media/tv" and "/media/tv/"`)
+	assert.Contains(t, warnings[2], `"re:bar" and "re:foo"`)
+}
+
+func TestProfileMatcher_IDOverride(t *testing.T) {
+	matcher := NewProfileMatcher(map[string]*Profile{
+		"/media/shows":   {RequiredLanguagesAudio: []string{"eng"}, Priority: 100},
+		"anime":          {RequiredLanguagesAudio: []string{"jpn"}, Priority: 100},
+		"tvdb:81189":     {RequiredLanguagesAudio: []string{"kor"}},
+		"imdb:tt0903747": {Exempt: true},
+	})
+
+	tests := []struct {
+		name   string
+		ids    []string
+		rule   string
+		exempt bool
+	}{
+		{"tvdb wins over priority", mediaIDs(81189, 0, ""), "tvdb:81189", false},
+		{"imdb exempt", mediaIDs(0, 0, "tt0903747"), "imdb:tt0903747", true},
+		{"no override", mediaIDs(1, 2, "tt1"), "anime", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := matcher.Match(ProfileQuery{IDs: tt.ids, Tags: []string{"anime"}, Path: "/media/shows/Show"})
+			assert.True(t, ok)
+			assert.Equal(t, tt.rule, match.Rules[0].Key)
+			assert.Equal(t, tt.exempt, match.Profile.Exempt)
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// profilesKey is the top-level config section holding reusable named profiles,
+// it is reserved and never loaded as an arr instance
+const profilesKey = "profiles"
+
+// configDecoder makes viper decode using the json tags on the config structs,
+// keys written without underscores (e.g. languagemap) are still accepted
+func configDecoder(c *mapstructure.DecoderConfig) {
+	c.TagName = "json"
+	c.MatchName = func(mapKey, fieldName string) bool {
+		return strings.EqualFold(
+			strings.ReplaceAll(mapKey, "_", ""),
+			strings.ReplaceAll(fieldName, "_", ""),
+		)
+	}
+	c.DecodeHook = mapstructure.ComposeDecodeHookFunc(daysDurationHook, c.DecodeHook, profileRefHook, profileFieldsHook, remediationActionHook)
+}
+
+// daysDurationHook accepts durations with a leading number of days e.g. `30d` or `1d12h`,
+// anything else is left to the default string to duration hook
+func daysDurationHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	days, rest, ok := strings.Cut(strings.TrimSpace(data.(string)), "d")
+	if !ok {
+		return data, nil
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil {
+		return data, nil
+	}
+
+	res := time.Duration(n) * 24 * time.Hour
+	if rest != "" {
+		extra, err := time.ParseDuration(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", data, err)
+		}
+		res += extra
+	}
+	return res, nil
+}
+
+// profileRefHook allows a profile to be written as just the name of
+// the profile it extends e.g. `/media/anime: anime-base`
+func profileRefHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	if to != reflect.TypeOf(Profile{}) && to != reflect.TypeOf(&Profile{}) {
+		return data, nil
+	}
+	return map[string]any{"extends": data}, nil
+}
+
+// profileFields has the fields of Profile, it is decoded without profileFieldsHook
+type profileFields Profile
+
+// profileFieldsHook decodes a profile and records which fields the config sets,
+// so an explicit false, 0 or empty list overrides the value of the parent or a merged profile
+func profileFieldsHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != reflect.TypeOf(Profile{}) && to != reflect.TypeOf(&Profile{}) {
+		return data, nil
+	}
+	fields, ok := data.(map[string]any)
+	if !ok {
+		return data, nil
+	}
+
+	var decoded profileFields
+	conf := &mapstructure.DecoderConfig{
+		Result:           &decoded,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	}
+	configDecoder(conf)
+	decoder, err := mapstructure.NewDecoder(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = decoder.Decode(fields); err != nil {
+		return nil, err
+	}
+
+	prof := Profile(decoded)
+	prof.set = map[string]bool{}
+	typ := reflect.TypeOf(prof)
+	for key := range fields {
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if tag != "" && conf.MatchName(key, tag) {
+				prof.set[typ.Field(i).Name] = true
+			}
+		}
+	}
+	return prof, nil
+}
+
+// isSet reports whether the field with the name is set on p
+func (p *Profile) isSet(name string, field reflect.Value) bool {
+	if p.set == nil {
+		return !field.IsZero()
+	}
+	return p.set[name]
+}
+
+// setFields returns a copy of the names of the fields set on p
+func (p *Profile) setFields() map[string]bool {
+	res := map[string]bool{}
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; v.Field(i).CanSet() && p.isSet(name, v.Field(i)) {
+			res[name] = true
+		}
+	}
+	return res
+}
+
+// inherit copies every field that is unset on p from parent,
+// a field set to false, 0 or an empty list in the config overrides the parent value
+func (p *Profile) inherit(parent *Profile) {
+	set := p.setFields()
+	dst := reflect.ValueOf(p).Elem()
+	src := reflect.ValueOf(parent).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		name := dst.Type().Field(i).Name
+		if !field.CanSet() || name == "Extends" || set[name] {
+			continue
+		}
+		field.Set(src.Field(i))
+		if parent.isSet(name, src.Field(i)) {
+			set[name] = true
+		}
+	}
+	p.set = set
+}
+
+// resolveTemplates flattens the extends chain of each named profile,
+// profiles that are part of a cycle or extend an unknown profile are returned in errs and left out of the result
+func resolveTemplates(raw map[string]*Profile) (resolved map[string]*Profile, errs map[string]error) {
+	resolved = map[string]*Profile{}
+	errs = map[string]error{}
+
+	var resolve func(name string, chain []string) (*Profile, error)
+	resolve = func(name string, chain []string) (*Profile, error) {
+		if prof, ok := resolved[name]; ok {
+			return prof, nil
+		}
+		if err, ok := errs[name]; ok {
+			return nil, err
+		}
+
+		for i, visited := range chain {
+			if visited == name {
+				cycle := append(chain[i:], name)
+				return nil, fmt.Errorf("profile inheritance cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		tmpl, ok := raw[name]
+		if !ok || tmpl == nil {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+
+		prof := *tmpl
+		if prof.Extends != "" {
+			parent, err := resolve(prof.Extends, append(chain, name))
+			if err != nil {
+				return nil, err
+			}
+			prof.inherit(parent)
+		}
+
+		resolved[name] = &prof
+		return &prof, nil
+	}
+
+	for name := range raw {
+		if _, err := resolve(name, nil); err != nil {
+			errs[name] = err
+		}
+	}
+
+	return resolved, errs
+}
+
+// resolveProfile applies the template named in prof.Extends, fields set on prof take precedence
+func resolveProfile(prof *Profile, templates map[string]*Profile) (*Profile, error) {
+	if prof == nil {
+		return nil, fmt.Errorf("empty profile")
+	}
+
+	res := *prof
+	if res.Extends == "" {
+		return &res, nil
+	}
+
+	parent, ok := templates[res.Extends]
+	if !ok {
+		return nil, fmt.Errorf("unknown or invalid profile %q", res.Extends)
+	}
+	res.inherit(parent)
+	return &res, nil
+}
+
+// merge combines lower into p, language lists are unioned and unset fields are taken from lower,
+// a list set to an empty list in the config stays empty
+func (p *Profile) merge(lower *Profile) {
+	set := p.setFields()
+	defer func() { p.set = set }()
+
+	dst := reflect.ValueOf(p).Elem()
+	src := reflect.ValueOf(lower).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		name := dst.Type().Field(i).Name
+		switch name {
+		case "Extends", "Priority", "Merge":
+			continue
+		}
+		if !field.CanSet() {
+			continue
+		}
+
+		if !set[name] {
+			field.Set(src.Field(i))
+			if lower.isSet(name, src.Field(i)) {
+				set[name] = true
+			}
+			continue
+		}
+		if field.Kind() == reflect.Slice && field.Len() > 0 {
+			union := reflect.AppendSlice(reflect.MakeSlice(field.Type(), 0, field.Len()), field)
+			for j := 0; j < src.Field(i).Len(); j++ {
+				item := src.Field(i).Index(j)
+				if !containsValue(union, item) {
+					union = reflect.Append(union, item)
+				}
+			}
+			field.Set(union)
+		}
+	}
+}
+
+func containsValue(slice reflect.Value, item reflect.Value) bool {
+	for i := 0; i < slice.Len(); i++ {
+		if reflect.DeepEqual(slice.Index(i).Interface(), item.Interface()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const templateConfig = `
+profiles:
+  base:
+    required_languages_audio: [eng]
+    required_languages_sub: [eng]
+  anime:
+    extends: base
+    required_languages_audio: [jpn]
+  seasonal:
+    extends: anime
+  loop-a:
+    extends: loop-b
+  loop-b:
+    extends: loop-a
+sonarr-main:
+  inst_type: sonarr
+  base_path: http://localhost:8989
+  api_key: key
+  language_map:
+    /media/anime: seasonal
+    /media/shows:
+      extends: base
+      required_languages_sub: []
+    /media/broken: loop-a
+`
+
+func TestLoadProfiles_Templates(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(templateConfig)))
+
+	profiles := loadProfiles(v, newMemoryState())
+	_, ok := profiles.Load(profilesKey)
+	assert.False(t, ok, "profiles section should not be loaded as an instance")
+
+	inst, ok := profiles.Load("sonarr-main")
+	require.True(t, ok)
+	assert.Equal(t, SONARR, inst.InstType)
+
+	anime := inst.LanguageMap["/media/anime"]
+	require.NotNil(t, anime)
+	assert.Equal(t, []string{"jpn"}, anime.RequiredLanguagesAudio)
+	assert.Equal(t, []string{"eng"}, anime.RequiredLanguagesSubs)
+
+	shows := inst.LanguageMap["/media/shows"]
+	require.NotNil(t, shows)
+	assert.Equal(t, []string{"eng"}, shows.RequiredLanguagesAudio)
+	assert.Empty(t, shows.RequiredLanguagesSubs)
+
+	_, ok = inst.LanguageMap["/media/broken"]
+	assert.False(t, ok, "entries extending a cyclic profile should be dropped")
+}
+
+func TestResolveTemplates_Cycle(t *testing.T) {
+	_, errs := resolveTemplates(map[string]*Profile{
+		"a":    {Extends: "b"},
+		"b":    {Extends: "c"},
+		"c":    {Extends: "a"},
+		"self": {Extends: "self"},
+		"ok":   {RequiredLanguagesAudio: []string{"eng"}},
+	})
+
+	assert.Len(t, errs, 4)
+	assert.ErrorContains(t, errs["self"], "self -> self")
+	assert.ErrorContains(t, errs["a"], "cycle")
+	assert.NotContains(t, errs, "ok")
+}
+
+func TestResolveTemplates_UnknownParent(t *testing.T) {
+	resolved, errs := resolveTemplates(map[string]*Profile{
+		"child": {Extends: "missing"},
+	})
+
+	assert.Empty(t, resolved)
+	assert.ErrorContains(t, errs["child"], "unknown profile")
+}
+
+func TestLoadProfiles_RegexKeyCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+sonarr-Main:
+  inst_type: sonarr
+  base_path: http://localhost:8989
+  api_key: key
+  language_map:
+    're:^/media/tv/\D+$':
+      required_languages_audio: [eng]
+`), 0o644))
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+
+	inst, ok := loadProfiles(v, newMemoryState()).Load("sonarr-main")
+	require.True(t, ok)
+	require.Contains(t, inst.LanguageMap, `re:^/media/tv/\D+$`)
+
+	_, ok = inst.matcher.Match(ProfileQuery{Path: "/media/tv/Show"})
+	assert.True(t, ok)
+	_, ok = inst.matcher.Match(ProfileQuery{Path: "/media/tv/2024"})
+	assert.False(t, ok, `\D must not be lowercased to \d`)
+}
+
+const overrideConfig = `
+profiles:
+  strict:
+    required_languages_audio: [jpn]
+    reject_grabs: true
+    preflight_search: true
+    priority: 5
+sonarr-main:
+  inst_type: sonarr
+  base_path: http://localhost:8989
+  api_key: key
+  language_map:
+    /media/anime:
+      extends: strict
+      reject_grabs: false
+      priority: 0
+    /media/tv:
+      require_full_subs: true
+      required_languages_sub: [eng]
+    subbed:
+      merge: merge
+      priority: 10
+      require_full_subs: false
+      required_languages_sub: []
+`
+
+func TestLoadProfiles_ExplicitZeroOverrides(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(overrideConfig)))
+
+	inst, ok := loadProfiles(v, newMemoryState()).Load("sonarr-main")
+	require.True(t, ok)
+
+	anime := inst.LanguageMap["/media/anime"]
+	require.NotNil(t, anime)
+	assert.False(t, anime.RejectGrabs, "a child turns off the bool of its parent")
+	assert.Zero(t, anime.Priority)
+	assert.True(t, anime.PreflightSearch)
+	assert.Equal(t, []string{"jpn"}, anime.RequiredLanguagesAudio)
+
+	match, ok := inst.matcher.Match(ProfileQuery{Tags: []string{"subbed"}, Path: "/media/tv/Show"})
+	require.True(t, ok)
+	assert.Len(t, match.Rules, 2)
+	assert.False(t, match.Profile.RequireFullSubs, "a merged profile turns off the bool of the next one")
+	assert.Empty(t, match.Profile.RequiredLanguagesSubs)
+	assert.True(t, inst.LanguageMap["/media/tv"].RequireFullSubs)
+}
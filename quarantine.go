@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	quarantinePurgeInterval = time.Hour
+	// quarantineMetaSuffix is appended to the name of a quarantined file for its metadata sidecar
+	quarantineMetaSuffix = ".warden.json"
+)
+
+var (
+	errNotQuarantined      = errors.New("no such quarantined file")
+	errInvalidQuarantineID = errors.New("quarantine ids are numeric file ids")
+)
+
+// QuarantinedFile is the metadata sidecar written next to a quarantined file
+type QuarantinedFile struct {
+	// ID is the file id the *arr app used, it names the directory holding the file
+	ID       string `json:"id"`
+	Instance string `json:"instance"`
+	MediaID  int    `json:"media_id"`
+	SeriesID int    `json:"series_id,omitempty"`
+	// OriginalPath is the path of the file as reported by the *arr app
+	OriginalPath string `json:"original_path"`
+	// LocalPath is where the file was before it was moved and where it is restored to
+	LocalPath string `json:"local_path"`
+	// Path is the quarantined file
+	Path        string    `json:"path"`
+	Reason      string    `json:"reason,omitempty"`
+	Quarantined time.Time `json:"quarantined"`
+}
+
+// quarantine keeps rejected files of one instance in <dir>/<instance>/<file id>/ until they are restored or purged
+type quarantine struct {
+	dir      string
+	instance string
+	// retention is how long files are kept, 0 keeps them until they are purged by hand
+	retention time.Duration
+	// rescan makes the *arr app pick up a restored file
+	rescan func(ctx context.Context, item QuarantinedFile) error
+}
+
+// newQuarantine returns nil when the instance has no quarantine_dir
+func newQuarantine(inst *ArrInstance, rescan func(context.Context, QuarantinedFile) error) *quarantine {
+	if inst.QuarantineDir == "" {
+		return nil
+	}
+	return &quarantine{
+		dir:       inst.QuarantineDir,
+		instance:  inst.name,
+		retention: inst.QuarantineRetention,
+		rescan:    rescan,
+	}
+}
+
+func (q *quarantine) itemDir(id string) string {
+	return filepath.Join(q.dir, q.instance, filepath.Base(id))
+}
+
+// Add moves the file of the remediation into the quarantine and writes its metadata,
+// a file that was already moved is not moved again so a resumed step succeeds
+func (q *quarantine) Add(rec *PendingRemediation, localPath string) (QuarantinedFile, error) {
+	if q == nil {
+		return QuarantinedFile{}, fmt.Errorf("%w: quarantine_dir is not set for the instance", errRemediationConfig)
+	}
+	if localPath == "" {
+		return QuarantinedFile{}, fmt.Errorf("path of file %s is unknown", rec.FileID)
+	}
+
+	item := QuarantinedFile{
+		ID:           rec.FileID,
+		Instance:     q.instance,
+		MediaID:      rec.MediaID,
+		SeriesID:     rec.SeriesID,
+		OriginalPath: rec.FilePath,
+		LocalPath:    localPath,
+		Path:         filepath.Join(q.itemDir(rec.FileID), filepath.Base(localPath)),
+		Reason:       rec.Reason,
+		Quarantined:  time.Now(),
+	}
+
+	if _, err := os.Stat(localPath); errors.Is(err, os.ErrNotExist) {
+		if _, err = os.Stat(item.Path); err != nil {
+			return item, fmt.Errorf("file %s does not exist", localPath)
+		}
+	} else {
+		if err = os.MkdirAll(filepath.Dir(item.Path), os.ModePerm); err != nil {
+			return item, err
+		}
+		if err = moveFile(localPath, item.Path); err != nil {
+			return item, fmt.Errorf("failed to quarantine %s: %w", localPath, err)
+		}
+	}
+
+	if err := writeQuarantineMeta(item); err != nil {
+		return item, err
+	}
+	log.Info().Str("path", item.Path).Msgf("Quarantined file %s", rec.FileID)
+	return item, nil
+}
+
+// List returns the quarantined files, oldest first
+func (q *quarantine) List() ([]QuarantinedFile, error) {
+	metas, err := filepath.Glob(filepath.Join(q.dir, q.instance, "*", "*"+quarantineMetaSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []QuarantinedFile
+	for _, meta := range metas {
+		item, err := readQuarantineMeta(meta)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Ignoring quarantine metadata %s", meta)
+			continue
+		}
+		res = append(res, item)
+	}
+	slices.SortFunc(res, func(a, b QuarantinedFile) int {
+		return a.Quarantined.Compare(b.Quarantined)
+	})
+	return res, nil
+}
+
+// Get returns the quarantined file with the id, ids are the numeric file ids so they never act as glob patterns
+func (q *quarantine) Get(id string) (QuarantinedFile, error) {
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return QuarantinedFile{}, fmt.Errorf("%w: %q", errInvalidQuarantineID, id)
+	}
+	metas, _ := filepath.Glob(filepath.Join(q.itemDir(id), "*"+quarantineMetaSuffix))
+	if len(metas) == 0 {
+		return QuarantinedFile{}, fmt.Errorf("%w: %s", errNotQuarantined, id)
+	}
+	return readQuarantineMeta(metas[0])
+}
+
+// Restore moves the file back to where it was and asks the *arr app to rescan it,
+// it refuses to overwrite a file that has since taken its place
+func (q *quarantine) Restore(ctx context.Context, id string) (QuarantinedFile, error) {
+	item, err := q.Get(id)
+	if err != nil {
+		return item, err
+	}
+	if _, err = os.Stat(item.LocalPath); err == nil {
+		return item, fmt.Errorf("%s already exists, purge the quarantined file or move the other one away first", item.LocalPath)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(item.LocalPath), os.ModePerm); err != nil {
+		return item, err
+	}
+	if err = moveFile(item.Path, item.LocalPath); err != nil {
+		return item, fmt.Errorf("failed to restore %s: %w", item.LocalPath, err)
+	}
+	if err = os.RemoveAll(q.itemDir(id)); err != nil {
+		log.Warn().Err(err).Msgf("Unable to remove quarantine directory of file %s", id)
+	}
+	log.Info().Str("path", item.LocalPath).Msgf("Restored quarantined file %s", id)
+
+	if q.rescan != nil {
+		if err = q.rescan(ctx, item); err != nil {
+			return item, fmt.Errorf("file was restored but the rescan failed: %w", err)
+		}
+	}
+	return item, nil
+}
+
+// Purge deletes the quarantined file and its metadata
+func (q *quarantine) Purge(id string) error {
+	if _, err := q.Get(id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(q.itemDir(id)); err != nil {
+		return fmt.Errorf("failed to purge quarantined file %s: %w", id, err)
+	}
+	log.Info().Msgf("Purged quarantined file %s", id)
+	return nil
+}
+
+// PurgeExpired deletes the files quarantined longer than the retention, returns how many were deleted
+func (q *quarantine) PurgeExpired(now time.Time) int {
+	if q.retention <= 0 {
+		return 0
+	}
+	items, err := q.List()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to list quarantined files")
+		return 0
+	}
+
+	purged := 0
+	for _, item := range items {
+		if now.Sub(item.Quarantined) < q.retention {
+			continue
+		}
+		if err = q.Purge(item.ID); err != nil {
+			log.Error().Err(err).Msg("Unable to purge expired quarantined file")
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+// watch purges expired files every quarantinePurgeInterval until ctx is cancelled
+func (q *quarantine) watch(ctx context.Context) {
+	if q == nil || q.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(quarantinePurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if n := q.PurgeExpired(time.Now()); n > 0 {
+			log.Info().Msgf("Purged %d quarantined files older than %s", n, q.retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeQuarantineMeta(item QuarantinedFile) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(item.Path+quarantineMetaSuffix, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write quarantine metadata: %w", err)
+	}
+	return nil
+}
+
+func readQuarantineMeta(path string) (QuarantinedFile, error) {
+	var item QuarantinedFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return item, err
+	}
+	if err = json.Unmarshal(data, &item); err != nil {
+		return item, fmt.Errorf("invalid quarantine metadata %s: %w", path, err)
+	}
+	// the quarantine directory may have been moved since the file was added
+	item.Path = strings.TrimSuffix(path, quarantineMetaSuffix)
+	return item, nil
+}
+
+// moveFile renames src to dest, copying it when they are on different filesystems
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err = out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return os.Remove(src)
+}
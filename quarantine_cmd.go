@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// quarantineOf returns the quarantine of the instance with the nickname
+func quarantineOf(pm *ProfileManager, nickname string) (*quarantine, error) {
+	inst, ok := pm.GetProfile(nickname)
+	if !ok {
+		return nil, fmt.Errorf("no instance named %s", nickname)
+	}
+	if inst.arrClient == nil || inst.arrClient.Quarantine() == nil {
+		return nil, fmt.Errorf("instance %s has no quarantine_dir", nickname)
+	}
+	return inst.arrClient.Quarantine(), nil
+}
+
+// registerQuarantineRoutes serves the quarantine of the instance named by the warden-key header,
+// requests must send the api key of that instance in the X-Api-Key header:
+//
+//	GET    /quarantine              lists the quarantined files
+//	POST   /quarantine/{id}/restore moves the file back and rescans it
+//	DELETE /quarantine/{id}         deletes the file
+func registerQuarantineRoutes(mux *http.ServeMux, pm *ProfileManager) {
+	mux.HandleFunc("GET /quarantine", withQuarantine(pm, func(w http.ResponseWriter, r *http.Request, q *quarantine) {
+		items, err := q.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	}))
+	mux.HandleFunc("POST /quarantine/{id}/restore", withQuarantine(pm, func(w http.ResponseWriter, r *http.Request, q *quarantine) {
+		item, err := q.Restore(r.Context(), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), quarantineStatus(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	}))
+	mux.HandleFunc("DELETE /quarantine/{id}", withQuarantine(pm, func(w http.ResponseWriter, r *http.Request, q *quarantine) {
+		if err := q.Purge(r.PathValue("id")); err != nil {
+			http.Error(w, err.Error(), quarantineStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func withQuarantine(pm *ProfileManager, handler func(http.ResponseWriter, *http.Request, *quarantine)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nickname := r.Header.Get(targetHeader)
+		if nickname == "" {
+			http.Error(w, "Missing header: "+targetHeader, http.StatusBadRequest)
+			return
+		}
+		q, err := quarantineOf(pm, nickname)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if inst, _ := pm.GetProfile(nickname); !validApiKey(inst, r.Header.Get(apiKeyHeader)) {
+			http.Error(w, "Invalid or missing header: "+apiKeyHeader, http.StatusUnauthorized)
+			return
+		}
+		handler(w, r, q)
+	}
+}
+
+// validApiKey compares in constant time, an instance without an api key accepts none
+func validApiKey(inst *ArrInstance, key string) bool {
+	return inst.ApiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(inst.ApiKey)) == 1
+}
+
+func quarantineStatus(err error) int {
+	if errors.Is(err, errNotQuarantined) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, errInvalidQuarantineID) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Unable to write response")
+	}
+}
+
+const quarantineUsage = `usage: warden quarantine [-prof yaml] <command> <instance> [id]
+
+commands:
+  list <instance>          list the quarantined files
+  restore <instance> <id>  move the file back and rescan it
+  purge <instance> <id>    delete the file
+`
+
+// runQuarantineCommand handles `warden quarantine ...` against the instances in the config file
+func runQuarantineCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("quarantine", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() { fmt.Fprint(out, quarantineUsage) }
+	confType := flags.String("prof", "yaml", "profile file type (json, yaml, toml)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cmd, rest := flags.Arg(0), flags.Args()
+	if len(rest) > 0 {
+		rest = rest[1:]
+	}
+	wantArgs := 2
+	if cmd == "list" {
+		wantArgs = 1
+	}
+	if len(rest) != wantArgs {
+		flags.Usage()
+		return errors.New("wrong number of arguments")
+	}
+
+	v := createViperInstance(*confType)
+	if v == nil {
+		return errors.New("unable to load the config")
+	}
+	state := NewState(defaultStateDir)
+	pm := &ProfileManager{profileMap: loadProfiles(v, state), v: v, state: state}
+	q, err := quarantineOf(pm, rest[0])
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "list":
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		printQuarantine(out, items, time.Now())
+		return nil
+	case "restore":
+		item, err := q.Restore(context.Background(), rest[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "restored %s\n", item.LocalPath)
+		return nil
+	case "purge":
+		if err = q.Purge(rest[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "purged %s\n", rest[1])
+		return nil
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func printQuarantine(out io.Writer, items []QuarantinedFile, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tAGE\tREASON\tPATH")
+	for _, item := range items {
+		age := now.Sub(item.Quarantined).Truncate(time.Minute)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.ID, age, item.Reason, item.OriginalPath)
+	}
+	w.Flush()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQuarantine returns a quarantine with one file in it, quarantined from library/Show - S01E01.mkv
+func newTestQuarantine(t *testing.T) (*quarantine, QuarantinedFile, *[]QuarantinedFile) {
+	dir := t.TempDir()
+	var rescanned []QuarantinedFile
+	q := newQuarantine(&ArrInstance{QuarantineDir: filepath.Join(dir, "quarantine"), name: "sonarr-main"}, func(_ context.Context, item QuarantinedFile) error {
+		rescanned = append(rescanned, item)
+		return nil
+	})
+
+	local := filepath.Join(dir, "library", "Show - S01E01.mkv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(local), os.ModePerm))
+	require.NoError(t, os.WriteFile(local, []byte("video"), 0o644))
+
+	item, err := q.Add(&PendingRemediation{
+		MediaID:  13947,
+		FileID:   "11729",
+		SeriesID: 118,
+		FilePath: "/tv/Show/Show - S01E01.mkv",
+		Reason:   "missing required languages",
+	}, local)
+	require.NoError(t, err)
+	return q, item, &rescanned
+}
+
+func TestQuarantine_Add(t *testing.T) {
+	q, item, _ := newTestQuarantine(t)
+
+	assert.NoFileExists(t, item.LocalPath)
+	assert.FileExists(t, item.Path)
+	assert.FileExists(t, item.Path+quarantineMetaSuffix)
+
+	items, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "11729", items[0].ID)
+	assert.Equal(t, "/tv/Show/Show - S01E01.mkv", items[0].OriginalPath)
+	assert.Equal(t, item.LocalPath, items[0].LocalPath)
+	assert.Equal(t, 118, items[0].SeriesID)
+
+	// a resumed step finds the file already moved
+	_, err = q.Add(&PendingRemediation{FileID: "11729", FilePath: item.OriginalPath}, item.LocalPath)
+	assert.NoError(t, err)
+}
+
+func TestQuarantine_Restore(t *testing.T) {
+	q, item, rescanned := newTestQuarantine(t)
+
+	require.NoError(t, os.WriteFile(item.LocalPath, []byte("replacement"), 0o644))
+	_, err := q.Restore(context.Background(), "11729")
+	assert.ErrorContains(t, err, "already exists")
+	require.NoError(t, os.Remove(item.LocalPath))
+
+	restored, err := q.Restore(context.Background(), "11729")
+	require.NoError(t, err)
+	data, err := os.ReadFile(restored.LocalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+	assert.NoDirExists(t, q.itemDir("11729"))
+	require.Len(t, *rescanned, 1)
+	assert.Equal(t, 13947, (*rescanned)[0].MediaID)
+
+	_, err = q.Restore(context.Background(), "11729")
+	assert.ErrorIs(t, err, errNotQuarantined)
+}
+
+func TestQuarantine_Purge(t *testing.T) {
+	q, item, _ := newTestQuarantine(t)
+
+	assert.ErrorIs(t, q.Purge("404"), errNotQuarantined)
+	for _, id := range []string{"*", "1172?", "../sonarr-main/11729"} {
+		assert.ErrorIs(t, q.Purge(id), errInvalidQuarantineID)
+		_, err := q.Restore(context.Background(), id)
+		assert.ErrorIs(t, err, errInvalidQuarantineID)
+	}
+	assert.FileExists(t, item.Path)
+	require.NoError(t, q.Purge("11729"))
+	assert.NoFileExists(t, item.Path)
+
+	items, err := q.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestQuarantine_PurgeExpired(t *testing.T) {
+	q, item, _ := newTestQuarantine(t)
+
+	assert.Zero(t, q.PurgeExpired(time.Now().Add(time.Hour)), "files are kept without a retention")
+
+	q.retention = 24 * time.Hour
+	assert.Zero(t, q.PurgeExpired(time.Now().Add(time.Hour)))
+	assert.Equal(t, 1, q.PurgeExpired(time.Now().Add(25*time.Hour)))
+	assert.NoFileExists(t, item.Path)
+}
+
+func TestQuarantine_Routes(t *testing.T) {
+	q, _, rescanned := newTestQuarantine(t)
+	inst := &ArrInstance{InstType: SONARR, ApiKey: "secret", QuarantineDir: q.dir, name: "sonarr-main"}
+	inst.InitClient()
+	inst.arrClient.(*SonarrInst).quarantine = q
+
+	pm := &ProfileManager{profileMap: &Map[string, *ArrInstance]{}}
+	pm.profileMap.Store("sonarr-main", inst)
+	mux := http.NewServeMux()
+	registerQuarantineRoutes(mux, pm)
+
+	apiKey := "secret"
+	send := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set(targetHeader, key)
+		}
+		req.Header.Set(apiKeyHeader, apiKey)
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+		return res
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/quarantine", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/quarantine", "radarr-main").Code)
+
+	apiKey = "wrong"
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/quarantine", "sonarr-main").Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodDelete, "/quarantine/11729", "sonarr-main").Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/quarantine/11729/restore", "sonarr-main").Code)
+	apiKey = "secret"
+
+	res := send(http.MethodGet, "/quarantine", "sonarr-main")
+	require.Equal(t, http.StatusOK, res.Code)
+	var items []QuarantinedFile
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "11729", items[0].ID)
+
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/quarantine/404", "sonarr-main").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/quarantine/*", "sonarr-main").Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/quarantine/11729/restore", "sonarr-main").Code)
+	assert.Len(t, *rescanned, 1)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/quarantine/11729/restore", "sonarr-main").Code)
+}
+
+func TestLoadProfiles_QuarantineRetention(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`
+radarr-main:
+  inst_type: radarr
+  base_path: http://localhost:7878
+  api_key: key
+  quarantine_dir: /quarantine
+  quarantine_retention: 1d12h
+`)))
+
+	inst, ok := loadProfiles(v, newMemoryState()).Load("radarr-main")
+	require.True(t, ok)
+	assert.Equal(t, 36*time.Hour, inst.QuarantineRetention)
+	require.NotNil(t, inst.arrClient.Quarantine())
+	assert.Equal(t, 36*time.Hour, inst.arrClient.Quarantine().retention)
+}
@@ -1,16 +1,669 @@
 package main
 
-import "github.com/rs/zerolog/log"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/bazarr"
+	"github.com/RA341/warden/api/radarr"
+	"github.com/RA341/warden/tracks"
+	"github.com/rs/zerolog/log"
+)
+
+// RadarrWebhookPayload represents the structure of the incoming webhook JSON
+type RadarrWebhookPayload struct {
+	Movie struct {
+		Id               int      `json:"id"`
+		FolderPath       string   `json:"folderPath"`
+		TmdbId           int      `json:"tmdbId"`
+		ImdbId           string   `json:"imdbId"`
+		Tags             []string `json:"tags"`
+		OriginalLanguage struct {
+			Name string `json:"name"`
+		} `json:"originalLanguage"`
+	} `json:"movie"`
+	MovieFile struct {
+		ID           int64  `json:"id"`
+		Path         string `json:"path"`
+		RelativePath string `json:"relativePath"`
+		// Languages are the languages radarr parsed from the release
+		Languages languageList `json:"languages"`
+		MediaInfo struct {
+			AudioLanguages languageList `json:"audioLanguages"`
+			Subtitles      languageList `json:"subtitles"`
+		} `json:"mediaInfo"`
+	} `json:"movieFile"`
+	Release            webhookRelease `json:"release"`
+	DownloadID         string         `json:"downloadId"`
+	DownloadClient     string         `json:"downloadClient"`
+	DownloadClientType string         `json:"downloadClientType"`
+}
+
+type RadarrMediaInfo struct {
+	MovieID     int
+	MovieFileID string
+	// MoviePath is the full path of the movie folder
+	MoviePath        string
+	TmdbID           int
+	ImdbID           string
+	Tags             []string
+	OriginalLanguage string
+	// DownloadID is the id of the download the file was imported from
+	DownloadID string
+	// FilePath is the full path of the imported file
+	FilePath string
+	// Tracks are read from the file when the instance inspects files
+	Tracks []tracks.Track
+	// Sidecars are the subtitle files next to the file
+	Sidecars []tracks.Track
+	// FileLanguages are the languages radarr parsed from the release name
+	FileLanguages []string
+	Subtitles     []string
+	Audios        []string
+	// ReleaseDate is when the movie was released, it is loaded from radarr
+	ReleaseDate time.Time
+	// recheck is set when the check was scheduled, the grace period does not apply again then
+	recheck bool
+}
 
 type RadarrInst struct {
+	api        *radarr.Client
+	profiles   *ProfileMatcher
+	remediator *remediator
+	tracker    *grabTracker
+	bazarr     *bazarr.Client
+	// inspectFiles prefers the tracks read from the file over the media info
+	inspectFiles bool
+	// sidecarSubs counts the subtitle files next to the media file
+	sidecarSubs bool
+	paths       PathMappings
+	quarantine  *quarantine
+	cancel      context.CancelFunc
+	// mediaInfoDelay is the wait between checks while radarr is still analysing a file
+	mediaInfoDelay time.Duration
+	// queueDelay is the wait between checks while a grab is not in the queue yet
+	queueDelay time.Duration
+	// commandDelay is the wait between checks while a rescan is running
+	commandDelay time.Duration
+	checks       *recheckScheduler
+}
+
+func NewRadarr(inst *ArrInstance) *RadarrInst {
+	inst.withDefaults()
+	r := &RadarrInst{
+		api:            radarr.New(inst.BasePath, inst.ApiKey),
+		profiles:       inst.matcher,
+		mediaInfoDelay: defaultMediaInfoDelay,
+		queueDelay:     defaultQueueDelay,
+		commandDelay:   defaultCommandDelay,
+		inspectFiles:   inst.InspectFiles,
+		sidecarSubs:    inst.SidecarSubtitles,
+		bazarr:         newBazarrClient(inst.Bazarr),
+		paths:          inst.PathMappings,
+	}
+	r.remediator = &remediator{
+		instance: inst.name,
+		store:    inst.state.Pending,
+		skipped:  inst.state.Skipped,
+		target:   r,
+		api:      r.api.Client,
+	}
+	r.tracker = newGrabTracker(inst, r.rejectGrab)
+	r.checks = &recheckScheduler{
+		instance: inst.name,
+		store:    inst.state.Checks,
+		api:      r.api.Client,
+		check:    r.recheck,
+	}
+	r.quarantine = newQuarantine(inst, func(ctx context.Context, item QuarantinedFile) error {
+		return r.rescanMovie(ctx, item.MediaID)
+	})
+	return r
+}
+
+// Start resumes incomplete remediations, runs scheduled checks and purges expired quarantined files in the background
+func (r *RadarrInst) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.remediator.watch(ctx)
+	go r.tracker.watch(ctx)
+	go r.quarantine.watch(ctx)
+	go r.checks.watch(ctx)
 }
 
-func NewRadarr() *RadarrInst {
-	return &RadarrInst{}
+func (r *RadarrInst) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func (r *RadarrInst) Quarantine() *quarantine {
+	return r.quarantine
 }
 
 func (r *RadarrInst) ProcessWebhook(payload []byte) error {
-	//TODO implement me
-	log.Error().Msg("Unimplemented")
+	switch event := webhookEventType(payload); event {
+	case eventTest:
+		log.Info().Msg("Received test webhook")
+		return nil
+	case eventGrab:
+		grab, err := r.ParseGrab(payload)
+		if err != nil {
+			return err
+		}
+		r.RunGrabCheck(context.Background(), grab)
+		return nil
+	case eventDownload:
+	default:
+		log.Debug().Msgf("Ignoring %s webhook", event)
+		return nil
+	}
+
+	info, err := r.ParseJson(payload)
+	if err != nil {
+		return err
+	}
+	r.tracker.Untrack(info.DownloadID)
+	r.RunCheck(context.Background(), info)
+	return nil
+}
+
+func (r *RadarrInst) RunCheck(ctx context.Context, info *RadarrMediaInfo) {
+	if err := r.check(ctx, info); err != nil {
+		log.Error().Err(err).Msg("Not acting on unverified media info")
+	}
+}
+
+// check is RunCheck returning the error instead of logging it, the file was left alone on error
+func (r *RadarrInst) check(ctx context.Context, info *RadarrMediaInfo) error {
+	ids := mediaIDs(0, info.TmdbID, info.ImdbID)
+	match, ok := r.profiles.Match(ProfileQuery{IDs: ids, Tags: info.Tags, Path: info.MoviePath})
+	if !ok {
+		log.Warn().
+			Strs("ids", ids).
+			Interface("tags", info.Tags).
+			Str("MoviePath", info.MoviePath).
+			Msgf("No profile found, checked ids, tags and movie path")
+		return nil
+	}
+	prof := match.Profile
+	log.Info().
+		Interface("rules", match.Rules).
+		Str("MoviePath", info.MoviePath).
+		Msg("Matched profile")
+
+	if prof.Exempt {
+		log.Info().Strs("ids", ids).Msg("Movie is exempt, skipping check")
+		return nil
+	}
+
+	if err := r.loadMediaInfo(ctx, info); err != nil {
+		return err
+	}
+	if r.sidecarSubs {
+		info.Sidecars = readSidecars(r.paths.ToLocal(info.FilePath))
+	}
+
+	audios, subs := prof.availableLanguages(info.Tracks, info.Sidecars, info.Audios, info.Subtitles)
+	if !prof.Satisfied(audios, subs) {
+		missing, _ := prof.missingSubtitles(audios, subs)
+		r.Remediate(ctx, info, prof, "missing required languages", missing)
+		return nil
+	}
+	log.Debug().Msg("All required languages found")
+	r.checks.Cancel(info.MovieID)
+
+	if prof.rejectsDefaultTracks(info.Tracks, info.MoviePath) {
+		r.Remediate(ctx, info, prof, defaultTracksMismatch, nil)
+		return nil
+	}
+	r.remediator.defaultTracksMatched(info.MovieID)
+	return nil
+}
+
+func (r *RadarrInst) ParseJson(jsonData []byte) (*RadarrMediaInfo, error) {
+	var payload RadarrWebhookPayload
+	if err := json.Unmarshal(jsonData, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Movie.Id == 0 {
+		return nil, errors.New("missing movie id")
+	}
+
+	if payload.Movie.FolderPath == "" {
+		return nil, errors.New("missing movie folder path")
+	}
+
+	if payload.MovieFile.ID == 0 {
+		return nil, errors.New("missing movieFile id")
+	}
+
+	audios := []string(payload.MovieFile.MediaInfo.AudioLanguages)
+	if len(audios) == 0 && len(payload.MovieFile.Languages) > 0 {
+		// no media info in the webhook, use the release languages until the api data is loaded
+		audios = payload.MovieFile.Languages
+	}
+
+	return &RadarrMediaInfo{
+		MovieID:          payload.Movie.Id,
+		MovieFileID:      strconv.FormatInt(payload.MovieFile.ID, 10),
+		MoviePath:        filepath.ToSlash(payload.Movie.FolderPath),
+		TmdbID:           payload.Movie.TmdbId,
+		ImdbID:           payload.Movie.ImdbId,
+		Tags:             payload.Movie.Tags,
+		OriginalLanguage: payload.Movie.OriginalLanguage.Name,
+		DownloadID:       payload.DownloadID,
+		FilePath:         webhookFilePath(payload.Movie.FolderPath, payload.MovieFile.Path, payload.MovieFile.RelativePath),
+		FileLanguages:    payload.MovieFile.Languages,
+		Subtitles:        payload.MovieFile.MediaInfo.Subtitles,
+		Audios:           audios,
+	}, nil
+}
+
+// ParseGrab reads a Grab webhook, sent when radarr hands a release to the download client
+func (r *RadarrInst) ParseGrab(jsonData []byte) (*GrabInfo, error) {
+	var payload RadarrWebhookPayload
+	if err := json.Unmarshal(jsonData, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Movie.Id == 0 {
+		return nil, errors.New("missing movie id")
+	}
+	if payload.Release.ReleaseTitle == "" {
+		return nil, errors.New("missing release title")
+	}
+	if payload.DownloadID == "" {
+		return nil, errors.New("missing download id")
+	}
+
+	return &GrabInfo{
+		MediaIDs:           []int{payload.Movie.Id},
+		Path:               filepath.ToSlash(payload.Movie.FolderPath),
+		TmdbID:             payload.Movie.TmdbId,
+		ImdbID:             payload.Movie.ImdbId,
+		Tags:               payload.Movie.Tags,
+		OriginalLanguage:   payload.Movie.OriginalLanguage.Name,
+		ReleaseTitle:       payload.Release.ReleaseTitle,
+		CustomFormats:      payload.Release.CustomFormats,
+		DownloadID:         payload.DownloadID,
+		DownloadClient:     payload.DownloadClient,
+		DownloadClientType: payload.DownloadClientType,
+	}, nil
+}
+
+// RunGrabCheck rejects the grabbed release before it finishes downloading if it cannot satisfy the profile
+func (r *RadarrInst) RunGrabCheck(ctx context.Context, info *GrabInfo) {
+	ids := mediaIDs(0, info.TmdbID, info.ImdbID)
+	match, ok := r.profiles.Match(ProfileQuery{IDs: ids, Tags: info.Tags, Path: info.Path})
+	if !ok {
+		log.Debug().Str("MoviePath", info.Path).Msg("No profile found for grab")
+		return
+	}
+	prof := match.Profile
+	if prof.Exempt || !prof.RejectGrabs {
+		return
+	}
+	if _, wait := prof.checkDue(time.Now(), r.grabReleaseDate(ctx, info, prof), true); wait {
+		log.Debug().Str("release", info.ReleaseTitle).Msg("Movie is too new to enforce the profile, accepting grab")
+		return
+	}
+
+	reason, reject := prof.grabViolation(info.ReleaseTitle, info.CustomFormats)
+	if !reject {
+		log.Debug().Str("release", info.ReleaseTitle).Msg("Grabbed release may satisfy the profile")
+		r.tracker.Track(info, prof)
+		return
+	}
+
+	log.Info().Str("release", info.ReleaseTitle).Msgf("Rejecting grab, %s", reason)
+	if err := r.rejectGrab(ctx, info); err != nil {
+		log.Error().Err(err).Msg("Unable to reject grab, the file will be checked after import")
+	}
+}
+
+// grabReleaseDate returns when the grabbed movie was released if the profile depends on it, the zero time if it is unknown
+func (r *RadarrInst) grabReleaseDate(ctx context.Context, info *GrabInfo, prof *Profile) time.Time {
+	if prof.EnforceAfter <= 0 || !info.ReleaseDate.IsZero() || len(info.MediaIDs) == 0 {
+		return info.ReleaseDate
+	}
+
+	movie, err := r.api.GetMovie(ctx, info.MediaIDs[0])
+	if err != nil {
+		log.Warn().Err(err).Msgf("Unable to load the release date of movie %d, enforcing the profile", info.MediaIDs[0])
+		return time.Time{}
+	}
+	info.ReleaseDate = movieReleaseDate(movie)
+	return info.ReleaseDate
+}
+
+// rejectGrab removes the download from the queue and the download client, blocklists the release and searches again.
+// It is not persisted like remediations since the file is still checked once it is imported
+func (r *RadarrInst) rejectGrab(ctx context.Context, info *GrabInfo) error {
+	queueIDs, err := findQueueItems(ctx, r.queueDelay, func(ctx context.Context) ([]int, error) {
+		records, err := r.api.GetAllQueue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int
+		for _, rec := range records {
+			if sameDownload(rec.DownloadID, info.DownloadID) {
+				ids = append(ids, rec.ID)
+			}
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to find download %s in the queue: %w", info.DownloadID, err)
+	}
+
+	opts := arr.QueueDeleteOptions{RemoveFromClient: true, Blocklist: true, SkipRedownload: true}
+	for _, id := range queueIDs {
+		if err = r.api.DeleteQueueItem(ctx, id, opts); err != nil && !arr.IsNotFound(err) {
+			return fmt.Errorf("failed to remove queue item %d: %w", id, err)
+		}
+	}
+
+	if _, err = r.api.MoviesSearch(ctx, info.MediaIDs...); err != nil {
+		return fmt.Errorf("failed to search movie %v: %w", info.MediaIDs, err)
+	}
+	return nil
+}
+
+// loadMediaInfo replaces the webhook languages with the tracks of the file when it is inspected,
+// otherwise with the ones radarr has analysed for the movie file
+func (r *RadarrInst) loadMediaInfo(ctx context.Context, info *RadarrMediaInfo) error {
+	if r.inspectFiles {
+		all, err := readFileTracks(r.paths.ToLocal(info.FilePath), &info.Audios, &info.Subtitles)
+		if err == nil {
+			info.Tracks = all
+			return nil
+		}
+		log.Warn().Err(err).Msg("Unable to read the file tracks, falling back to the media info")
+	}
+
+	fileID, err := strconv.Atoi(info.MovieFileID)
+	if err != nil {
+		return fmt.Errorf("invalid movie file id %s: %w", info.MovieFileID, err)
+	}
+
+	mediaInfo, err := fetchAnalysedMediaInfo(ctx, r.mediaInfoDelay, func(ctx context.Context) (*arr.MediaInfo, error) {
+		file, err := r.api.GetMovieFile(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		return file.MediaInfo, nil
+	})
+	if err != nil {
+		return mediaInfoError("movie file", info.MovieFileID, err)
+	}
+
+	applyAuthoritative(mediaInfo, &info.Audios, &info.Subtitles)
+	return nil
+}
+
+// Remediate runs the remediation pipeline of the profile on the file,
+// missingSubs are the subtitle languages of a file that only misses subtitles and enable the subtitle-search action
+func (r *RadarrInst) Remediate(ctx context.Context, info *RadarrMediaInfo, prof *Profile, reason string, missingSubs []string) {
+	if due, ok := prof.checkDue(time.Now(), r.releaseDate(ctx, info, prof), info.recheck); ok {
+		if existing, ok := r.checks.scheduled(info.MovieID, info.MovieFileID); ok && !info.recheck {
+			// another webhook for the same file does not push the check back
+			due = existing
+		}
+		log.Info().Msgf("Movie file %s failed its profile (%s), checking it again at %s", info.MovieFileID, reason, due.Format(time.RFC3339))
+		r.checks.Schedule(info.MovieID, info.MovieFileID, due, info)
+		return
+	}
+
+	subtitleSearch := len(missingSubs) > 0 && r.bazarr != nil
+	steps, actions := prof.remediationPlan(subtitleSearch)
+	if len(steps) == 0 {
+		log.Warn().Msgf("No remediation action applies to movie file %s, keeping it", info.MovieFileID)
+		return
+	}
+	if prof.PreflightSearch && replacesFile(steps) && !r.remediator.Preflight(ctx, info.MovieID, info.MovieFileID, prof, info.OriginalLanguage, r.searchReleases(info.MovieID)) {
+		return
+	}
+	log.Info().Strs("steps", steps).Msgf("Remediating movie file %s", info.MovieFileID)
+
+	rec := PendingRemediation{
+		MediaID:  info.MovieID,
+		FileID:   info.MovieFileID,
+		FilePath: info.FilePath,
+		Reason:   reason,
+		Steps:    steps,
+		Actions:  actions,
+	}
+	if prof.SearchMode == SearchModeGrab {
+		rec.Release = newReleaseCriteria(prof, info.OriginalLanguage)
+	}
+	if subtitleSearch {
+		rec.Subtitles = newSubtitleCriteria(prof, missingSubs, 0, info.FilePath)
+	}
+	if err := r.remediator.start(ctx, rec); err != nil {
+		log.Error().Err(err).Msg("failed to remediate movie")
+	}
+}
+
+// releaseDate returns when the movie was released if the profile depends on it, the zero time if it is unknown
+func (r *RadarrInst) releaseDate(ctx context.Context, info *RadarrMediaInfo, prof *Profile) time.Time {
+	if prof.EnforceAfter <= 0 || !info.ReleaseDate.IsZero() {
+		return info.ReleaseDate
+	}
+
+	movie, err := r.api.GetMovie(ctx, info.MovieID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Unable to load the release date of movie %d, enforcing the profile", info.MovieID)
+		return time.Time{}
+	}
+	info.ReleaseDate = movieReleaseDate(movie)
+	return info.ReleaseDate
+}
+
+// movieReleaseDate is the first digital or physical release of the movie, the cinema release if neither is known
+func movieReleaseDate(movie *radarr.Movie) time.Time {
+	var res time.Time
+	for _, date := range []time.Time{movie.DigitalRelease, movie.PhysicalRelease} {
+		if !date.IsZero() && (res.IsZero() || date.Before(res)) {
+			res = date
+		}
+	}
+	if res.IsZero() {
+		return movie.InCinemas
+	}
+	return res
+}
+
+// recheck runs a scheduled check again unless the movie has another file by now
+func (r *RadarrInst) recheck(ctx context.Context, chk ScheduledCheck) error {
+	var info RadarrMediaInfo
+	if err := json.Unmarshal(chk.Info, &info); err != nil {
+		log.Error().Err(err).Msgf("Dropping unreadable check of file %s", chk.FileID)
+		return nil
+	}
+
+	movie, err := r.api.GetMovie(ctx, info.MovieID)
+	if arr.IsNotFound(err) {
+		log.Info().Msgf("Movie %d no longer exists, dropping its check", info.MovieID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load movie %d: %w", info.MovieID, err)
+	}
+	if !movie.HasFile || strconv.Itoa(movie.MovieFileID) != info.MovieFileID {
+		log.Info().Msgf("Movie file %s was replaced, dropping its check", info.MovieFileID)
+		return nil
+	}
+
+	info.recheck = true
+	info.ReleaseDate = movieReleaseDate(movie)
+	info.Tracks, info.Sidecars = nil, nil
+	return r.check(ctx, &info)
+}
+
+func (r *RadarrInst) runStep(ctx context.Context, rec *PendingRemediation, step RemediationStep) error {
+	switch step {
+	case StepDelete:
+		err := r.deleteMovieFile(ctx, rec.FileID)
+		if arr.IsNotFound(err) {
+			log.Debug().Msgf("movie file %s is already deleted", rec.FileID)
+			return nil
+		}
+		return err
+	case StepMonitor:
+		return r.monitorMovie(ctx, []int{rec.MediaID})
+	case StepSearch:
+		return r.SearchMovie(ctx, rec.MediaID)
+	case StepGrab:
+		return grabBestRelease(ctx, rec, r.searchReleases(rec.MediaID), r.grabRelease, func(ctx context.Context) error {
+			return r.SearchMovie(ctx, rec.MediaID)
+		})
+	case StepSubtitleSearch:
+		if r.bazarr == nil {
+			return searchSubtitles(ctx, rec, nil)
+		}
+		return searchSubtitles(ctx, rec, func(ctx context.Context, opts bazarr.SearchOptions) error {
+			return r.bazarr.SearchMovie(ctx, rec.MediaID, opts)
+		})
+	case StepSubtitleRecheck:
+		var sidecars []tracks.Track
+		if r.sidecarSubs && rec.Subtitles != nil {
+			sidecars = readSidecars(r.paths.ToLocal(rec.Subtitles.FilePath))
+		}
+		if r.bazarr == nil {
+			return recheckSubtitles(ctx, rec, nil, sidecars)
+		}
+		return recheckSubtitles(ctx, rec, func(ctx context.Context) ([]bazarr.Subtitle, error) {
+			movie, err := r.bazarr.Movie(ctx, rec.MediaID)
+			if err != nil {
+				return nil, err
+			}
+			return movie.Subtitles, nil
+		}, sidecars)
+	case StepBlocklist:
+		return r.blocklistMovie(ctx, rec.MediaID)
+	case StepQuarantine:
+		_, err := r.quarantine.Add(rec, r.paths.ToLocal(rec.FilePath))
+		return err
+	case StepRescan:
+		return r.rescanMovie(ctx, rec.MediaID)
+	case StepTag:
+		return r.tagMovie(ctx, rec)
+	case StepUnmonitor:
+		err := r.api.MonitorMovies(ctx, []int{rec.MediaID}, false)
+		if err != nil {
+			return fmt.Errorf("failed to unmonitor movie %d: %w", rec.MediaID, err)
+		}
+		return nil
+	case StepNotify:
+		return notifyRemediation(ctx, rec)
+	case StepExec:
+		return execRemediation(ctx, rec, r.paths.ToLocal(rec.FilePath))
+	default:
+		return fmt.Errorf("unknown remediation step %s", step)
+	}
+}
+
+// SearchMovie triggers an indexer search for the movie
+func (r *RadarrInst) SearchMovie(ctx context.Context, movieID int) error {
+	_, err := r.api.MoviesSearch(ctx, movieID)
+	if err != nil {
+		return fmt.Errorf("failed to search movie %d: %w", movieID, err)
+	}
+	return nil
+}
+
+// searchReleases returns a function running an interactive search for the movie
+func (r *RadarrInst) searchReleases(movieID int) func(context.Context) ([]releaseCandidate, error) {
+	return func(ctx context.Context) ([]releaseCandidate, error) {
+		releases, err := r.api.GetReleases(ctx, movieID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search releases for movie %d: %w", movieID, err)
+		}
+		return radarrCandidates(releases), nil
+	}
+}
+
+func (r *RadarrInst) grabRelease(ctx context.Context, release releaseCandidate) error {
+	err := r.api.GrabRelease(ctx, arr.GrabRequest{GUID: release.GUID, IndexerID: release.IndexerID})
+	if err != nil {
+		return fmt.Errorf("failed to grab release %s: %w", release.Title, err)
+	}
+	return nil
+}
+
+// blocklistMovie marks the latest grab of the movie as failed, files that were never grabbed are skipped
+func (r *RadarrInst) blocklistMovie(ctx context.Context, movieID int) error {
+	history, err := r.api.GetHistory(ctx, radarr.HistoryOptions{
+		PageOptions: arr.PageOptions{PageSize: 50, SortKey: "date", SortDirection: "descending"},
+		MovieID:     movieID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load history of movie %d: %w", movieID, err)
+	}
+
+	id, ok := latestGrab(history.Records, func(rec radarr.HistoryRecord) (int, string, time.Time) {
+		return rec.ID, rec.EventType, rec.Date
+	})
+	if !ok {
+		log.Warn().Msgf("Movie %d has no grab in its history, nothing to blocklist", movieID)
+		return nil
+	}
+	if err = r.api.MarkHistoryFailed(ctx, id); err != nil {
+		return fmt.Errorf("failed to blocklist grab %d of movie %d: %w", id, movieID, err)
+	}
+	return nil
+}
+
+// tagMovie adds the tag of the remediation to the movie
+func (r *RadarrInst) tagMovie(ctx context.Context, rec *PendingRemediation) error {
+	label := remediationTag(rec)
+	tagID, err := ensureTag(ctx, label, r.api.GetTags, r.api.CreateTag)
+	if err != nil {
+		return err
+	}
+	if err = r.api.AddTags(ctx, []int{rec.MediaID}, []int{tagID}); err != nil {
+		return fmt.Errorf("failed to tag movie %d with %s: %w", rec.MediaID, label, err)
+	}
+	return nil
+}
+
+// rescanMovie rescans the movie and waits for radarr to finish
+func (r *RadarrInst) rescanMovie(ctx context.Context, movieID int) error {
+	cmd, err := r.api.RescanMovie(ctx, movieID)
+	if err != nil {
+		return fmt.Errorf("failed to rescan movie %d: %w", movieID, err)
+	}
+	if err = waitForCommand(ctx, r.commandDelay, cmd, r.api.GetCommand); err != nil {
+		return fmt.Errorf("rescan of movie %d did not finish: %w", movieID, err)
+	}
+	return nil
+}
+
+func (r *RadarrInst) deleteMovieFile(ctx context.Context, movieFileID string) error {
+	id, err := strconv.Atoi(movieFileID)
+	if err != nil {
+		return fmt.Errorf("invalid movie file id %s: %w", movieFileID, err)
+	}
+
+	err = r.api.DeleteMovieFile(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete movie file %s: %w", movieFileID, err)
+	}
+	return nil
+}
+
+func (r *RadarrInst) monitorMovie(ctx context.Context, movieIDs []int) error {
+	err := r.api.MonitorMovies(ctx, movieIDs, true)
+	if err != nil {
+		return fmt.Errorf("failed to re-monitor movies %v: %w", movieIDs, err)
+	}
 	return nil
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const radarrTestPayload = `{
+  "eventType": "Download",
+  "movie": {
+    "id": 7,
+    "title": "Alien",
+    "year": 1979,
+    "folderPath": "/media/movies/Alien (1979)",
+    "tmdbId": 348,
+    "imdbId": "tt0078748",
+    "tags": ["classics"]
+  },
+  "movieFile": {
+    "id": 42,
+    "mediaInfo": {
+      "audioLanguages": ["rus"],
+      "subtitles": ["eng"]
+    }
+  }
+}`
+
+func TestRadarr_ParseWebhook(t *testing.T) {
+	cli := NewRadarr(&ArrInstance{BasePath: "http://localhost:7878", ApiKey: "key"})
+	info, err := cli.ParseJson([]byte(radarrTestPayload))
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, info.MovieID)
+	assert.Equal(t, "42", info.MovieFileID)
+	assert.Equal(t, "/media/movies/Alien (1979)", info.MoviePath)
+	assert.Equal(t, 348, info.TmdbID)
+	assert.Equal(t, "tt0078748", info.ImdbID)
+	assert.Equal(t, []string{"classics"}, info.Tags)
+	assert.Equal(t, []string{"rus"}, info.Audios)
+}
+
+func TestRadarr_RemediatesFailingFile(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/moviefile/{id}", http.StatusOK, map[string]any{
+		"id":        42,
+		"mediaInfo": map[string]any{"audioLanguages": "rus", "audioStreamCount": 1, "subtitles": "eng"},
+	})
+	server.JSON("DELETE /api/v3/moviefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/movie/editor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewRadarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		LanguageMap: map[string]*Profile{
+			"/media/movies": {RequiredLanguagesAudio: []string{"eng"}},
+		},
+	})
+	info, err := cli.ParseJson([]byte(radarrTestPayload))
+	require.NoError(t, err)
+
+	cli.RunCheck(context.Background(), info)
+
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/moviefile/42"), 1)
+	assert.Len(t, server.RequestsTo(http.MethodPut, "/api/v3/movie/editor"), 1)
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/command"), 1)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/rs/zerolog/log"
+)
+
+const recheckInterval = time.Minute
+
+// ScheduledCheck is a delayed check of a file, it is persisted so it survives restarts
+type ScheduledCheck struct {
+	Instance string `json:"instance"`
+	// MediaID is the episode or movie id, an item has at most one scheduled check
+	MediaID int `json:"media_id"`
+	// FileID is the file that was checked, the check is dropped if the item has another file by the time it is due
+	FileID string    `json:"file_id"`
+	Due    time.Time `json:"due"`
+	// Info is the media info of the webhook, the languages are loaded again when the check runs
+	Info    json.RawMessage `json:"info"`
+	Created time.Time       `json:"created"`
+}
+
+func (c *ScheduledCheck) Key() string {
+	return c.Instance + ":" + strconv.Itoa(c.MediaID)
+}
+
+// checkDue returns when a failing file of an item released at released is checked again,
+// false if it should be remediated now. The grace period only applies to the first check,
+// the release age is also enforced when a scheduled check runs
+func (p *Profile) checkDue(now, released time.Time, recheck bool) (time.Time, bool) {
+	var due time.Time
+	if p.EnforceAfter > 0 && !released.IsZero() {
+		due = released.Add(p.EnforceAfter)
+	}
+	if p.GracePeriod > 0 && !recheck {
+		if grace := now.Add(p.GracePeriod); grace.After(due) {
+			due = grace
+		}
+	}
+	return due, due.After(now)
+}
+
+// recheckScheduler runs the scheduled checks of one instance once they are due
+type recheckScheduler struct {
+	instance string
+	store    *jsonStore[ScheduledCheck]
+	api      *arr.Client
+	// check runs the check again, an error keeps it scheduled for the next attempt
+	check func(ctx context.Context, chk ScheduledCheck) error
+}
+
+// Schedule checks the item again at due, replacing any check already scheduled for it
+func (s *recheckScheduler) Schedule(mediaID int, fileID string, due time.Time, info any) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to schedule a check of file %s", fileID)
+		return
+	}
+
+	chk := ScheduledCheck{
+		Instance: s.instance,
+		MediaID:  mediaID,
+		FileID:   fileID,
+		Due:      due,
+		Info:     data,
+		Created:  time.Now(),
+	}
+	s.store.Put(chk.Key(), chk)
+	log.Info().Time("due", due).Msgf("Checking file %s again later", fileID)
+}
+
+// scheduled returns when the check of the item is due if it was scheduled for the same file
+func (s *recheckScheduler) scheduled(mediaID int, fileID string) (time.Time, bool) {
+	chk, ok := s.store.Get((&ScheduledCheck{Instance: s.instance, MediaID: mediaID}).Key())
+	if !ok || chk.FileID != fileID {
+		return time.Time{}, false
+	}
+	return chk.Due, true
+}
+
+// Cancel drops the scheduled check of the item, e.g. because a compliant file was imported
+func (s *recheckScheduler) Cancel(mediaID int) {
+	key := (&ScheduledCheck{Instance: s.instance, MediaID: mediaID}).Key()
+	if _, ok := s.store.Get(key); !ok {
+		return
+	}
+	log.Debug().Msgf("Cancelling the scheduled check of item %d", mediaID)
+	s.store.Delete(key)
+}
+
+// due returns the checks of this instance that should run at now
+func (s *recheckScheduler) due(now time.Time) []ScheduledCheck {
+	var res []ScheduledCheck
+	for _, key := range s.store.Keys() {
+		chk, ok := s.store.Get(key)
+		if ok && chk.Instance == s.instance && !now.Before(chk.Due) {
+			res = append(res, chk)
+		}
+	}
+	return res
+}
+
+// runDue runs every check that is due if the instance responds
+func (s *recheckScheduler) runDue(ctx context.Context) {
+	due := s.due(time.Now())
+	if len(due) == 0 || !s.api.Healthy() {
+		return
+	}
+	if err := s.api.Ping(ctx); err != nil {
+		log.Debug().Err(err).Msgf("%s is still unavailable, %d checks due", s.instance, len(due))
+		return
+	}
+
+	for _, chk := range due {
+		if err := s.check(ctx, chk); err != nil {
+			log.Error().Err(err).Msgf("Scheduled check of file %s failed, retrying later", chk.FileID)
+			continue
+		}
+		// the check may have been replaced while it ran
+		if current, ok := s.store.Get(chk.Key()); ok && current.Created.Equal(chk.Created) {
+			s.store.Delete(chk.Key())
+		}
+	}
+}
+
+// watch runs due checks every recheckInterval until ctx is cancelled
+func (s *recheckScheduler) watch(ctx context.Context) {
+	ticker := time.NewTicker(recheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
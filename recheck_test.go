@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/RA341/warden/api/radarr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGraceSonarr returns a sonarr instance whose episode file has english audio until dubbed is set
+func newGraceSonarr(t *testing.T, stateDir string, fileID *atomic.Int64, dubbed *atomic.Bool) (*SonarrInst, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	server.Handle("GET /api/v3/episodefile/{id}", func(w http.ResponseWriter, r *http.Request) {
+		audio := "eng"
+		if dubbed.Load() {
+			audio = "eng/jpn"
+		}
+		arrtest.WriteJSON(w, http.StatusOK, map[string]any{
+			"id":        11729,
+			"mediaInfo": arr.MediaInfo{AudioLanguages: audio, AudioStreamCount: 1},
+		})
+	})
+	server.Handle("GET /api/v3/episode/{id}", func(w http.ResponseWriter, r *http.Request) {
+		arrtest.WriteJSON(w, http.StatusOK, map[string]any{"id": 13947, "seriesId": 118, "episodeFileId": fileID.Load(), "hasFile": true})
+	})
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		name:     "sonarr-main",
+		state:    NewState(stateDir),
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {
+				RequiredLanguagesAudio: []string{"jpn"},
+				GracePeriod:            6 * time.Hour,
+			},
+		},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.mediaInfoDelay = time.Millisecond
+	return cli, server
+}
+
+func runGraceCheck(cli *SonarrInst) {
+	cli.RunCheck(context.Background(), &SonarMediaInfo{
+		SeriesID:      118,
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+	})
+}
+
+// expireCheck moves the scheduled check of the episode into the past
+func expireCheck(t *testing.T, cli *SonarrInst) {
+	chk, ok := cli.checks.store.Get("sonarr-main:13947")
+	require.True(t, ok)
+	chk.Due = time.Now().Add(-time.Second)
+	cli.checks.store.Put(chk.Key(), chk)
+}
+
+func TestSonarr_GracePeriodRemediatesAfterRecheck(t *testing.T) {
+	var fileID atomic.Int64
+	var dubbed atomic.Bool
+	fileID.Store(11729)
+	stateDir := t.TempDir()
+	cli, server := newGraceSonarr(t, stateDir, &fileID, &dubbed)
+
+	runGraceCheck(cli)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), "nothing happens during the grace period")
+
+	// the scheduled check survives a restart
+	chk, ok := NewState(stateDir).Checks.Get("sonarr-main:13947")
+	require.True(t, ok)
+	assert.Equal(t, "11729", chk.FileID)
+	assert.WithinDuration(t, time.Now().Add(6*time.Hour), chk.Due, time.Minute)
+
+	cli.checks.runDue(context.Background())
+	assert.Empty(t, server.RequestsTo(http.MethodGet, "/api/v3/episode/13947"), "checks only run once they are due")
+
+	expireCheck(t, cli)
+	cli.checks.runDue(context.Background())
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+	_, ok = cli.checks.store.Get("sonarr-main:13947")
+	assert.False(t, ok)
+}
+
+func TestSonarr_GracePeriodCompliantOnRecheck(t *testing.T) {
+	var fileID atomic.Int64
+	var dubbed atomic.Bool
+	fileID.Store(11729)
+	cli, server := newGraceSonarr(t, t.TempDir(), &fileID, &dubbed)
+
+	runGraceCheck(cli)
+	dubbed.Store(true)
+	expireCheck(t, cli)
+	cli.checks.runDue(context.Background())
+
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+	assert.Empty(t, cli.checks.store.Keys())
+}
+
+func TestSonarr_GracePeriodFileReplaced(t *testing.T) {
+	var fileID atomic.Int64
+	var dubbed atomic.Bool
+	fileID.Store(11729)
+	cli, server := newGraceSonarr(t, t.TempDir(), &fileID, &dubbed)
+
+	runGraceCheck(cli)
+	fileID.Store(11800)
+	expireCheck(t, cli)
+	cli.checks.runDue(context.Background())
+
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/episodefile/11729"), 1, "a replaced file is not checked again")
+	assert.Empty(t, cli.checks.store.Keys())
+}
+
+func TestSonarr_GracePeriodNotPushedBack(t *testing.T) {
+	var fileID atomic.Int64
+	var dubbed atomic.Bool
+	fileID.Store(11729)
+	cli, _ := newGraceSonarr(t, t.TempDir(), &fileID, &dubbed)
+
+	runGraceCheck(cli)
+	chk, ok := cli.checks.store.Get("sonarr-main:13947")
+	require.True(t, ok)
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	chk.Due = due
+	cli.checks.store.Put(chk.Key(), chk)
+
+	// another import of the same file keeps the due time
+	runGraceCheck(cli)
+	chk, ok = cli.checks.store.Get("sonarr-main:13947")
+	require.True(t, ok)
+	assert.True(t, due.Equal(chk.Due), "got %s", chk.Due)
+}
+
+func TestSonarr_GracePeriodCancelledByCompliantImport(t *testing.T) {
+	var fileID atomic.Int64
+	var dubbed atomic.Bool
+	fileID.Store(11729)
+	cli, _ := newGraceSonarr(t, t.TempDir(), &fileID, &dubbed)
+
+	runGraceCheck(cli)
+	require.Len(t, cli.checks.store.Keys(), 1)
+
+	dubbed.Store(true)
+	runGraceCheck(cli)
+	assert.Empty(t, cli.checks.store.Keys())
+}
+
+func TestSonarr_RecheckKeptWhenUnverified(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{"id": 11729})
+	server.JSON("GET /api/v3/episode/{id}", http.StatusOK, map[string]any{"id": 13947, "episodeFileId": 11729, "hasFile": true})
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath:    server.URL,
+		ApiKey:      arrtest.APIKey,
+		name:        "sonarr-main",
+		state:       newMemoryState(),
+		LanguageMap: map[string]*Profile{"/media/anime": {RequiredLanguagesAudio: []string{"jpn"}}},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.mediaInfoDelay = time.Millisecond
+
+	cli.checks.Schedule(13947, "11729", time.Now().Add(-time.Second), &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+	})
+	cli.checks.runDue(context.Background())
+
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/episodefile/11729"), mediaInfoAttempts)
+	assert.Len(t, cli.checks.store.Keys(), 1, "a check that could not load the media info stays scheduled")
+}
+
+func TestProfile_CheckDue(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	aired := now.Add(-3 * 24 * time.Hour)
+
+	_, ok := (&Profile{}).checkDue(now, aired, false)
+	assert.False(t, ok)
+
+	prof := &Profile{EnforceAfter: 14 * 24 * time.Hour}
+	due, ok := prof.checkDue(now, aired, false)
+	assert.True(t, ok)
+	assert.Equal(t, aired.Add(14*24*time.Hour), due)
+
+	_, ok = prof.checkDue(now, now.Add(-15*24*time.Hour), false)
+	assert.False(t, ok, "old items are enforced right away")
+	_, ok = prof.checkDue(now, time.Time{}, false)
+	assert.False(t, ok, "items without a date are enforced right away")
+
+	prof.GracePeriod = 12 * 24 * time.Hour
+	due, _ = prof.checkDue(now, aired, false)
+	assert.Equal(t, now.Add(12*24*time.Hour), due, "the later of both wins")
+	due, _ = prof.checkDue(now, aired, true)
+	assert.Equal(t, aired.Add(14*24*time.Hour), due, "scheduled checks skip the grace period")
+}
+
+func TestMovieReleaseDate(t *testing.T) {
+	cinema := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	digital := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	physical := time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, digital, movieReleaseDate(&radarr.Movie{InCinemas: cinema, DigitalRelease: digital, PhysicalRelease: physical}))
+	assert.Equal(t, physical, movieReleaseDate(&radarr.Movie{InCinemas: cinema, PhysicalRelease: physical}))
+	assert.Equal(t, cinema, movieReleaseDate(&radarr.Movie{InCinemas: cinema}))
+}
+
+func TestSonarr_EnforceAfter(t *testing.T) {
+	var airDate atomic.Int64
+	airDate.Store(time.Now().Add(-2 * 24 * time.Hour).Unix())
+
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{AudioLanguages: "jpn", AudioStreamCount: 1},
+	})
+	server.Handle("GET /api/v3/episode/{id}", func(w http.ResponseWriter, r *http.Request) {
+		arrtest.WriteJSON(w, http.StatusOK, map[string]any{
+			"id":            13947,
+			"seriesId":      118,
+			"episodeFileId": 11729,
+			"hasFile":       true,
+			"airDateUtc":    time.Unix(airDate.Load(), 0).UTC(),
+		})
+	})
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		name:     "sonarr-main",
+		state:    newMemoryState(),
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {
+				RequiredLanguagesAudio: []string{"jpn", "eng"},
+				EnforceAfter:           14 * 24 * time.Hour,
+			},
+		},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.mediaInfoDelay = time.Millisecond
+
+	runGraceCheck(cli)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), "new episodes are accepted")
+	chk, ok := cli.checks.store.Get("sonarr-main:13947")
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Unix(airDate.Load(), 0).Add(14*24*time.Hour), chk.Due, time.Second)
+
+	// the air date moved, the check waits for it again
+	airDate.Store(time.Now().Add(-time.Hour).Unix())
+	expireCheck(t, cli)
+	cli.checks.runDue(context.Background())
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+	chk, ok = cli.checks.store.Get("sonarr-main:13947")
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Unix(airDate.Load(), 0).Add(14*24*time.Hour), chk.Due, time.Second)
+
+	airDate.Store(time.Now().Add(-15 * 24 * time.Hour).Unix())
+	expireCheck(t, cli)
+	cli.checks.runDue(context.Background())
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+	assert.Empty(t, cli.checks.store.Keys())
+}
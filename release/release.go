@@ -0,0 +1,347 @@
+// Package release infers audio and subtitle languages from release titles and file names,
+// e.g. "MULTi", "GER.DL", "VOSTFR" or "[Eng Sub]"
+package release
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/RA341/warden/lang"
+)
+
+// Info holds the language hints found in a release title, languages are ISO 639-2/B codes
+type Info struct {
+	// Audio are the audio languages named in the title
+	Audio []string
+	// Subtitles are the subtitle languages named in the title
+	Subtitles []string
+	// MultiAudio is set for MULTi releases, they have audio tracks that are not listed
+	MultiAudio bool
+	// DualAudio is set for dual audio releases, usually the original language plus a dub
+	DualAudio bool
+	// MultiSubs is set when the release has several subtitle tracks that are not listed
+	MultiSubs bool
+	// Subbed is set when the release has subtitles without naming their language
+	Subbed bool
+	// Dubbed is set when the release is dubbed without naming the language
+	Dubbed bool
+	// Hardsub is set when subtitles are burned into the video
+	Hardsub bool
+	// Ambiguous is set when an audio language was read from a bare code like ITA in a title without
+	// an episode or year, the code may be part of the name
+	Ambiguous bool
+}
+
+// HasUnlistedAudio reports whether the release has audio tracks besides the ones in Audio
+func (i Info) HasUnlistedAudio() bool {
+	return i.MultiAudio || i.DualAudio
+}
+
+// scene abbreviations that name an audio language on their own
+var audioAliases = map[string]string{
+	"eng": "eng", "ger": "ger", "deu": "ger", "fre": "fre", "fra": "fre", "ita": "ita", "spa": "spa", "esp": "spa",
+	"jap": "jpn", "jpn": "jpn", "rus": "rus", "kor": "kor", "chi": "chi", "por": "por", "hun": "hun", "pol": "pol",
+	"cze": "cze", "swe": "swe", "nor": "nor", "dan": "dan", "fin": "fin", "dut": "dut", "nld": "dut", "tur": "tur",
+	"gre": "gre", "heb": "heb", "ara": "ara", "hin": "hin", "tha": "tha", "vie": "vie", "ukr": "ukr", "rum": "rum",
+	"truefrench": "fre", "vff": "fre", "vfq": "fre", "vfi": "fre", "vf": "fre", "vf2": "fre",
+	"castellano": "spa", "latino": "spa", "portugues": "por", "deutsch": "ger", "italiano": "ita",
+	"espanol": "spa", "francais": "fre", "nihongo": "jpn",
+}
+
+// abbreviations that name a subtitle language on their own
+var subtitleAliases = map[string]string{
+	"vostfr": "fre", "vost": "fre", "vosta": "fre", "subfrench": "fre", "subfr": "fre",
+	"vose": "spa", "vosi": "ita", "subita": "ita", "vosteng": "eng",
+	"chs": "chi", "cht": "chi", "big5": "chi",
+}
+
+// two letter codes only count when glued to sub or dub, e.g. NLSUB or PLDUB
+var shortAliases = map[string]string{
+	"en": "eng", "de": "ger", "fr": "fre", "it": "ita", "es": "spa", "ru": "rus", "pt": "por", "nl": "dut",
+	"pl": "pol", "cz": "cze", "se": "swe", "dk": "dan", "fi": "fin", "kr": "kor", "jp": "jpn",
+}
+
+var (
+	subWords = []string{"sub", "subs", "subbed", "subtitle", "subtitles", "subtitled"}
+	// languageWords appear between language tags
+	languageWords = []string{"dl", "multi", "dual", "audio", "sub", "subs", "subbed", "dub", "dubbed"}
+	dubWords      = []string{"dub", "dubs", "dubbed", "audio"}
+	subSuffix     = []string{"subs", "sub"}
+	dubSuffix     = []string{"dub"}
+
+	// the title ends at the first of these, language names before it are part of the name e.g. The English Patient
+	markerPattern  = regexp.MustCompile(`^(s\d{1,2}(e\d{1,4})*|\d{1,4}|\d{3,4}[pi]|(19|20)\d\d|bluray|bdrip|brrip|web|webrip|webdl|hdtv|dvdrip|remux|hdrip|uhd|complete)$`)
+	bracketPattern = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)|\{[^}]*\}`)
+)
+
+// extensions stripped from file names before parsing
+var (
+	fileExtensions     = []string{".mkv", ".mp4", ".avi", ".m4v", ".ts", ".wmv", ".mov", ".webm", ".nzb", ".torrent"}
+	subtitleExtensions = []string{".srt", ".ass", ".ssa", ".sub", ".idx", ".sup", ".vtt"}
+	// subtitleFlags may follow the language of a sidecar subtitle e.g. movie.en.forced.srt
+	subtitleFlags = []string{"forced", "foreign", "sdh", "cc", "hi", "default", "full"}
+)
+
+// Parse extracts the language hints from a release title or file name
+func Parse(title string) Info {
+	title = path.Base(strings.ReplaceAll(title, `\`, "/"))
+	ext := strings.ToLower(path.Ext(title))
+	var sidecar []string
+	switch {
+	case slices.Contains(fileExtensions, ext):
+		title = title[:len(title)-len(ext)]
+	case slices.Contains(subtitleExtensions, ext):
+		title, sidecar, _ = SplitSidecar(title[:len(title)-len(ext)])
+	}
+
+	// bracketed groups are always metadata, the rest is metadata once the title has ended
+	var tokens []string
+	for _, group := range bracketPattern.FindAllString(title, -1) {
+		tokens = append(tokens, tokenize(group)...)
+	}
+	name, marked := stripTitle(tokenize(bracketPattern.ReplaceAllString(title, " ")))
+
+	p := parser{guessFrom: -1}
+	if !marked {
+		p.guessFrom = len(tokens)
+	}
+	p.parse(append(tokens, name...))
+	p.subs = append(p.subs, sidecar...)
+	return p.info()
+}
+
+// SplitSidecar splits the language and flag suffixes off the name of a sidecar subtitle without its extension,
+// e.g. movie.en.forced, the languages and flags are returned from last to first
+func SplitSidecar(name string) (string, []string, []string) {
+	var langs, flags []string
+	for {
+		dot := strings.LastIndex(name, ".")
+		if dot == -1 {
+			return name, langs, flags
+		}
+		suffix := strings.ToLower(name[dot+1:])
+		// hi is also the code of hindi, it is only a flag after a language e.g. movie.en.hi
+		if slices.Contains(subtitleFlags, suffix) && (suffix != "hi" || sidecarLanguage(name[:dot]) != "") {
+			flags = append(flags, suffix)
+			name = name[:dot]
+			continue
+		}
+		code := sidecarLanguage(name)
+		if code == "" {
+			return name, langs, flags
+		}
+		langs = append(langs, code)
+		name = name[:dot]
+	}
+}
+
+// sidecarLanguage returns the language of the last dot separated part of name, empty if it is none
+func sidecarLanguage(name string) string {
+	suffix := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	// region tags name the same language e.g. pt-BR
+	primary, _, _ := strings.Cut(suffix, "-")
+	l, ok := lang.Lookup(primary)
+	if !ok || l.Code == lang.Undetermined {
+		return ""
+	}
+	return l.Code
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stripTitle drops the tokens before the first marker, the first token is never a marker
+// so titles like "1917" or "24" are skipped. Language tags right before the marker are kept
+// for names like Movie.German.DL.1080p, unless they are all bare codes that may end the name
+// like The.Chi or Dan.Da.Dan. Without a marker nothing is dropped and false is returned.
+func stripTitle(tokens []string) ([]string, bool) {
+	for i := 1; i < len(tokens); i++ {
+		if !markerPattern.MatchString(tokens[i]) {
+			continue
+		}
+		start, explicit := i, false
+		for start > 1 && isLanguageTag(tokens[start-1]) {
+			start--
+			explicit = explicit || !isBareCode(tokens[start])
+		}
+		if !explicit {
+			start = i
+		}
+		return tokens[start:], true
+	}
+	return tokens, false
+}
+
+// isBareCode reports whether tok is a short language code like chi or dan, they are common words in names
+func isBareCode(tok string) bool {
+	return len(tok) <= 3 && !slices.Contains(languageWords, tok)
+}
+
+// isLanguageTag reports whether tok is part of the language tags of a release
+func isLanguageTag(tok string) bool {
+	if slices.Contains(languageWords, tok) {
+		return true
+	}
+	if _, ok := languageToken(tok); ok {
+		return true
+	}
+	var p parser
+	p.compound(tok)
+	return len(p.audio) > 0 || len(p.subs) > 0
+}
+
+type parser struct {
+	res   Info
+	audio []string
+	subs  []string
+	// guessFrom is the first token of a title without a marker, -1 if it has one
+	guessFrom int
+}
+
+func (p *parser) info() Info {
+	p.res.Audio = lang.NormalizeAll(p.audio)
+	p.res.Subtitles = lang.NormalizeAll(p.subs)
+	return p.res
+}
+
+func (p *parser) parse(tokens []string) {
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		if p.marker(tok, next, &i) {
+			continue
+		}
+
+		code, ok := languageToken(tok)
+		if !ok {
+			p.compound(tok)
+			continue
+		}
+
+		switch {
+		case slices.Contains(subWords, next):
+			p.subs = append(p.subs, code)
+			i++
+		case slices.Contains(dubWords, next):
+			p.audio = append(p.audio, code)
+			i++
+		case next == "dl":
+			// GER.DL is german plus the original audio
+			p.audio = append(p.audio, code)
+			p.res.DualAudio = true
+			i++
+		default:
+			p.audio = append(p.audio, code)
+			if p.guessFrom >= 0 && i >= p.guessFrom && isBareCode(tok) {
+				p.res.Ambiguous = true
+			}
+		}
+	}
+}
+
+// marker handles the tokens that are not languages, i is advanced when next is consumed
+func (p *parser) marker(tok, next string, i *int) bool {
+	switch tok {
+	case "multi":
+		if slices.Contains(subWords, next) {
+			p.res.MultiSubs = true
+			*i++
+		} else {
+			p.res.MultiAudio = true
+		}
+	case "multisub", "multisubs", "multisubbed":
+		p.res.MultiSubs = true
+	case "dual", "dualaudio":
+		if next == "audio" {
+			*i++
+		}
+		p.res.DualAudio = true
+	case "hard":
+		if !slices.Contains(subWords, next) && next != "coded" {
+			return false
+		}
+		p.res.Hardsub = true
+		*i++
+	case "hc", "hardsub", "hardsubs", "hardsubbed", "hardcoded":
+		p.res.Hardsub = true
+	case "softsub", "softsubs", "sub", "subs", "subbed", "subtitled":
+		p.res.Subbed = true
+	case "nordic":
+		p.res.MultiSubs = true
+	case "nosub", "nosubs", "raw":
+		// raws have no subtitles, nothing to record
+	case "dubbed":
+		p.res.Dubbed = true
+	default:
+		return false
+	}
+	return true
+}
+
+// compound handles tokens that glue a language to sub or dub e.g. ENGSUB, KORSUB, SUBITA or PLDUB
+func (p *parser) compound(tok string) {
+	if code, ok := subtitleAliases[tok]; ok {
+		p.subs = append(p.subs, code)
+		return
+	}
+	if strings.HasPrefix(tok, "vost") {
+		if code, ok := shortLanguage(strings.TrimPrefix(tok, "vost")); ok {
+			p.subs = append(p.subs, code)
+		}
+		return
+	}
+
+	for _, suffix := range subSuffix {
+		if code, ok := shortLanguage(strings.TrimSuffix(tok, suffix)); ok && strings.HasSuffix(tok, suffix) {
+			p.subs = append(p.subs, code)
+			// korean scene releases are hardsubbed
+			if code == "kor" {
+				p.res.Hardsub = true
+			}
+			return
+		}
+	}
+	if code, ok := shortLanguage(strings.TrimPrefix(tok, "sub")); ok && strings.HasPrefix(tok, "sub") {
+		p.subs = append(p.subs, code)
+		return
+	}
+	for _, suffix := range dubSuffix {
+		if code, ok := shortLanguage(strings.TrimSuffix(tok, suffix)); ok && strings.HasSuffix(tok, suffix) {
+			p.audio = append(p.audio, code)
+			return
+		}
+	}
+}
+
+// languageToken resolves a standalone token, only full names and well known scene abbreviations count
+// since short codes like "it" or "no" are ordinary words
+func languageToken(tok string) (string, bool) {
+	if code, ok := audioAliases[tok]; ok {
+		return code, true
+	}
+	if l, ok := lang.Lookup(tok); ok && slices.Contains(l.Names, tok) && l.Code != lang.Undetermined {
+		return l.Code, true
+	}
+	return "", false
+}
+
+// shortLanguage resolves the language part of a compound token
+func shortLanguage(tok string) (string, bool) {
+	if tok == "" {
+		return "", false
+	}
+	if code, ok := shortAliases[tok]; ok {
+		return code, true
+	}
+	return languageToken(tok)
+}
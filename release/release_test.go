@@ -0,0 +1,141 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		title string
+		want  Info
+	}{
+		// scene tv
+		{"Show.S01E01.1080p.WEB.h264-GROUP", Info{}},
+		{"Show.S01E01.MULTi.1080p.WEB.h264-GROUP", Info{MultiAudio: true}},
+		{"Show.S01E01.German.DL.1080p.WEB.h264-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Show.S01E01.GER.DL.720p.HDTV.x264-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Show.S01E01.German.1080p.WEB.h264-GROUP", Info{Audio: []string{"ger"}}},
+		{"Show.S02E05.iTA.ENG.1080p.AMZN.WEB-DL.DDP5.1.H.264-GROUP", Info{Audio: []string{"ita", "eng"}}},
+		{"Show.S02E05.ITA.1080p.WEB-DL.x264-GROUP", Info{Audio: []string{"ita"}}},
+		{"Show.S01E01.FRENCH.720p.HDTV.x264-GROUP", Info{Audio: []string{"fre"}}},
+		{"Show.S01E01.VOSTFR.1080p.WEB.x264-GROUP", Info{Subtitles: []string{"fre"}}},
+		{"Show.S01E01.SUBFRENCH.720p.HDTV.x264-GROUP", Info{Subtitles: []string{"fre"}}},
+		{"Show.S01E01.MULTi.VFF.1080p.BluRay.x264-GROUP", Info{Audio: []string{"fre"}, MultiAudio: true}},
+		{"Show.S01E01.TRUEFRENCH.1080p.BluRay.x264-GROUP", Info{Audio: []string{"fre"}}},
+		{"Show.S01E01.SWESUB.720p.HDTV.x264-GROUP", Info{Subtitles: []string{"swe"}}},
+		{"Show.S01E01.NLSUB.720p.HDTV.x264-GROUP", Info{Subtitles: []string{"dut"}}},
+		{"Show.S01E01.DKSUBS.720p.HDTV.x264-GROUP", Info{Subtitles: []string{"dan"}}},
+		{"Show.S01E01.NORDiC.1080p.WEB-DL.H.264-GROUP", Info{MultiSubs: true}},
+		{"Show.S01E01.KORSUB.720p.HDTV.x264-GROUP", Info{Subtitles: []string{"kor"}, Hardsub: true}},
+		{"Show.S01E01.HC.1080p.WEB.h264-GROUP", Info{Hardsub: true}},
+		{"Show.S01E01.PLDUB.720p.WEB.x264-GROUP", Info{Audio: []string{"pol"}}},
+		{"Show.S01E01.SPANiSH.1080p.WEB.x264-GROUP", Info{Audio: []string{"spa"}}},
+		{"Show.S01E01.Castellano.1080p.WEB-DL.x264-GROUP", Info{Audio: []string{"spa"}}},
+		{"Show.S01E01.Latino.1080p.WEB-DL.x264-GROUP", Info{Audio: []string{"spa"}}},
+		{"Show.S01E01.RUS.ENG.1080p.WEB-DL-GROUP", Info{Audio: []string{"rus", "eng"}}},
+		{"Show.S01E01.Hindi.Tamil.Telugu.1080p.WEB-DL-GROUP", Info{Audio: []string{"hin", "tam", "tel"}}},
+		{"Show.S01E01.DUAL.1080p.WEB-DL-GROUP", Info{DualAudio: true}},
+		{"Show.S01.COMPLETE.German.DL.1080p.BluRay.x264-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Show.S01E01.1080p.WEB.h264.ENGSUB-GROUP", Info{Subtitles: []string{"eng"}}},
+
+		// scene movies
+		{"Movie.2019.1080p.BluRay.x264-GROUP", Info{}},
+		{"Movie.2019.German.DL.1080p.BluRay.x264-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Movie.German.DL.1080p.BluRay.x264-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Movie.2019.MULTi.2160p.UHD.BluRay.x265-GROUP", Info{MultiAudio: true}},
+		{"Movie.2019.iTALiAN.1080p.BluRay.x264-GROUP", Info{Audio: []string{"ita"}}},
+		{"Movie.2019.SUBiTA.1080p.WEB.x264-GROUP", Info{Subtitles: []string{"ita"}}},
+		{"Movie.2019.SPANISH.1080p.WEBRip.x264-GROUP", Info{Audio: []string{"spa"}}},
+		{"Movie.2019.VOSE.1080p.WEBRip.x264-GROUP", Info{Subtitles: []string{"spa"}}},
+		{"Movie.2019.DUBBED.1080p.WEBRip.x264-GROUP", Info{Dubbed: true}},
+		{"Movie.2019.SUBBED.1080p.WEBRip.x264-GROUP", Info{Subbed: true}},
+		{"Movie.2019.1080p.WEBRip.x264.HC-GROUP", Info{Hardsub: true}},
+		{"Movie.2019.HARDSUB.720p.WEBRip.x264-GROUP", Info{Hardsub: true}},
+		{"Movie.2019.KOREAN.1080p.WEBRip.x264-GROUP", Info{Audio: []string{"kor"}}},
+		{"Movie.2019.Japanese.1080p.BluRay.x264-GROUP", Info{Audio: []string{"jpn"}}},
+		{"Movie.2019.CHINESE.1080p.BluRay.x264-GROUP", Info{Audio: []string{"chi"}}},
+		{"Movie.2019.Mandarin.1080p.WEB-DL-GROUP", Info{Audio: []string{"chi"}}},
+		{"Movie.2019.PORTUGUESE.1080p.WEB-DL-GROUP", Info{Audio: []string{"por"}}},
+		{"Movie.2019.DUTCH.1080p.WEB-DL-GROUP", Info{Audio: []string{"dut"}}},
+		{"Movie.2019.TURKISH.1080p.WEB-DL-GROUP", Info{Audio: []string{"tur"}}},
+		{"Movie.2019.POLISH.1080p.WEB-DL-GROUP", Info{Audio: []string{"pol"}}},
+		{"Movie.2019.HUNGARIAN.1080p.WEB-DL-GROUP", Info{Audio: []string{"hun"}}},
+		{"Movie.2019.CZECH.1080p.WEB-DL-GROUP", Info{Audio: []string{"cze"}}},
+		{"Movie.2019.RUSSIAN.1080p.WEB-DL-GROUP", Info{Audio: []string{"rus"}}},
+		{"Movie.2019.FiNNiSH.1080p.WEB-DL-GROUP", Info{Audio: []string{"fin"}}},
+		{"Movie.2019.SWEDiSH.1080p.WEB-DL-GROUP", Info{Audio: []string{"swe"}}},
+		{"Movie.2019.NORWEGiAN.1080p.WEB-DL-GROUP", Info{Audio: []string{"nor"}}},
+		{"Movie.2019.DANiSH.1080p.WEB-DL-GROUP", Info{Audio: []string{"dan"}}},
+		{"Movie 2019 1080p BluRay English German DTS x264", Info{Audio: []string{"eng", "ger"}}},
+
+		// language names in the title are not hints
+		{"The.English.Patient.1996.1080p.BluRay.x264-GROUP", Info{}},
+		{"The.Italian.Job.2003.720p.BluRay.x264-GROUP", Info{}},
+		{"French.Kiss.1995.1080p.WEB-DL-GROUP", Info{}},
+		{"The.Danish.Girl.2015.German.DL.1080p.BluRay-GROUP", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{"Portuguese.Man.O.War.S01E01.1080p.WEB-GROUP", Info{}},
+		{"1917.2019.1080p.BluRay.x264-GROUP", Info{}},
+		{"It.2017.1080p.BluRay.x264-GROUP", Info{}},
+		{"No.Time.to.Die.2021.1080p.BluRay-GROUP", Info{}},
+		{"Dan.in.Real.Life.2007.720p.BluRay-GROUP", Info{}},
+		{"Dan.Da.Dan.S01E01.1080p.WEB.H264-GRP", Info{}},
+		{"The.Chi.S06E01.1080p.WEB.h264-GRP", Info{}},
+		{"The Chi", Info{Audio: []string{"chi"}, Ambiguous: true}},
+		{"Movie German", Info{Audio: []string{"ger"}}},
+		{"Show.ITA.1080p.WEB-DL.x264-GROUP", Info{}},
+		{"Movie.ITA.ENG.DL.1080p.BluRay-GROUP", Info{Audio: []string{"ita", "eng"}, DualAudio: true}},
+
+		// anime
+		{"[SubsPlease] Sousou no Frieren - 05 (1080p) [C2A8F7B1]", Info{}},
+		{"[Erai-raws] Sousou no Frieren - 05 [1080p][Multiple Subtitle][C2A8F7B1]", Info{}},
+		{"[Group] Show - 01 (1080p) [Dual Audio]", Info{DualAudio: true}},
+		{"[Group] Show - 01 [1080p] [Dual-Audio] [Multi-Sub]", Info{DualAudio: true, MultiSubs: true}},
+		{"[Group] Show - 01 [Eng Sub] [720p]", Info{Subtitles: []string{"eng"}}},
+		{"[Group] Show - 01 [English Sub][1080p]", Info{Subtitles: []string{"eng"}}},
+		{"[Group] Show - 01 (English Dub) [1080p]", Info{Audio: []string{"eng"}}},
+		{"[Group] Show - 01 [English Dubbed] [1080p]", Info{Audio: []string{"eng"}}},
+		{"[Group] Show - 01 [1080p] [EngSub]", Info{Subtitles: []string{"eng"}}},
+		{"[Group] Show - 01 [1080p][HardSub]", Info{Hardsub: true}},
+		{"[Group] Show - 01 [1080p][Hard Sub]", Info{Hardsub: true}},
+		{"[Group] Show - 01 [1080p][Multi Subs]", Info{MultiSubs: true}},
+		{"[Group] Show - 01 [1080p][MultiSub]", Info{MultiSubs: true}},
+		{"[Group] Show - 01 [1080p][Japanese Audio][English Subs]", Info{Audio: []string{"jpn"}, Subtitles: []string{"eng"}}},
+		{"[Group] Show - 01 [RAW][1080p]", Info{}},
+		{"[Group] Show S01E01 [1080p][CHS]", Info{Subtitles: []string{"chi"}}},
+		{"[Group] Show - 01 [BIG5][1080p]", Info{Subtitles: []string{"chi"}}},
+		{"[Group] Show - 01 [1080p][ENG][JPN]", Info{Audio: []string{"eng", "jpn"}}},
+		{"Show.S01E01.Dual.Audio.1080p.BluRay.x264-GROUP", Info{DualAudio: true}},
+		{"Show S01E01 1080p Japanese Audio English Subtitles", Info{Audio: []string{"jpn"}, Subtitles: []string{"eng"}}},
+		{"Show S01E01 [1080p] [Subbed]", Info{Subbed: true}},
+		{"Show S01E01 [1080p] [Dubbed]", Info{Dubbed: true}},
+
+		// file names
+		{"/downloads/complete/Show.S01E01.German.DL.1080p.WEB.h264-GROUP.mkv", Info{Audio: []string{"ger"}, DualAudio: true}},
+		{`C:\Downloads\Movie.2019.MULTi.1080p.BluRay.x264-GROUP.mkv`, Info{MultiAudio: true}},
+		{"Show - S01E01 - Pilot [WEBDL-1080p][EAC3 5.1][h264]-GROUP.mkv", Info{}},
+		{"Movie (2019) [Bluray-1080p][DTS 5.1][x264][EN+DE]-GROUP.mkv", Info{}},
+
+		// sidecar subtitles
+		{"Show.S01E01.1080p.WEB.h264-GROUP.eng.srt", Info{Subtitles: []string{"eng"}}},
+		{"Movie (2019).en.forced.srt", Info{Subtitles: []string{"eng"}}},
+		{"Movie (2019).de.sdh.ass", Info{Subtitles: []string{"ger"}}},
+		{"Movie.2019.German.DL.1080p.BluRay-GROUP.ger.srt", Info{Audio: []string{"ger"}, Subtitles: []string{"ger"}, DualAudio: true}},
+		{"Movie (2019).srt", Info{}},
+		{"Show.S01E01.hi.srt", Info{Subtitles: []string{"hin"}}},
+		{"Show.S01E01.en.hi.srt", Info{Subtitles: []string{"eng"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.want, Parse(tt.title))
+		})
+	}
+}
+
+func TestInfo_HasUnlistedAudio(t *testing.T) {
+	assert.True(t, Parse("Movie.2019.MULTi.1080p.BluRay").HasUnlistedAudio())
+	assert.True(t, Parse("Show.S01E01.German.DL.1080p.WEB").HasUnlistedAudio())
+	assert.False(t, Parse("Show.S01E01.German.1080p.WEB").HasUnlistedAudio())
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/radarr"
+	"github.com/RA341/warden/api/sonarr"
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/release"
+	"github.com/rs/zerolog/log"
+)
+
+const noCompliantRelease = "no compliant release available"
+
+// releaseCandidate is a release returned by an interactive search
+type releaseCandidate struct {
+	GUID      string
+	IndexerID int
+	Title     string
+	// Languages are the languages the *arr app parsed from the title
+	Languages []string
+	// CustomFormats are the names of the matched custom formats
+	CustomFormats     []string
+	CustomFormatScore int
+	Rejections        []string
+	Rejected          bool
+}
+
+func sonarrCandidates(releases []sonarr.Release) []releaseCandidate {
+	res := make([]releaseCandidate, 0, len(releases))
+	for _, rel := range releases {
+		res = append(res, newReleaseCandidate(
+			rel.GUID, rel.IndexerID, rel.Title, rel.Languages, rel.CustomFormats, rel.CustomFormatScore, rel.Rejected, rel.Rejections,
+		))
+	}
+	return res
+}
+
+func radarrCandidates(releases []radarr.Release) []releaseCandidate {
+	res := make([]releaseCandidate, 0, len(releases))
+	for _, rel := range releases {
+		res = append(res, newReleaseCandidate(
+			rel.GUID, rel.IndexerID, rel.Title, rel.Languages, rel.CustomFormats, rel.CustomFormatScore, rel.Rejected, rel.Rejections,
+		))
+	}
+	return res
+}
+
+func newReleaseCandidate(guid string, indexerID int, title string, languages []arr.Language, formats []arr.CustomFormat, score int, rejected bool, rejections []string) releaseCandidate {
+	c := releaseCandidate{
+		GUID:              guid,
+		IndexerID:         indexerID,
+		Title:             title,
+		CustomFormatScore: score,
+		Rejected:          rejected,
+		Rejections:        rejections,
+	}
+	for _, l := range languages {
+		c.Languages = append(c.Languages, l.Name)
+	}
+	for _, cf := range formats {
+		c.CustomFormats = append(c.CustomFormats, cf.Name)
+	}
+	return c
+}
+
+// grabbable reports whether a search could download the release once the current file is gone,
+// rejections caused by the existing file do not count since it is deleted first
+func (c releaseCandidate) grabbable() bool {
+	if !c.Rejected {
+		return true
+	}
+	for _, reason := range c.Rejections {
+		if !strings.Contains(strings.ToLower(reason), "existing file") {
+			return false
+		}
+	}
+	return len(c.Rejections) > 0
+}
+
+// audioLanguages estimates the audio languages of the release from the languages parsed by the *arr app,
+// the title and the custom format names, multi is set when the release has more audio tracks than it lists.
+// originalLanguage resolves the "Original" language used by sonarr v4
+func (c releaseCandidate) audioLanguages(originalLanguage string) (langs []string, multi bool) {
+	for _, name := range c.Languages {
+		switch strings.ToLower(name) {
+		case "unknown", "":
+			continue
+		case "original":
+			name = originalLanguage
+		}
+		langs = append(langs, name)
+	}
+
+	parsed := release.Parse(c.Title)
+	langs = append(langs, parsed.Audio...)
+	multi = parsed.HasUnlistedAudio()
+
+	// custom formats are names like "German DL" or "Language: English" rather than release titles
+	for _, cf := range c.CustomFormats {
+		parsed = release.Parse(cf)
+		langs = append(langs, parsed.Audio...)
+		multi = multi || parsed.HasUnlistedAudio()
+	}
+
+	return lang.NormalizeAll(langs), multi
+}
+
+type releaseFit int
+
+const (
+	releaseUnfit releaseFit = iota
+	// releasePossible has unlisted audio tracks that may include the required languages
+	releasePossible
+	// releaseExplicit lists every required language
+	releaseExplicit
+)
+
+// releaseFit estimates how well the release meets the audio requirements of the profile,
+// subtitles are rarely part of a release title so they are never used to rule a release out
+func (p *Profile) releaseFit(c releaseCandidate, originalLanguage string) releaseFit {
+	langs, multi := c.audioLanguages(originalLanguage)
+	switch {
+	case isSubset(langs, lang.NormalizeAll(p.RequiredLanguagesAudio)):
+		return releaseExplicit
+	case multi:
+		return releasePossible
+	default:
+		return releaseUnfit
+	}
+}
+
+// plausiblySatisfies reports whether the release could meet the audio requirements of the profile
+func (p *Profile) plausiblySatisfies(c releaseCandidate, originalLanguage string) bool {
+	return p.releaseFit(c, originalLanguage) != releaseUnfit
+}
+
+// findCompliantReleases returns the grabbable releases that could satisfy the profile
+func findCompliantReleases(candidates []releaseCandidate, prof *Profile, originalLanguage string) []releaseCandidate {
+	var res []releaseCandidate
+	for _, c := range candidates {
+		if c.grabbable() && prof.plausiblySatisfies(c, originalLanguage) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// rankReleases orders the releases that could satisfy the profile from best to worst,
+// releases listing every required language come first, otherwise the order of the *arr app is kept
+// since it already ranks by quality and custom format score
+func rankReleases(candidates []releaseCandidate, prof *Profile, originalLanguage string) []releaseCandidate {
+	ranked := findCompliantReleases(candidates, prof, originalLanguage)
+	slices.SortStableFunc(ranked, func(a, b releaseCandidate) int {
+		return cmp.Or(
+			cmp.Compare(prof.releaseFit(b, originalLanguage), prof.releaseFit(a, originalLanguage)),
+			compareBool(!a.Rejected, !b.Rejected),
+		)
+	})
+	return ranked
+}
+
+// grabBestRelease grabs the highest ranked release for the remediation, when no release qualifies
+// or the *arr app refuses the grab it falls back to a regular search
+func grabBestRelease(
+	ctx context.Context,
+	rec *PendingRemediation,
+	search func(context.Context) ([]releaseCandidate, error),
+	grab func(context.Context, releaseCandidate) error,
+	fallback func(context.Context) error,
+) error {
+	if rec.Release == nil {
+		return fallback(ctx)
+	}
+
+	candidates, err := search(ctx)
+	if err != nil {
+		return err
+	}
+
+	prof := &Profile{RequiredLanguagesAudio: rec.Release.RequiredLanguagesAudio}
+	ranked := rankReleases(candidates, prof, rec.Release.OriginalLanguage)
+	if len(ranked) == 0 {
+		log.Info().Int("candidates", len(candidates)).Msg("No release could satisfy the profile, falling back to a search")
+		return fallback(ctx)
+	}
+
+	best := ranked[0]
+	log.Info().Str("release", best.Title).Str("guid", best.GUID).Msg("Grabbing release")
+	if err = grab(ctx, best); err != nil {
+		// unreachable instances are retried on resume, refused releases e.g. expired from the release cache are not
+		if status := arr.StatusCode(err); status == 0 || status >= 500 {
+			return err
+		}
+		log.Warn().Err(err).Msg("Release was refused, falling back to a search")
+		return fallback(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile_PlausiblySatisfies(t *testing.T) {
+	prof := &Profile{RequiredLanguagesAudio: []string{"eng", "jpn"}}
+
+	tests := []struct {
+		name      string
+		candidate releaseCandidate
+		want      bool
+	}{
+		{"parsed languages", releaseCandidate{Title: "Show.S01E01.1080p", Languages: []string{"English", "Japanese"}}, true},
+		{"original language", releaseCandidate{Title: "Show.S01E01.1080p", Languages: []string{"English", "Original"}}, true},
+		{"languages in title", releaseCandidate{Title: "Show S01E01 1080p English Japanese"}, true},
+		{"dual audio", releaseCandidate{Title: "[Group] Show - 01 (1080p) [Dual Audio]"}, true},
+		{"multi", releaseCandidate{Title: "Show.S01E01.MULTi.1080p.WEB-DL"}, true},
+		{"custom format", releaseCandidate{Title: "Show.S01E01.1080p", Languages: []string{"Japanese"}, CustomFormats: []string{"Language: English"}}, true},
+		{"web-dl is not dual", releaseCandidate{Title: "Show.S01E01.1080p.WEB-DL", Languages: []string{"Japanese"}}, false},
+		{"missing language", releaseCandidate{Title: "Show.S01E01.1080p", Languages: []string{"Japanese"}}, false},
+		{"unknown language", releaseCandidate{Title: "Show.S01E01.1080p", Languages: []string{"Unknown"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, prof.plausiblySatisfies(tt.candidate, "Japanese"))
+		})
+	}
+}
+
+func TestReleaseCandidate_Grabbable(t *testing.T) {
+	assert.True(t, releaseCandidate{}.grabbable())
+	assert.True(t, releaseCandidate{Rejected: true, Rejections: []string{"Existing file on disk is of equal or higher preference: WEBDL-1080p"}}.grabbable())
+	assert.False(t, releaseCandidate{Rejected: true, Rejections: []string{"Release is blocklisted"}}.grabbable())
+	assert.False(t, releaseCandidate{Rejected: true}.grabbable())
+}
+
+func newPreflightRadarr(t *testing.T, releases []map[string]any) (*RadarrInst, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/moviefile/{id}", http.StatusOK, map[string]any{
+		"id":        42,
+		"mediaInfo": map[string]any{"audioLanguages": "rus", "audioStreamCount": 1, "subtitles": "eng"},
+	})
+	server.JSON("GET /api/v3/release", http.StatusOK, releases)
+	server.JSON("DELETE /api/v3/moviefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/movie/editor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewRadarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		name:     "radarr",
+		LanguageMap: map[string]*Profile{
+			"/media/movies": {RequiredLanguagesAudio: []string{"eng"}, PreflightSearch: true},
+		},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	return cli, server
+}
+
+func TestRadarr_PreflightKeepsFileWithoutCompliantRelease(t *testing.T) {
+	cli, server := newPreflightRadarr(t, []map[string]any{
+		{"guid": "a", "title": "Alien.1979.1080p.BluRay.RUS", "languages": []map[string]any{{"id": 11, "name": "Russian"}}},
+		{"guid": "b", "title": "Alien.1979.1080p.BluRay", "rejected": true, "rejections": []string{"Release is blocklisted"},
+			"languages": []map[string]any{{"id": 1, "name": "English"}}},
+	})
+	info, err := cli.ParseJson([]byte(radarrTestPayload))
+	require.NoError(t, err)
+
+	cli.RunCheck(context.Background(), info)
+
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/release"), 1)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/moviefile/42"))
+
+	skipped, ok := cli.remediator.skipped.Get("radarr:42")
+	require.True(t, ok)
+	assert.Equal(t, noCompliantRelease, skipped.Reason)
+	assert.Equal(t, 7, skipped.MediaID)
+}
+
+func TestRadarr_PreflightDeletesWithCompliantRelease(t *testing.T) {
+	cli, server := newPreflightRadarr(t, []map[string]any{
+		{"guid": "a", "title": "Alien.1979.1080p.BluRay", "rejected": true,
+			"rejections": []string{"Existing file meets cutoff: Bluray-1080p"},
+			"languages":  []map[string]any{{"id": 1, "name": "English"}}},
+	})
+	cli.remediator.Skip(7, "42", noCompliantRelease)
+	info, err := cli.ParseJson([]byte(radarrTestPayload))
+	require.NoError(t, err)
+
+	cli.RunCheck(context.Background(), info)
+
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/moviefile/42"), 1)
+	_, ok := cli.remediator.skipped.Get("radarr:42")
+	assert.False(t, ok, "skip record should be cleared once the file is remediated")
+}
+
+func TestRankReleases(t *testing.T) {
+	prof := &Profile{RequiredLanguagesAudio: []string{"eng"}}
+	ranked := rankReleases([]releaseCandidate{
+		{GUID: "jpn", Languages: []string{"Japanese"}},
+		{GUID: "multi", Title: "Show.S01E01.MULTi.1080p"},
+		{GUID: "rejected", Languages: []string{"English"}, Rejected: true, Rejections: []string{"Existing file meets cutoff"}},
+		{GUID: "eng", Languages: []string{"English"}},
+		{GUID: "blocked", Languages: []string{"English"}, Rejected: true, Rejections: []string{"Release is blocklisted"}},
+	}, prof, "Japanese")
+
+	var guids []string
+	for _, c := range ranked {
+		guids = append(guids, c.GUID)
+	}
+	assert.Equal(t, []string{"eng", "rejected", "multi"}, guids)
+}
+
+func newGrabSonarr(t *testing.T, releases []map[string]any) (*SonarrInst, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)
+	server.JSON("GET /api/v3/release", http.StatusOK, releases)
+	server.JSON("POST /api/v3/release", http.StatusOK, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewSonarr(&ArrInstance{BasePath: server.URL, ApiKey: arrtest.APIKey})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	return cli, server
+}
+
+func TestSonarr_GrabsBestRelease(t *testing.T) {
+	cli, server := newGrabSonarr(t, []map[string]any{
+		{"guid": "jpn", "indexerId": 1, "title": "Show.S01E01.1080p", "languages": []map[string]any{{"id": 8, "name": "Japanese"}}},
+		{"guid": "dual", "indexerId": 2, "title": "[Group] Show - 01 [Dual Audio]"},
+		{"guid": "eng", "indexerId": 3, "title": "Show.S01E01.720p", "languages": []map[string]any{{"id": 1, "name": "English"}}},
+	})
+
+	prof := &Profile{RequiredLanguagesAudio: []string{"eng"}, SearchMode: SearchModeGrab}
+	cli.Remediate(context.Background(), &SonarMediaInfo{EpisodeID: 5, EpisodeFileID: "9"}, prof, "missing required languages", nil)
+
+	grabs := server.RequestsTo(http.MethodPost, "/api/v3/release")
+	require.Len(t, grabs, 1)
+	assert.JSONEq(t, `{"guid": "eng", "indexerId": 3}`, string(grabs[0].Body))
+	assert.Empty(t, server.RequestsTo(http.MethodPost, "/api/v3/command"))
+	assert.Empty(t, cli.remediator.pending())
+}
+
+func TestSonarr_GrabFallsBackToSearch(t *testing.T) {
+	cli, server := newGrabSonarr(t, []map[string]any{
+		{"guid": "jpn", "indexerId": 1, "title": "Show.S01E01.1080p", "languages": []map[string]any{{"id": 8, "name": "Japanese"}}},
+	})
+
+	prof := &Profile{RequiredLanguagesAudio: []string{"eng"}, SearchMode: SearchModeGrab}
+	cli.Remediate(context.Background(), &SonarMediaInfo{EpisodeID: 5, EpisodeFileID: "9"}, prof, "missing required languages", nil)
+
+	assert.Empty(t, server.RequestsTo(http.MethodPost, "/api/v3/release"))
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/command"), 1)
+}
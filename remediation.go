@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/rs/zerolog/log"
+)
+
+const remediationResumeInterval = time.Minute
+
+type RemediationStep = string
+
+const (
+	StepDelete  RemediationStep = "delete"
+	StepMonitor RemediationStep = "monitor"
+	StepSearch  RemediationStep = "search"
+	// StepGrab downloads the best compliant release, falling back to StepSearch if none qualify
+	StepGrab RemediationStep = "grab"
+	// StepSubtitleSearch asks bazarr for the missing subtitles
+	StepSubtitleSearch RemediationStep = "subtitle-search"
+	// StepSubtitleRecheck waits for bazarr and ends the sequence if the subtitles were found
+	StepSubtitleRecheck RemediationStep = "subtitle-recheck"
+	// StepBlocklist marks the grab of the file as failed so the release is never grabbed again
+	StepBlocklist RemediationStep = "blocklist"
+	// StepQuarantine moves the file out of the library instead of deleting it
+	StepQuarantine RemediationStep = "quarantine"
+	// StepRescan makes the *arr app notice a file that was moved away and waits for the rescan to finish
+	StepRescan    RemediationStep = "rescan"
+	StepTag       RemediationStep = "tag"
+	StepUnmonitor RemediationStep = "unmonitor"
+	StepNotify    RemediationStep = "notify"
+	StepExec      RemediationStep = "exec"
+)
+
+var (
+	// errStepWaiting keeps the step pending without counting a failed attempt, it runs again on the next resume
+	errStepWaiting = errors.New("remediation step is waiting")
+	// errRemediationResolved ends the sequence early, the file no longer needs the remaining steps
+	errRemediationResolved = errors.New("remediation is no longer needed")
+	// errRemediationConfig fails a step that cannot succeed until the config changes
+	errRemediationConfig = errors.New("remediation is misconfigured")
+)
+
+// maxRemediationAttempts is how often a failing step is retried before the sequence is given up
+const maxRemediationAttempts = 10
+
+// ReleaseCriteria is what a grabbed release is ranked against,
+// it is stored with the remediation so a resumed grab does not need the profile
+type ReleaseCriteria struct {
+	RequiredLanguagesAudio []string `json:"required_languages_audio"`
+	OriginalLanguage       string   `json:"original_language,omitempty"`
+}
+
+func newReleaseCriteria(prof *Profile, originalLanguage string) *ReleaseCriteria {
+	return &ReleaseCriteria{
+		RequiredLanguagesAudio: prof.RequiredLanguagesAudio,
+		OriginalLanguage:       originalLanguage,
+	}
+}
+
+// PendingRemediation is a remediation sequence that has not completed yet,
+// it is persisted before the first step runs so an interrupted sequence can be resumed
+type PendingRemediation struct {
+	Instance string `json:"instance"`
+	// MediaID is the episode or movie id
+	MediaID int `json:"media_id"`
+	// FileID is the episode file or movie file id
+	FileID string `json:"file_id"`
+	// SeriesID is the sonarr series of the episode, 0 when unknown
+	SeriesID int `json:"series_id,omitempty"`
+	// FilePath is the path of the file as reported by the *arr app
+	FilePath string `json:"file_path,omitempty"`
+	// Reason is why the file failed its profile
+	Reason string `json:"reason,omitempty"`
+	// Steps that still have to run, in order
+	Steps []RemediationStep `json:"steps"`
+	// Actions holds the configured action of each step, steps without one are retried on failure
+	Actions map[RemediationStep]RemediationAction `json:"actions,omitempty"`
+	// Release is set when the sequence grabs a release
+	Release *ReleaseCriteria `json:"release,omitempty"`
+	// Subtitles is set when the sequence searches subtitles
+	Subtitles *SubtitleCriteria `json:"subtitles,omitempty"`
+	// NotBefore delays the next step, e.g. while bazarr downloads subtitles
+	NotBefore time.Time `json:"not_before,omitempty"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+func (p *PendingRemediation) Key() string {
+	return p.Instance + ":" + p.FileID
+}
+
+// SkippedRemediation records a file that failed its profile but was kept
+type SkippedRemediation struct {
+	Instance string    `json:"instance"`
+	MediaID  int       `json:"media_id"`
+	FileID   string    `json:"file_id"`
+	Reason   string    `json:"reason"`
+	Checked  time.Time `json:"checked"`
+	// Attempts counts the replacements of a media item because of its default tracks
+	Attempts int `json:"attempts,omitempty"`
+}
+
+func (s *SkippedRemediation) Key() string {
+	return s.Instance + ":" + s.FileID
+}
+
+// remediationTarget runs a single remediation step against an instance
+type remediationTarget interface {
+	runStep(ctx context.Context, rec *PendingRemediation, step RemediationStep) error
+}
+
+// remediator runs remediation sequences for one instance and resumes
+// the ones left incomplete once the instance is healthy again
+type remediator struct {
+	instance string
+	store    *jsonStore[PendingRemediation]
+	skipped  *jsonStore[SkippedRemediation]
+	target   remediationTarget
+	api      *arr.Client
+
+	mu sync.Mutex
+	// running holds the keys of the sequences currently running, a sequence never runs twice at once
+	running map[string]bool
+}
+
+// acquire marks the sequence of key as running, false if it already is
+func (r *remediator) acquire(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running[key] {
+		return false
+	}
+	if r.running == nil {
+		r.running = map[string]bool{}
+	}
+	r.running[key] = true
+	return true
+}
+
+func (r *remediator) release(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.running, key)
+}
+
+// Start persists a new sequence for the file and runs it
+func (r *remediator) Start(ctx context.Context, mediaID int, fileID string, release *ReleaseCriteria, steps ...RemediationStep) error {
+	return r.start(ctx, PendingRemediation{MediaID: mediaID, FileID: fileID, Steps: steps, Release: release})
+}
+
+func (r *remediator) start(ctx context.Context, rec PendingRemediation) error {
+	now := time.Now()
+	rec.Instance = r.instance
+	rec.Steps = slices.Clone(rec.Steps)
+	rec.Created = now
+	rec.Updated = now
+
+	if !r.acquire(rec.Key()) {
+		log.Info().Msgf("Remediation for file %s is already running", rec.FileID)
+		return nil
+	}
+	defer r.release(rec.Key())
+
+	r.skipped.Delete(rec.Key())
+	if existing, ok := r.store.Get(rec.Key()); ok {
+		log.Warn().Msgf("Remediation for file %s is already pending, resuming it instead", rec.FileID)
+		rec = existing
+	} else if rec.Reason == defaultTracksMismatch && replacesFile(rec.Steps) && !r.replaceDefaultTracks(rec.MediaID, rec.FileID) {
+		return nil
+	}
+
+	r.store.Put(rec.Key(), rec)
+	return r.run(ctx, rec)
+}
+
+// maxDefaultTrackReplacements limits how often a media item is replaced because of its default tracks,
+// release titles say nothing about default flags so every replacement may fail the same way
+const maxDefaultTrackReplacements = 2
+
+func (r *remediator) defaultTracksKey(mediaID int) string {
+	return fmt.Sprintf("%s:default-tracks:%d", r.instance, mediaID)
+}
+
+// replaceDefaultTracks counts a replacement of the media item because of its default tracks,
+// once the replacements run out the file is kept and recorded as skipped
+func (r *remediator) replaceDefaultTracks(mediaID int, fileID string) bool {
+	key := r.defaultTracksKey(mediaID)
+	count, _ := r.skipped.Get(key)
+	if count.Attempts >= maxDefaultTrackReplacements {
+		r.Skip(mediaID, fileID, fmt.Sprintf("%s after %d replacements", defaultTracksMismatch, count.Attempts))
+		return false
+	}
+
+	r.skipped.Put(key, SkippedRemediation{
+		Instance: r.instance,
+		MediaID:  mediaID,
+		FileID:   fileID,
+		Reason:   defaultTracksMismatch,
+		Checked:  time.Now(),
+		Attempts: count.Attempts + 1,
+	})
+	return true
+}
+
+// defaultTracksMatched forgets the default track replacements of a media item whose file passed
+func (r *remediator) defaultTracksMatched(mediaID int) {
+	r.skipped.Delete(r.defaultTracksKey(mediaID))
+}
+
+// Skip records that the file was kept instead of remediated
+func (r *remediator) Skip(mediaID int, fileID string, reason string) {
+	rec := SkippedRemediation{
+		Instance: r.instance,
+		MediaID:  mediaID,
+		FileID:   fileID,
+		Reason:   reason,
+		Checked:  time.Now(),
+	}
+	log.Warn().Msgf("Keeping file %s: %s", fileID, reason)
+	r.skipped.Put(rec.Key(), rec)
+}
+
+// Preflight runs an interactive search and reports whether any release could satisfy prof,
+// the file is recorded as skipped when none could or the search fails
+func (r *remediator) Preflight(ctx context.Context, mediaID int, fileID string, prof *Profile, originalLanguage string, search func(context.Context) ([]releaseCandidate, error)) bool {
+	candidates, err := search(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Interactive search failed")
+		r.Skip(mediaID, fileID, fmt.Sprintf("interactive search failed: %v", err))
+		return false
+	}
+
+	compliant := findCompliantReleases(candidates, prof, originalLanguage)
+	if len(compliant) == 0 {
+		r.Skip(mediaID, fileID, noCompliantRelease)
+		return false
+	}
+
+	log.Info().
+		Int("candidates", len(candidates)).
+		Int("compliant", len(compliant)).
+		Str("release", compliant[0].Title).
+		Msg("Found a release that could satisfy the profile")
+	return true
+}
+
+// run executes the remaining steps, progress is saved after every step
+func (r *remediator) run(ctx context.Context, rec PendingRemediation) error {
+	for len(rec.Steps) > 0 {
+		step := rec.Steps[0]
+		err := r.target.runStep(ctx, &rec, step)
+		rec.Updated = time.Now()
+		if errors.Is(err, errStepWaiting) {
+			r.store.Put(rec.Key(), rec)
+			return nil
+		}
+		if errors.Is(err, errRemediationResolved) {
+			break
+		}
+		if err != nil {
+			switch rec.Actions[step].OnFailure {
+			case FailureContinue:
+				log.Warn().Err(err).Msgf("Remediation step %s failed for file %s, continuing", step, rec.FileID)
+				rec.Steps = rec.Steps[1:]
+				rec.LastError = err.Error()
+				r.store.Put(rec.Key(), rec)
+				continue
+			case FailureAbort:
+				r.store.Delete(rec.Key())
+				return fmt.Errorf("remediation step %s failed, skipping the remaining steps %v: %w", step, rec.Steps[1:], err)
+			}
+			rec.Attempts++
+			rec.LastError = err.Error()
+			if permanentStepError(err) || rec.Attempts >= maxRemediationAttempts {
+				r.store.Delete(rec.Key())
+				r.Skip(rec.MediaID, rec.FileID, fmt.Sprintf("remediation step %s failed after %d attempts: %v", step, rec.Attempts, err))
+				return fmt.Errorf("remediation step %s failed, giving up: %w", step, err)
+			}
+			r.store.Put(rec.Key(), rec)
+			return fmt.Errorf("remediation step %s failed, will resume once %s is healthy: %w", step, r.instance, err)
+		}
+
+		rec.Steps = rec.Steps[1:]
+		rec.LastError = ""
+		r.store.Put(rec.Key(), rec)
+	}
+
+	r.store.Delete(rec.Key())
+	return nil
+}
+
+// permanentStepError reports whether a failed step would fail the same way when retried
+func permanentStepError(err error) bool {
+	return errors.Is(err, errRemediationConfig) || arr.IsClientError(err)
+}
+
+// pending returns the incomplete sequences of this instance
+func (r *remediator) pending() []PendingRemediation {
+	var res []PendingRemediation
+	for _, key := range r.store.Keys() {
+		rec, ok := r.store.Get(key)
+		if ok && rec.Instance == r.instance {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
+
+// resume runs every pending sequence if the instance responds
+func (r *remediator) resume(ctx context.Context) {
+	pending := r.pending()
+	if len(pending) == 0 || !r.api.Healthy() {
+		return
+	}
+	if err := r.api.Ping(ctx); err != nil {
+		log.Debug().Err(err).Msgf("%s is still unavailable, %d remediations pending", r.instance, len(pending))
+		return
+	}
+
+	for _, rec := range pending {
+		if time.Now().Before(rec.NotBefore) {
+			continue
+		}
+		if err := r.resumeOne(ctx, rec); err != nil {
+			log.Error().Err(err).Msgf("Unable to resume remediation for file %s", rec.FileID)
+		}
+	}
+}
+
+// resumeOne runs a pending sequence unless it is already running
+func (r *remediator) resumeOne(ctx context.Context, rec PendingRemediation) error {
+	if !r.acquire(rec.Key()) {
+		return nil
+	}
+	defer r.release(rec.Key())
+
+	// the sequence may have finished while the previous one ran
+	rec, ok := r.store.Get(rec.Key())
+	if !ok {
+		return nil
+	}
+	log.Info().Strs("steps", rec.Steps).Msgf("Resuming remediation for file %s", rec.FileID)
+	return r.run(ctx, rec)
+}
+
+// watch resumes pending sequences every remediationResumeInterval until ctx is cancelled
+func (r *remediator) watch(ctx context.Context) {
+	ticker := time.NewTicker(remediationResumeInterval)
+	defer ticker.Stop()
+
+	for {
+		r.resume(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
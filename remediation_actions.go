@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/rs/zerolog/log"
+)
+
+type RemediationActionType = string
+
+const (
+	// ActionDeleteSearch deletes the file, re-monitors it and searches or grabs a replacement depending on search_mode
+	ActionDeleteSearch RemediationActionType = "delete-search"
+	ActionBlocklist    RemediationActionType = "blocklist"
+	ActionQuarantine   RemediationActionType = "quarantine"
+	ActionTag          RemediationActionType = "tag"
+	ActionUnmonitor    RemediationActionType = "unmonitor"
+	ActionNotify       RemediationActionType = "notify"
+	// ActionSubtitleSearch asks bazarr for the missing subtitles and ends the pipeline if they are found,
+	// it only runs for files that are missing nothing but subtitles
+	ActionSubtitleSearch RemediationActionType = "subtitle-search"
+	ActionExec           RemediationActionType = "exec"
+)
+
+type FailureMode = string
+
+const (
+	// FailureRetry keeps the failed step pending, it is retried once the instance is healthy (default)
+	FailureRetry FailureMode = "retry"
+	// FailureContinue logs the failure and runs the next step
+	FailureContinue FailureMode = "continue"
+	// FailureAbort drops the remaining steps
+	FailureAbort FailureMode = "abort"
+)
+
+const (
+	defaultRemediationTag = "warden-rejected"
+	defaultExecTimeout    = time.Minute
+	notifyTimeout         = 30 * time.Second
+	// commandAttempts and defaultCommandDelay bound the wait for a rescan to finish
+	commandAttempts     = 30
+	defaultCommandDelay = 2 * time.Second
+)
+
+// RemediationAction is one entry of the remediation pipeline of a profile
+type RemediationAction struct {
+	Action RemediationActionType `json:"action"`
+	// OnFailure is either retry (default), continue or abort
+	OnFailure FailureMode `json:"on_failure,omitempty"`
+	// Tag is the label added by the tag action, defaults to warden-rejected
+	Tag string `json:"tag,omitempty"`
+	// URL receives the notify action as a json POST, without it the notification is only logged
+	URL string `json:"url,omitempty"`
+	// Command is run by the exec action, the first entry is the program
+	Command []string `json:"command,omitempty"`
+	// Timeout limits the exec and notify actions, defaults to 1m for exec
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// remediationActionHook allows an action without options to be written as just its name e.g. `- unmonitor`
+func remediationActionHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(RemediationAction{}) {
+		return data, nil
+	}
+	return map[string]any{"action": data}, nil
+}
+
+func (a RemediationAction) validate() error {
+	switch a.Action {
+	case ActionDeleteSearch, ActionBlocklist, ActionQuarantine, ActionTag, ActionUnmonitor, ActionNotify, ActionSubtitleSearch:
+	case ActionExec:
+		if len(a.Command) == 0 {
+			return errors.New("exec action has no command")
+		}
+	default:
+		return fmt.Errorf("unknown remediation action %q", a.Action)
+	}
+
+	switch a.OnFailure {
+	case "", FailureRetry, FailureContinue, FailureAbort:
+		return nil
+	default:
+		return fmt.Errorf("unknown on_failure %q for action %s", a.OnFailure, a.Action)
+	}
+}
+
+// steps returns the remediation steps the action runs, in order
+func (a RemediationAction) steps(prof *Profile) []RemediationStep {
+	switch a.Action {
+	case ActionDeleteSearch:
+		return prof.remediationSteps()
+	case ActionSubtitleSearch:
+		return []RemediationStep{StepSubtitleSearch, StepSubtitleRecheck}
+	case ActionBlocklist:
+		return []RemediationStep{StepBlocklist}
+	case ActionQuarantine:
+		// the monitor and search steps of delete-search, the file is moved instead of deleted
+		return append([]RemediationStep{StepQuarantine, StepRescan}, prof.remediationSteps()[1:]...)
+	case ActionTag:
+		return []RemediationStep{StepTag}
+	case ActionUnmonitor:
+		return []RemediationStep{StepUnmonitor}
+	case ActionNotify:
+		return []RemediationStep{StepNotify}
+	case ActionExec:
+		return []RemediationStep{StepExec}
+	}
+	return nil
+}
+
+// remediationActions returns the pipeline of the profile, profiles without one
+// replace the file, after asking bazarr first when subtitle_search is set
+func (p *Profile) remediationActions() []RemediationAction {
+	if len(p.Remediation) > 0 {
+		return p.Remediation
+	}
+	if p.SubtitleSearch {
+		return []RemediationAction{{Action: ActionSubtitleSearch}, {Action: ActionDeleteSearch}}
+	}
+	return []RemediationAction{{Action: ActionDeleteSearch}}
+}
+
+// remediationPlan expands the pipeline of the profile into steps and the action each step belongs to.
+// subtitleSearch is false unless the file only misses subtitles and bazarr is configured, the action is dropped then.
+// Invalid actions and actions sharing a step with an earlier one are skipped in full, Lint reports them when the config is loaded
+func (p *Profile) remediationPlan(subtitleSearch bool) ([]RemediationStep, map[RemediationStep]RemediationAction) {
+	var steps []RemediationStep
+	actions := map[RemediationStep]RemediationAction{}
+	for _, action := range p.remediationActions() {
+		if err := action.validate(); err != nil {
+			log.Warn().Err(err).Msg("Skipping remediation action")
+			continue
+		}
+		if action.Action == ActionSubtitleSearch && !subtitleSearch {
+			continue
+		}
+
+		actionSteps := action.steps(p)
+		if other, step, ok := sharedStep(actions, actionSteps); ok {
+			log.Warn().Msgf("Remediation step %s is already part of action %s, skipping action %s", step, other.Action, action.Action)
+			continue
+		}
+		for _, step := range actionSteps {
+			steps = append(steps, step)
+			actions[step] = action
+		}
+	}
+	return steps, actions
+}
+
+// sharedStep returns the first of steps that already belongs to an action
+func sharedStep(actions map[RemediationStep]RemediationAction, steps []RemediationStep) (RemediationAction, RemediationStep, bool) {
+	for _, step := range steps {
+		if other, ok := actions[step]; ok {
+			return other, step, true
+		}
+	}
+	return RemediationAction{}, "", false
+}
+
+// lintRemediation returns a warning for every action of the pipeline that is skipped when it runs
+func (p *Profile) lintRemediation() []string {
+	var warnings []string
+	actions := map[RemediationStep]RemediationAction{}
+	for _, action := range p.Remediation {
+		if err := action.validate(); err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		actionSteps := action.steps(p)
+		other, step, ok := sharedStep(actions, actionSteps)
+		switch {
+		case ok && other.Action == action.Action:
+			warnings = append(warnings, fmt.Sprintf("remediation action %s is listed more than once, only the first one runs", action.Action))
+		case ok:
+			warnings = append(warnings, fmt.Sprintf("remediation actions %s and %s conflict, both run step %s, only %s runs", other.Action, action.Action, step, other.Action))
+		default:
+			for _, step := range actionSteps {
+				actions[step] = action
+			}
+		}
+	}
+	return warnings
+}
+
+// replacesFile reports whether the steps search for another file without asking bazarr first
+func replacesFile(steps []RemediationStep) bool {
+	searches := slices.Contains(steps, StepSearch) || slices.Contains(steps, StepGrab)
+	return searches && !slices.Contains(steps, StepSubtitleSearch)
+}
+
+// remediationNotification is the body the notify action posts
+type remediationNotification struct {
+	Instance string `json:"instance"`
+	MediaID  int    `json:"media_id"`
+	FileID   string `json:"file_id"`
+	FilePath string `json:"file_path,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// notifyRemediation logs the failed file and posts it to the url of the action
+func notifyRemediation(ctx context.Context, rec *PendingRemediation) error {
+	action := rec.Actions[StepNotify]
+	log.Warn().
+		Str("instance", rec.Instance).
+		Int("media_id", rec.MediaID).
+		Str("path", rec.FilePath).
+		Msgf("File %s failed its profile: %s", rec.FileID, rec.Reason)
+	if action.URL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(remediationNotification{
+		Instance: rec.Instance,
+		MediaID:  rec.MediaID,
+		FileID:   rec.FileID,
+		FilePath: rec.FilePath,
+		Reason:   rec.Reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = notifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid notify url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %w", action.URL, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify %s returned %s", action.URL, resp.Status)
+	}
+	return nil
+}
+
+// execRemediation runs the command of the exec action, the file is described by WARDEN_* environment variables
+func execRemediation(ctx context.Context, rec *PendingRemediation, localPath string) error {
+	action := rec.Actions[StepExec]
+	if len(action.Command) == 0 {
+		return fmt.Errorf("%w: exec action has no command", errRemediationConfig)
+	}
+
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, action.Command[0], action.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"WARDEN_INSTANCE="+rec.Instance,
+		"WARDEN_MEDIA_ID="+strconv.Itoa(rec.MediaID),
+		"WARDEN_FILE_ID="+rec.FileID,
+		"WARDEN_FILE_PATH="+localPath,
+		"WARDEN_REASON="+rec.Reason,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %s failed: %w: %s", action.Command[0], err, strings.TrimSpace(string(out)))
+	}
+	log.Info().Str("output", strings.TrimSpace(string(out))).Msgf("Ran %s for file %s", action.Command[0], rec.FileID)
+	return nil
+}
+
+// waitForCommand polls get until the command has finished, waiting delay between attempts
+func waitForCommand(ctx context.Context, delay time.Duration, cmd *arr.Command, get func(context.Context, int) (*arr.Command, error)) error {
+	for attempt := 1; ; attempt++ {
+		switch cmd.Status {
+		case "completed":
+			return nil
+		case "failed", "aborted", "cancelled", "orphaned":
+			return fmt.Errorf("command %s %s: %s", cmd.Name, cmd.Status, cmd.Message)
+		}
+		if attempt > commandAttempts {
+			return fmt.Errorf("command %s is still %s", cmd.Name, cmd.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		next, err := get(ctx, cmd.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load command %d: %w", cmd.ID, err)
+		}
+		cmd = next
+	}
+}
+
+// ensureTag returns the id of the tag with the label, creating it when the instance does not have it yet
+func ensureTag(ctx context.Context, label string, list func(context.Context) ([]arr.Tag, error), create func(context.Context, string) (*arr.Tag, error)) (int, error) {
+	tags, err := list(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load tags: %w", err)
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Label, label) {
+			return tag.ID, nil
+		}
+	}
+
+	tag, err := create(ctx, label)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tag %s: %w", label, err)
+	}
+	return tag.ID, nil
+}
+
+// remediationTag returns the label the tag action adds
+func remediationTag(rec *PendingRemediation) string {
+	if tag := rec.Actions[StepTag].Tag; tag != "" {
+		return tag
+	}
+	return defaultRemediationTag
+}
+
+// latestGrab returns the id of the most recent grabbed history record, false when the file was never grabbed
+func latestGrab[T any](records []T, fields func(T) (int, string, time.Time)) (int, bool) {
+	var id int
+	var latest time.Time
+	for _, rec := range records {
+		recID, event, date := fields(rec)
+		if event == "grabbed" && (id == 0 || date.After(latest)) {
+			id, latest = recID, date
+		}
+	}
+	return id, id != 0
+}
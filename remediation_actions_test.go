@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const remediationConfig = `
+sonarr-main:
+  inst_type: sonarr
+  base_path: http://localhost:8989
+  api_key: key
+  quarantine_dir: /quarantine
+  language_map:
+    /media/anime:
+      required_languages_audio: [jpn]
+      remediation:
+        - subtitle-search
+        - action: tag
+          tag: wrong-language
+          on_failure: continue
+        - action: exec
+          command: [/scripts/report.sh, --verbose]
+          timeout: 30s
+        - unmonitor
+`
+
+func TestLoadProfiles_Remediation(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(remediationConfig)))
+
+	inst, ok := loadProfiles(v, newMemoryState()).Load("sonarr-main")
+	require.True(t, ok)
+	assert.Equal(t, "/quarantine", inst.QuarantineDir)
+
+	prof := inst.LanguageMap["/media/anime"]
+	require.NotNil(t, prof)
+	assert.Equal(t, []RemediationAction{
+		{Action: ActionSubtitleSearch},
+		{Action: ActionTag, Tag: "wrong-language", OnFailure: FailureContinue},
+		{Action: ActionExec, Command: []string{"/scripts/report.sh", "--verbose"}, Timeout: 30 * time.Second},
+		{Action: ActionUnmonitor},
+	}, prof.Remediation)
+}
+
+func TestProfile_RemediationPlan(t *testing.T) {
+	prof := &Profile{SubtitleSearch: true}
+	steps, _ := prof.remediationPlan(false)
+	assert.Equal(t, []RemediationStep{StepDelete, StepMonitor, StepSearch}, steps, "subtitle-search only runs for files missing nothing but subtitles")
+
+	steps, _ = prof.remediationPlan(true)
+	assert.Equal(t, []RemediationStep{StepSubtitleSearch, StepSubtitleRecheck, StepDelete, StepMonitor, StepSearch}, steps)
+
+	prof = &Profile{
+		SearchMode: SearchModeGrab,
+		Remediation: []RemediationAction{
+			{Action: ActionNotify, OnFailure: FailureContinue},
+			{Action: "explode"},
+			{Action: ActionDeleteSearch, OnFailure: FailureAbort},
+			{Action: ActionNotify},
+		},
+	}
+	steps, actions := prof.remediationPlan(false)
+	assert.Equal(t, []RemediationStep{StepNotify, StepDelete, StepMonitor, StepGrab}, steps)
+	assert.Equal(t, FailureContinue, actions[StepNotify].OnFailure)
+	assert.Equal(t, FailureAbort, actions[StepGrab].OnFailure)
+
+	assert.Len(t, prof.lintRemediation(), 2)
+}
+
+func TestProfile_RemediationPlanConflicts(t *testing.T) {
+	prof := &Profile{Remediation: []RemediationAction{{Action: ActionDeleteSearch}, {Action: ActionQuarantine}}}
+	steps, _ := prof.remediationPlan(false)
+	assert.Equal(t, []RemediationStep{StepDelete, StepMonitor, StepSearch}, steps)
+	assert.Equal(t, []string{"remediation actions delete-search and quarantine conflict, both run step monitor, only delete-search runs"}, prof.lintRemediation())
+
+	prof = &Profile{Remediation: []RemediationAction{{Action: ActionQuarantine}, {Action: ActionNotify}, {Action: ActionDeleteSearch}}}
+	steps, actions := prof.remediationPlan(false)
+	assert.Equal(t, []RemediationStep{StepQuarantine, StepRescan, StepMonitor, StepSearch, StepNotify}, steps)
+	assert.Equal(t, ActionQuarantine, actions[StepSearch].Action)
+	assert.Len(t, prof.lintRemediation(), 1)
+}
+
+// testSonarr collects the options of newTestSonarr
+type testSonarr struct {
+	handlers map[string]http.HandlerFunc
+	edits    []func(*ArrInstance)
+}
+
+type testSonarrOption func(*testSonarr)
+
+// withHandler serves pattern with handler instead of the default
+func withHandler(pattern string, handler http.HandlerFunc) testSonarrOption {
+	return func(o *testSonarr) {
+		o.handlers[pattern] = handler
+	}
+}
+
+// withJSON answers pattern with status and body instead of the default
+func withJSON(pattern string, status int, body any) testSonarrOption {
+	return withHandler(pattern, func(w http.ResponseWriter, r *http.Request) {
+		arrtest.WriteJSON(w, status, body)
+	})
+}
+
+// withInstance changes the instance config before the client is created
+func withInstance(edit func(inst *ArrInstance)) testSonarrOption {
+	return func(o *testSonarr) {
+		o.edits = append(o.edits, edit)
+	}
+}
+
+// newTestSonarr returns the sonarr-main instance with prof on /media/anime for the remediation tests, backed by a server
+// that knows episode 13947 of series 118 with file 11729 and english audio and accepts the default pipeline
+func newTestSonarr(t *testing.T, prof *Profile, opts ...testSonarrOption) (*SonarrInst, *arrtest.Server) {
+	o := &testSonarr{handlers: map[string]http.HandlerFunc{}}
+	withJSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{AudioLanguages: "eng", AudioStreamCount: 1},
+	})(o)
+	withJSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})(o)
+	withJSON("GET /api/v3/episode/{id}", http.StatusOK, map[string]any{"id": 13947, "seriesId": 118, "episodeFileId": 11729, "hasFile": true})(o)
+	withJSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)(o)
+	withJSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)(o)
+	withJSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})(o)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	server := arrtest.NewServer(t)
+	for pattern, handler := range o.handlers {
+		server.Handle(pattern, handler)
+	}
+
+	inst := &ArrInstance{
+		BasePath:    server.URL,
+		ApiKey:      arrtest.APIKey,
+		name:        "sonarr-main",
+		state:       newMemoryState(),
+		LanguageMap: map[string]*Profile{},
+	}
+	if prof != nil {
+		inst.LanguageMap["/media/anime"] = prof
+	}
+	for _, edit := range o.edits {
+		edit(inst)
+	}
+
+	cli := NewSonarr(inst)
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.mediaInfoDelay = time.Millisecond
+	cli.commandDelay = time.Millisecond
+	return cli, server
+}
+
+// runTestCheck checks file 11729 of episode 13947 in /media/anime/Show
+func runTestCheck(cli *SonarrInst, filePath string) {
+	cli.RunCheck(context.Background(), &SonarMediaInfo{
+		SeriesID:      118,
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		FilePath:      filePath,
+	})
+}
+
+// pipelineProfile fails the english episode file of newTestSonarr and remediates it with actions
+func pipelineProfile(actions ...RemediationAction) *Profile {
+	return &Profile{RequiredLanguagesAudio: []string{"jpn"}, Remediation: actions}
+}
+
+func TestSonarr_RemediationPipeline(t *testing.T) {
+	cli, server := newTestSonarr(t,
+		pipelineProfile(
+			RemediationAction{Action: ActionTag, Tag: "wrong-language"},
+			RemediationAction{Action: ActionUnmonitor},
+		),
+		withJSON("GET /api/v3/tag", http.StatusOK, []arr.Tag{{ID: 1, Label: "anime"}}),
+		withJSON("POST /api/v3/tag", http.StatusCreated, arr.Tag{ID: 4, Label: "wrong-language"}),
+		withJSON("PUT /api/v3/series/editor", http.StatusAccepted, nil),
+	)
+	runTestCheck(cli, "/media/anime/Show/Show - S01E01.mkv")
+
+	assert.Empty(t, cli.remediator.pending())
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), "the file is only replaced by delete-search")
+
+	editor := server.RequestsTo(http.MethodPut, "/api/v3/series/editor")
+	require.Len(t, editor, 1)
+	assert.JSONEq(t, `{"seriesIds":[118],"tags":[4],"applyTags":"add"}`, string(editor[0].Body))
+
+	monitor := server.RequestsTo(http.MethodPut, "/api/v3/episode/monitor")
+	require.Len(t, monitor, 1)
+	assert.JSONEq(t, `{"episodeIds":[13947],"monitored":false}`, string(monitor[0].Body))
+}
+
+func TestSonarr_RemediationFailureModes(t *testing.T) {
+	notify := arrtest.NewServer(t)
+	notify.JSON("POST /hook", http.StatusInternalServerError, nil)
+
+	cli, server := newTestSonarr(t,
+		pipelineProfile(
+			RemediationAction{Action: ActionNotify, URL: notify.URL + "/hook", OnFailure: FailureContinue},
+			RemediationAction{Action: ActionDeleteSearch, OnFailure: FailureAbort},
+			RemediationAction{Action: ActionUnmonitor},
+		),
+		withJSON("DELETE /api/v3/episodefile/{id}", http.StatusInternalServerError, nil),
+	)
+	runTestCheck(cli, "/media/anime/Show/Show - S01E01.mkv")
+
+	reqs := notify.RequestsTo(http.MethodPost, "/hook")
+	require.Len(t, reqs, 1, "a failed notify continues with the next step")
+	var body remediationNotification
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &body))
+	assert.Equal(t, remediationNotification{
+		Instance: "sonarr-main",
+		MediaID:  13947,
+		FileID:   "11729",
+		FilePath: "/media/anime/Show/Show - S01E01.mkv",
+		Reason:   "missing required languages",
+	}, body)
+
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+	assert.Empty(t, server.RequestsTo(http.MethodPut, "/api/v3/episode/monitor"), "an aborted pipeline skips the remaining steps")
+	assert.Empty(t, cli.remediator.pending(), "an aborted pipeline is not resumed")
+}
+
+func TestSonarr_RemediationBlocklist(t *testing.T) {
+	cli, server := newTestSonarr(t, pipelineProfile(RemediationAction{Action: ActionBlocklist}), withJSON("GET /api/v3/history", http.StatusOK, arr.Page[map[string]any]{
+		Page:         1,
+		PageSize:     50,
+		TotalRecords: 3,
+		Records: []map[string]any{
+			{"id": 31, "eventType": "downloadFolderImported", "date": "2026-10-02T10:00:00Z"},
+			{"id": 30, "eventType": "grabbed", "date": "2026-10-02T09:00:00Z"},
+			{"id": 12, "eventType": "grabbed", "date": "2026-09-01T09:00:00Z"},
+		},
+	}), withJSON("POST /api/v3/history/failed/{id}", http.StatusOK, nil))
+	runTestCheck(cli, "")
+
+	history := server.RequestsTo(http.MethodGet, "/api/v3/history")
+	require.Len(t, history, 1)
+	assert.Contains(t, history[0].Query, "episodeId=13947")
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/history/failed/30"), 1)
+	assert.Empty(t, cli.remediator.pending())
+}
+
+func TestSonarr_RemediationQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	cli, server := newTestSonarr(t, pipelineProfile(RemediationAction{Action: ActionQuarantine}),
+		withJSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1, Status: "queued"}),
+		withJSON("GET /api/v3/command/{id}", http.StatusOK, arr.Command{ID: 1, Status: "completed"}),
+		withInstance(func(inst *ArrInstance) {
+			inst.QuarantineDir = filepath.Join(t.TempDir(), "quarantine")
+			inst.PathMappings = PathMappings{{Remote: "/media", Local: dir}}
+		}),
+	)
+	file := filepath.Join(dir, "anime", "Show", "Show - S01E01.mkv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+	require.NoError(t, os.WriteFile(file, []byte("video"), 0o644))
+
+	runTestCheck(cli, "/media/anime/Show/Show - S01E01.mkv")
+
+	assert.NoFileExists(t, file)
+	data, err := os.ReadFile(filepath.Join(cli.quarantine.dir, "sonarr-main", "11729", "Show - S01E01.mkv"))
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	// rescan so sonarr sees the file missing, then the monitor and search steps of delete-search
+	commands := server.RequestsTo(http.MethodPost, "/api/v3/command")
+	require.Len(t, commands, 2)
+	assert.Contains(t, string(commands[0].Body), "RescanSeries")
+	assert.Contains(t, string(commands[1].Body), "EpisodeSearch")
+	assert.Len(t, server.RequestsTo(http.MethodGet, "/api/v3/command/1"), 1)
+	assert.Len(t, server.RequestsTo(http.MethodPut, "/api/v3/episode/monitor"), 1)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+	assert.Empty(t, cli.remediator.pending())
+}
+
+func TestSonarr_RemediationExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec test uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out.txt")
+
+	cli, _ := newTestSonarr(t, pipelineProfile(RemediationAction{
+		Action:  ActionExec,
+		Command: []string{"sh", "-c", `echo "$WARDEN_INSTANCE $WARDEN_MEDIA_ID $WARDEN_FILE_ID $WARDEN_REASON" > "$0"`, out},
+	}))
+	runTestCheck(cli, "/media/anime/Show/Show - S01E01.mkv")
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "sonarr-main 13947 11729 missing required languages\n", string(data))
+	assert.Empty(t, cli.remediator.pending())
+}
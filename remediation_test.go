@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemediation_ResumesAfterFailure(t *testing.T) {
+	server := arrtest.NewServer(t)
+	var down atomic.Bool
+	down.Store(true)
+
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+	server.Handle("PUT /api/v3/episode/monitor", func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			arrtest.WriteJSON(w, http.StatusServiceUnavailable, nil)
+			return
+		}
+		arrtest.WriteJSON(w, http.StatusAccepted, nil)
+	})
+
+	stateDir := t.TempDir()
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		name:     "sonarr-main",
+		state:    NewState(stateDir),
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+
+	cli.Remediate(context.Background(), &SonarMediaInfo{EpisodeID: 13947, EpisodeFileID: "11729"}, &Profile{}, "missing required languages", nil)
+
+	// the incomplete sequence is persisted and survives a restart
+	pending := NewState(stateDir).Pending
+	rec, ok := pending.Get("sonarr-main:11729")
+	require.True(t, ok)
+	assert.Equal(t, []RemediationStep{StepMonitor, StepSearch}, rec.Steps)
+	assert.Equal(t, 1, rec.Attempts)
+	assert.Contains(t, rec.LastError, "503")
+
+	down.Store(false)
+	cli.remediator.resume(context.Background())
+
+	_, ok = cli.remediator.store.Get("sonarr-main:11729")
+	assert.False(t, ok, "completed remediation should be removed")
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/command"), 1)
+}
+
+func TestRemediation_AlreadyDeleted(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("DELETE /api/v3/moviefile/{id}", http.StatusNotFound, map[string]string{"message": "NotFound"})
+	server.JSON("PUT /api/v3/movie/editor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	cli := NewRadarr(&ArrInstance{BasePath: server.URL, ApiKey: arrtest.APIKey})
+	err := cli.remediator.Start(context.Background(), 7, "42", nil, StepDelete, StepMonitor, StepSearch)
+	require.NoError(t, err)
+	assert.Empty(t, cli.remediator.pending())
+}
+
+// blockingTarget holds every step until unblock is closed
+type blockingTarget struct {
+	calls   atomic.Int32
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingTarget) runStep(context.Context, *PendingRemediation, RemediationStep) error {
+	b.calls.Add(1)
+	b.started <- struct{}{}
+	<-b.unblock
+	return nil
+}
+
+func TestRemediation_RunsOnce(t *testing.T) {
+	state := newMemoryState()
+	target := &blockingTarget{started: make(chan struct{}, 4), unblock: make(chan struct{})}
+	r := &remediator{instance: "sonarr-main", store: state.Pending, skipped: state.Skipped, target: target}
+
+	done := make(chan error)
+	go func() {
+		done <- r.Start(context.Background(), 13947, "11729", nil, StepDelete)
+	}()
+	<-target.started
+
+	// a second webhook and the resume tick both find the sequence running
+	require.NoError(t, r.Start(context.Background(), 13947, "11729", nil, StepDelete))
+	require.NoError(t, r.resumeOne(context.Background(), r.pending()[0]))
+
+	close(target.unblock)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), target.calls.Load())
+	assert.Empty(t, r.pending())
+}
+
+// failingTarget fails every step of the files in fail with err
+type failingTarget struct {
+	fail map[string]error
+	ran  []string
+}
+
+func (f *failingTarget) runStep(_ context.Context, rec *PendingRemediation, _ RemediationStep) error {
+	f.ran = append(f.ran, rec.FileID)
+	return f.fail[rec.FileID]
+}
+
+func TestRemediation_ResumeSkipsFailingRecord(t *testing.T) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+
+	state := newMemoryState()
+	target := &failingTarget{fail: map[string]error{"1": errors.New("connection reset")}}
+	r := &remediator{instance: "sonarr-main", store: state.Pending, skipped: state.Skipped, target: target, api: arr.NewClient(server.URL, arrtest.APIKey)}
+	for _, fileID := range []string{"1", "2"} {
+		rec := PendingRemediation{Instance: "sonarr-main", FileID: fileID, Steps: []RemediationStep{StepDelete}}
+		state.Pending.Put(rec.Key(), rec)
+	}
+
+	r.resume(context.Background())
+	assert.Equal(t, []string{"1", "2"}, target.ran, "the failing record does not block the next one")
+	require.Len(t, r.pending(), 1)
+	assert.Equal(t, 1, r.pending()[0].Attempts)
+
+	for range maxRemediationAttempts - 1 {
+		r.resume(context.Background())
+	}
+	assert.Empty(t, r.pending(), "the record is given up after maxRemediationAttempts")
+	skipped, ok := state.Skipped.Get("sonarr-main:1")
+	require.True(t, ok)
+	assert.Contains(t, skipped.Reason, "connection reset")
+}
+
+func TestRemediation_GivesUpOnPermanentErrors(t *testing.T) {
+	state := newMemoryState()
+	target := &failingTarget{fail: map[string]error{
+		"1": &arr.Error{Method: http.MethodDelete, Path: "/api/v3/episodefile/1", StatusCode: http.StatusBadRequest},
+		"2": fmt.Errorf("%w: quarantine_dir is not set for the instance", errRemediationConfig),
+		"3": &arr.Error{Method: http.MethodDelete, Path: "/api/v3/episodefile/3", StatusCode: http.StatusTooManyRequests},
+	}}
+	r := &remediator{instance: "sonarr-main", store: state.Pending, skipped: state.Skipped, target: target}
+
+	for _, fileID := range []string{"1", "2", "3"} {
+		require.Error(t, r.Start(context.Background(), 13947, fileID, nil, StepDelete))
+	}
+
+	pending := r.pending()
+	require.Len(t, pending, 1, "rate limits are retried")
+	assert.Equal(t, "3", pending[0].FileID)
+	assert.Equal(t, []string{"sonarr-main:1", "sonarr-main:2"}, state.Skipped.Keys())
+}
@@ -1,103 +1,255 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"path/filepath"
-	"resty.dev/v3"
 	"strconv"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/bazarr"
+	"github.com/RA341/warden/api/sonarr"
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/tracks"
 )
 
 // SonarWebhookPayload represents the structure of the incoming webhook JSON
 type SonarWebhookPayload struct {
 	Series struct {
-		Path             string   `json:"path"`
-		Tags             []string `json:"tags"`
+		Id               int         `json:"id"`
+		Path             string      `json:"path"`
+		TvdbId           int         `json:"tvdbId"`
+		TmdbId           int         `json:"tmdbId"`
+		ImdbId           string      `json:"imdbId"`
+		Tags             webhookTags `json:"tags"`
 		OriginalLanguage struct {
 			Name string `json:"name"`
 		} `json:"originalLanguage"`
 	} `json:"series"`
 	Episodes []struct {
-		Id int `json:"id"`
+		Id         int       `json:"id"`
+		AirDateUtc time.Time `json:"airDateUtc"`
 	} `json:"episodes"`
 	EpisodeFile struct {
-		ID        int64 `json:"id"`
+		ID           int64  `json:"id"`
+		Path         string `json:"path"`
+		RelativePath string `json:"relativePath"`
+		// Languages are the languages sonarr parsed from the release, v4 sends a list, v3 a single object
+		Languages languageList `json:"languages"`
+		Language  languageList `json:"language"`
 		MediaInfo struct {
-			AudioLanguages []string `json:"audioLanguages"`
-			Subtitles      []string `json:"subtitles"`
+			AudioLanguages languageList `json:"audioLanguages"`
+			Subtitles      languageList `json:"subtitles"`
 		} `json:"mediaInfo"`
 	} `json:"episodeFile"`
+	Release            webhookRelease `json:"release"`
+	DownloadID         string         `json:"downloadId"`
+	DownloadClient     string         `json:"downloadClient"`
+	DownloadClientType string         `json:"downloadClientType"`
 }
 
 type SonarMediaInfo struct {
-	EpisodeID        int
-	EpisodeFileID    string
-	MediaPath        string
-	Tags             []string
+	SeriesID      int
+	EpisodeID     int
+	EpisodeFileID string
+	// MediaPath is the root folder of the series
+	MediaPath string
+	// SeriesPath is the full path of the series folder
+	SeriesPath string
+	TvdbID     int
+	TmdbID     int
+	ImdbID     string
+	Tags       []string
+	// TagIDs are tags sent as ids instead of labels, they are resolved in RunCheck
+	TagIDs           []int
 	OriginalLanguage string
-	Subtitles        []string
-	Audios           []string
+	// DownloadID is the id of the download the file was imported from
+	DownloadID string
+	// FilePath is the full path of the imported file
+	FilePath string
+	// Tracks are read from the file when the instance inspects files
+	Tracks []tracks.Track
+	// Sidecars are the subtitle files next to the file
+	Sidecars []tracks.Track
+	// FileLanguages are the languages sonarr parsed from the release name
+	FileLanguages []string
+	Subtitles     []string
+	Audios        []string
+	// AirDate is when the episode aired, it is loaded from sonarr when the webhook has none
+	AirDate time.Time
+	// recheck is set when the check was scheduled, the grace period does not apply again then
+	recheck bool
 }
 
 type SonarrInst struct {
-	client     *resty.Client
-	getProfile GetProfileCallback
+	api        *sonarr.Client
+	profiles   *ProfileMatcher
+	tags       *tagCache
+	remediator *remediator
+	tracker    *grabTracker
+	bazarr     *bazarr.Client
+	// inspectFiles prefers the tracks read from the file over the media info
+	inspectFiles bool
+	// sidecarSubs counts the subtitle files next to the media file
+	sidecarSubs bool
+	paths       PathMappings
+	quarantine  *quarantine
+	cancel      context.CancelFunc
+	// mediaInfoDelay is the wait between checks while sonarr is still analysing a file
+	mediaInfoDelay time.Duration
+	// queueDelay is the wait between checks while a grab is not in the queue yet
+	queueDelay time.Duration
+	// commandDelay is the wait between checks while a rescan is running
+	commandDelay time.Duration
+	checks       *recheckScheduler
 }
 
-func NewSonarr(baseUrl, apiKey string, callback GetProfileCallback) *SonarrInst {
-	return &SonarrInst{
-		client: resty.New().
-			SetBaseURL(baseUrl).
-			SetHeader("X-Api-Key", apiKey).
-			SetDebug(false),
-		getProfile: callback,
+func NewSonarr(inst *ArrInstance) *SonarrInst {
+	inst.withDefaults()
+	s := &SonarrInst{
+		api:            sonarr.New(inst.BasePath, inst.ApiKey),
+		profiles:       inst.matcher,
+		tags:           &tagCache{},
+		mediaInfoDelay: defaultMediaInfoDelay,
+		queueDelay:     defaultQueueDelay,
+		commandDelay:   defaultCommandDelay,
+		inspectFiles:   inst.InspectFiles,
+		sidecarSubs:    inst.SidecarSubtitles,
+		bazarr:         newBazarrClient(inst.Bazarr),
+		paths:          inst.PathMappings,
+	}
+	s.remediator = &remediator{
+		instance: inst.name,
+		store:    inst.state.Pending,
+		skipped:  inst.state.Skipped,
+		target:   s,
+		api:      s.api.Client,
+	}
+	s.tracker = newGrabTracker(inst, s.rejectGrab)
+	s.checks = &recheckScheduler{
+		instance: inst.name,
+		store:    inst.state.Checks,
+		api:      s.api.Client,
+		check:    s.recheck,
 	}
+	s.quarantine = newQuarantine(inst, s.rescanQuarantined)
+	return s
 }
 
-// NewSonarrWithEmptyCallback used for tests, callback always returns false
+// NewSonarrWithEmptyCallback used for tests, no profile ever matches
 func NewSonarrWithEmptyCallback(baseUrl, apiKey string) *SonarrInst {
-	return NewSonarr(
-		baseUrl,
-		apiKey,
-		func(s string) (*Profile, bool) {
-			return nil, false
-		},
-	)
+	return NewSonarr(&ArrInstance{
+		InstType: SONARR,
+		BasePath: baseUrl,
+		ApiKey:   apiKey,
+	})
+}
+
+// Start keeps the instance tags up to date, resumes incomplete remediations, runs scheduled checks
+// and purges expired quarantined files in the background
+func (s *SonarrInst) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.tags.watch(ctx, s.fetchTags, s.profiles.TagKeys())
+	go s.remediator.watch(ctx)
+	go s.tracker.watch(ctx)
+	go s.quarantine.watch(ctx)
+	go s.checks.watch(ctx)
+}
+
+func (s *SonarrInst) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *SonarrInst) Quarantine() *quarantine {
+	return s.quarantine
 }
 
 func (s *SonarrInst) ProcessWebhook(jsonData []byte) error {
+	switch event := webhookEventType(jsonData); event {
+	case eventTest:
+		log.Info().Msg("Received test webhook")
+		return nil
+	case eventGrab:
+		grab, err := s.ParseGrab(jsonData)
+		if err != nil {
+			return err
+		}
+		s.RunGrabCheck(context.Background(), grab)
+		return nil
+	case eventDownload:
+	default:
+		log.Debug().Msgf("Ignoring %s webhook", event)
+		return nil
+	}
+
 	info, err := s.ParseJson(jsonData)
 	if err != nil {
 		return err
 	}
-	s.RunCheck(info)
+	s.tracker.Untrack(info.DownloadID)
+	s.RunCheck(context.Background(), info)
 	return nil
 }
 
-func (s *SonarrInst) RunCheck(info *SonarMediaInfo) {
-	prof := s.matchProfile(info)
-	if prof == nil {
+func (s *SonarrInst) RunCheck(ctx context.Context, info *SonarMediaInfo) {
+	if err := s.check(ctx, info); err != nil {
+		log.Error().Err(err).Msg("Not acting on unverified media info")
+	}
+}
+
+// check is RunCheck returning the error instead of logging it, the file was left alone on error
+func (s *SonarrInst) check(ctx context.Context, info *SonarMediaInfo) error {
+	info.Tags = append(info.Tags, s.tags.labels(info.TagIDs)...)
+	ids := mediaIDs(info.TvdbID, info.TmdbID, info.ImdbID)
+	match, ok := s.profiles.Match(ProfileQuery{IDs: ids, Tags: info.Tags, Path: info.SeriesPath})
+	if !ok {
 		log.Warn().
+			Strs("ids", ids).
 			Interface("tags", info.Tags).
-			Str("MediaPath", info.MediaPath).
-			Msgf("No profile found, checked tags and root folder")
-		return
+			Str("SeriesPath", info.SeriesPath).
+			Msgf("No profile found, checked ids, tags and series path")
+		return nil
 	}
+	prof := match.Profile
+	log.Info().
+		Interface("rules", match.Rules).
+		Str("SeriesPath", info.SeriesPath).
+		Msg("Matched profile")
 
-	if !isSubset(info.Audios, prof.RequiredLanguagesAudio) {
-		log.Info().Msgf("Found missing audio languages, \nneed: %v \ngot:%v", prof.RequiredLanguagesAudio, info.Audios)
-		s.DeleteAndReMonitor(info)
-		return
+	if prof.Exempt {
+		log.Info().Strs("ids", ids).Msg("Series is exempt, skipping check")
+		return nil
 	}
-	if !isSubset(info.Subtitles, prof.RequiredLanguagesSubs) {
-		log.Info().Msgf("Found missing subtitles languages, \nneed: %v \ngot: %v", prof.RequiredLanguagesSubs, info.Subtitles)
-		s.DeleteAndReMonitor(info)
-		return
+
+	if err := s.loadMediaInfo(ctx, info); err != nil {
+		return err
+	}
+	if s.sidecarSubs {
+		info.Sidecars = readSidecars(s.paths.ToLocal(info.FilePath))
 	}
 
+	audios, subs := prof.availableLanguages(info.Tracks, info.Sidecars, info.Audios, info.Subtitles)
+	if !prof.Satisfied(audios, subs) {
+		missing, _ := prof.missingSubtitles(audios, subs)
+		s.Remediate(ctx, info, prof, "missing required languages", missing)
+		return nil
+	}
 	log.Debug().Msg("All required languages found")
+	s.checks.Cancel(info.EpisodeID)
+
+	if prof.rejectsDefaultTracks(info.Tracks, info.SeriesPath) {
+		s.Remediate(ctx, info, prof, defaultTracksMismatch, nil)
+		return nil
+	}
+	s.remediator.defaultTracksMatched(info.EpisodeID)
+	return nil
 }
 
 func (s *SonarrInst) ParseJson(jsonData []byte) (*SonarMediaInfo, error) {
@@ -117,7 +269,9 @@ func (s *SonarrInst) ParseJson(jsonData []byte) (*SonarMediaInfo, error) {
 		return nil, errors.New("missing series path")
 	}
 
-	if payload.Series.OriginalLanguage.Name == "" {
+	// sonarr v3 does not send the original language, its episode file has a single language instead of a list
+	v3 := payload.EpisodeFile.Language != nil && payload.EpisodeFile.Languages == nil
+	if payload.Series.OriginalLanguage.Name == "" && !v3 {
 		return nil, errors.New("missing original language name")
 	}
 
@@ -136,102 +290,477 @@ func (s *SonarrInst) ParseJson(jsonData []byte) (*SonarMediaInfo, error) {
 	//	return nil, errors.New("missing tags")
 	//}
 
+	fileLanguages := lang.NormalizeAll(append(payload.EpisodeFile.Languages, payload.EpisodeFile.Language...))
+	audios := []string(payload.EpisodeFile.MediaInfo.AudioLanguages)
+	if len(audios) == 0 && len(fileLanguages) > 0 {
+		// no media info in the webhook, use the release languages until the api data is loaded
+		audios = fileLanguages
+	}
+
+	seriesPath := filepath.ToSlash(payload.Series.Path)
 	basePath := filepath.Dir(payload.Series.Path)
 	basePath = filepath.ToSlash(basePath)
 
 	return &SonarMediaInfo{
+		SeriesID:         payload.Series.Id,
 		EpisodeID:        payload.Episodes[0].Id,
+		AirDate:          payload.Episodes[0].AirDateUtc,
 		EpisodeFileID:    strconv.FormatInt(payload.EpisodeFile.ID, 10),
 		MediaPath:        basePath,
-		Tags:             payload.Series.Tags,
+		SeriesPath:       seriesPath,
+		TvdbID:           payload.Series.TvdbId,
+		TmdbID:           payload.Series.TmdbId,
+		ImdbID:           payload.Series.ImdbId,
+		Tags:             payload.Series.Tags.Labels,
+		TagIDs:           payload.Series.Tags.IDs,
 		OriginalLanguage: payload.Series.OriginalLanguage.Name,
+		DownloadID:       payload.DownloadID,
+		FilePath:         webhookFilePath(payload.Series.Path, payload.EpisodeFile.Path, payload.EpisodeFile.RelativePath),
+		FileLanguages:    fileLanguages,
 		Subtitles:        payload.EpisodeFile.MediaInfo.Subtitles,
-		Audios:           payload.EpisodeFile.MediaInfo.AudioLanguages,
+		Audios:           audios,
 	}, nil
 }
 
-func (s *SonarrInst) DeleteAndReMonitor(info *SonarMediaInfo) {
-	log.Info().Msgf("Deleting file and remonitoring")
+// ParseGrab reads a Grab webhook, sent when sonarr hands a release to the download client
+func (s *SonarrInst) ParseGrab(jsonData []byte) (*GrabInfo, error) {
+	var payload SonarWebhookPayload
+	if err := json.Unmarshal(jsonData, &payload); err != nil {
+		return nil, err
+	}
 
-	err := s.deleteEpisode(info.EpisodeFileID)
-	if err != nil {
-		log.Error().Err(err).Msg(" failed to delete episode")
+	if len(payload.Episodes) == 0 {
+		return nil, errors.New("missing episode id")
+	}
+	if payload.Release.ReleaseTitle == "" {
+		return nil, errors.New("missing release title")
+	}
+	if payload.DownloadID == "" {
+		return nil, errors.New("missing download id")
+	}
+
+	var episodeIDs []int
+	var airDate time.Time
+	for _, ep := range payload.Episodes {
+		episodeIDs = append(episodeIDs, ep.Id)
+		if ep.AirDateUtc.After(airDate) {
+			airDate = ep.AirDateUtc
+		}
+	}
+
+	return &GrabInfo{
+		MediaIDs:           episodeIDs,
+		Path:               filepath.ToSlash(payload.Series.Path),
+		TvdbID:             payload.Series.TvdbId,
+		TmdbID:             payload.Series.TmdbId,
+		ImdbID:             payload.Series.ImdbId,
+		Tags:               payload.Series.Tags.Labels,
+		TagIDs:             payload.Series.Tags.IDs,
+		OriginalLanguage:   payload.Series.OriginalLanguage.Name,
+		ReleaseTitle:       payload.Release.ReleaseTitle,
+		CustomFormats:      payload.Release.CustomFormats,
+		DownloadID:         payload.DownloadID,
+		DownloadClient:     payload.DownloadClient,
+		DownloadClientType: payload.DownloadClientType,
+		ReleaseDate:        airDate,
+	}, nil
+}
+
+// RunGrabCheck rejects the grabbed release before it finishes downloading if it cannot satisfy the profile
+func (s *SonarrInst) RunGrabCheck(ctx context.Context, info *GrabInfo) {
+	info.Tags = append(info.Tags, s.tags.labels(info.TagIDs)...)
+	ids := mediaIDs(info.TvdbID, info.TmdbID, info.ImdbID)
+	match, ok := s.profiles.Match(ProfileQuery{IDs: ids, Tags: info.Tags, Path: info.Path})
+	if !ok {
+		log.Debug().Str("SeriesPath", info.Path).Msg("No profile found for grab")
+		return
+	}
+	prof := match.Profile
+	if prof.Exempt || !prof.RejectGrabs {
+		return
+	}
+	if _, wait := prof.checkDue(time.Now(), s.grabAirDate(ctx, info, prof), true); wait {
+		log.Debug().Str("release", info.ReleaseTitle).Msg("Episode is too new to enforce the profile, accepting grab")
 		return
 	}
 
-	err = s.monitorEpisode([]int{info.EpisodeID})
-	if err != nil {
-		log.Error().Err(err).Msg(" failed to re-monitor episode")
+	reason, reject := prof.grabViolation(info.ReleaseTitle, info.CustomFormats)
+	if !reject {
+		log.Debug().Str("release", info.ReleaseTitle).Msg("Grabbed release may satisfy the profile")
+		s.tracker.Track(info, prof)
 		return
 	}
 
-	err = s.SearchEpisodes(info.EpisodeID)
+	log.Info().Str("release", info.ReleaseTitle).Msgf("Rejecting grab, %s", reason)
+	if err := s.rejectGrab(ctx, info); err != nil {
+		log.Error().Err(err).Msg("Unable to reject grab, the file will be checked after import")
+	}
+}
+
+// grabAirDate returns when the newest grabbed episode aired if the profile depends on it, the zero time if it is unknown
+func (s *SonarrInst) grabAirDate(ctx context.Context, info *GrabInfo, prof *Profile) time.Time {
+	if prof.EnforceAfter <= 0 || !info.ReleaseDate.IsZero() {
+		return info.ReleaseDate
+	}
+
+	var airDate time.Time
+	for _, id := range info.MediaIDs {
+		ep, err := s.api.GetEpisode(ctx, id)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Unable to load the air date of episode %d, enforcing the profile", id)
+			return time.Time{}
+		}
+		if ep.AirDateUtc.After(airDate) {
+			airDate = ep.AirDateUtc
+		}
+	}
+	info.ReleaseDate = airDate
+	return info.ReleaseDate
+}
+
+// rejectGrab removes the download from the queue and the download client, blocklists the release and searches again.
+// It is not persisted like remediations since the file is still checked once it is imported
+func (s *SonarrInst) rejectGrab(ctx context.Context, info *GrabInfo) error {
+	queueIDs, err := findQueueItems(ctx, s.queueDelay, func(ctx context.Context) ([]int, error) {
+		records, err := s.api.GetAllQueue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int
+		for _, rec := range records {
+			if sameDownload(rec.DownloadID, info.DownloadID) {
+				ids = append(ids, rec.ID)
+			}
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to find download %s in the queue: %w", info.DownloadID, err)
+	}
+
+	opts := arr.QueueDeleteOptions{RemoveFromClient: true, Blocklist: true, SkipRedownload: true}
+	for _, id := range queueIDs {
+		// every episode of a season pack has its own queue item, removing the first removes the download
+		if err = s.api.DeleteQueueItem(ctx, id, opts); err != nil && !arr.IsNotFound(err) {
+			return fmt.Errorf("failed to remove queue item %d: %w", id, err)
+		}
+	}
+
+	if _, err = s.api.EpisodeSearch(ctx, info.MediaIDs...); err != nil {
+		return fmt.Errorf("failed to search episodes %v: %w", info.MediaIDs, err)
+	}
+	return nil
+}
+
+// loadMediaInfo replaces the webhook languages with the tracks of the file when it is inspected,
+// otherwise with the ones sonarr has analysed for the episode file
+func (s *SonarrInst) loadMediaInfo(ctx context.Context, info *SonarMediaInfo) error {
+	if s.inspectFiles {
+		all, err := readFileTracks(s.paths.ToLocal(info.FilePath), &info.Audios, &info.Subtitles)
+		if err == nil {
+			info.Tracks = all
+			return nil
+		}
+		log.Warn().Err(err).Msg("Unable to read the file tracks, falling back to the media info")
+	}
+
+	fileID, err := strconv.Atoi(info.EpisodeFileID)
+	if err != nil {
+		return fmt.Errorf("invalid episode file id %s: %w", info.EpisodeFileID, err)
+	}
+
+	mediaInfo, err := fetchAnalysedMediaInfo(ctx, s.mediaInfoDelay, func(ctx context.Context) (*arr.MediaInfo, error) {
+		file, err := s.api.GetEpisodeFile(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		return file.MediaInfo, nil
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed to search episode")
+		return mediaInfoError("episode file", info.EpisodeFileID, err)
+	}
+
+	applyAuthoritative(mediaInfo, &info.Audios, &info.Subtitles)
+	return nil
+}
+
+// Remediate runs the remediation pipeline of the profile on the file,
+// missingSubs are the subtitle languages of a file that only misses subtitles and enable the subtitle-search action
+func (s *SonarrInst) Remediate(ctx context.Context, info *SonarMediaInfo, prof *Profile, reason string, missingSubs []string) {
+	if due, ok := prof.checkDue(time.Now(), s.airDate(ctx, info, prof), info.recheck); ok {
+		if existing, ok := s.checks.scheduled(info.EpisodeID, info.EpisodeFileID); ok && !info.recheck {
+			// another webhook for the same file does not push the check back
+			due = existing
+		}
+		log.Info().Msgf("Episode file %s failed its profile (%s), checking it again at %s", info.EpisodeFileID, reason, due.Format(time.RFC3339))
+		s.checks.Schedule(info.EpisodeID, info.EpisodeFileID, due, info)
+		return
+	}
+
+	subtitleSearch := len(missingSubs) > 0 && s.bazarr != nil
+	steps, actions := prof.remediationPlan(subtitleSearch)
+	if len(steps) == 0 {
+		log.Warn().Msgf("No remediation action applies to episode file %s, keeping it", info.EpisodeFileID)
 		return
 	}
+	if prof.PreflightSearch && replacesFile(steps) && !s.remediator.Preflight(ctx, info.EpisodeID, info.EpisodeFileID, prof, info.OriginalLanguage, s.searchReleases(info.EpisodeID)) {
+		return
+	}
+	log.Info().Strs("steps", steps).Msgf("Remediating episode file %s", info.EpisodeFileID)
+
+	rec := PendingRemediation{
+		MediaID:  info.EpisodeID,
+		FileID:   info.EpisodeFileID,
+		SeriesID: info.SeriesID,
+		FilePath: info.FilePath,
+		Reason:   reason,
+		Steps:    steps,
+		Actions:  actions,
+	}
+	if prof.SearchMode == SearchModeGrab {
+		rec.Release = newReleaseCriteria(prof, info.OriginalLanguage)
+	}
+	if subtitleSearch {
+		rec.Subtitles = newSubtitleCriteria(prof, missingSubs, info.SeriesID, info.FilePath)
+	}
+	if err := s.remediator.start(ctx, rec); err != nil {
+		log.Error().Err(err).Msg("failed to remediate episode")
+	}
 }
 
-// SearchEpisodes searches for episodes based on series ID and season number.
-func (s *SonarrInst) SearchEpisodes(epID int) error {
-	resp, err := s.client.R().
-		SetBody(map[string]interface{}{
-			"name":       "EpisodeSearch",
-			"episodeIds": []int{epID},
-		}).
-		Post("/api/v3/command")
+// airDate returns when the episode aired if the profile depends on it, the zero time if it is unknown
+func (s *SonarrInst) airDate(ctx context.Context, info *SonarMediaInfo, prof *Profile) time.Time {
+	if prof.EnforceAfter <= 0 || !info.AirDate.IsZero() {
+		return info.AirDate
+	}
+
+	ep, err := s.api.GetEpisode(ctx, info.EpisodeID)
 	if err != nil {
-		return fmt.Errorf("error performing request: %w", err)
+		log.Warn().Err(err).Msgf("Unable to load the air date of episode %d, enforcing the profile", info.EpisodeID)
+		return time.Time{}
 	}
+	info.AirDate = ep.AirDateUtc
+	return info.AirDate
+}
 
-	if resp.IsError() {
-		return fmt.Errorf("GET request failed with status code %d: %s", resp.StatusCode(), resp.String())
+// recheck runs a scheduled check again unless the episode has another file by now
+func (s *SonarrInst) recheck(ctx context.Context, chk ScheduledCheck) error {
+	var info SonarMediaInfo
+	if err := json.Unmarshal(chk.Info, &info); err != nil {
+		log.Error().Err(err).Msgf("Dropping unreadable check of file %s", chk.FileID)
+		return nil
 	}
 
+	ep, err := s.api.GetEpisode(ctx, info.EpisodeID)
+	if arr.IsNotFound(err) {
+		log.Info().Msgf("Episode %d no longer exists, dropping its check", info.EpisodeID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load episode %d: %w", info.EpisodeID, err)
+	}
+	if !ep.HasFile || strconv.Itoa(ep.EpisodeFileID) != info.EpisodeFileID {
+		log.Info().Msgf("Episode file %s was replaced, dropping its check", info.EpisodeFileID)
+		return nil
+	}
+
+	info.recheck = true
+	info.AirDate = ep.AirDateUtc
+	info.Tracks, info.Sidecars = nil, nil
+	return s.check(ctx, &info)
+}
+
+func (s *SonarrInst) runStep(ctx context.Context, rec *PendingRemediation, step RemediationStep) error {
+	switch step {
+	case StepDelete:
+		err := s.deleteEpisode(ctx, rec.FileID)
+		if arr.IsNotFound(err) {
+			log.Debug().Msgf("episode file %s is already deleted", rec.FileID)
+			return nil
+		}
+		return err
+	case StepMonitor:
+		return s.monitorEpisode(ctx, []int{rec.MediaID})
+	case StepSearch:
+		return s.SearchEpisodes(ctx, rec.MediaID)
+	case StepGrab:
+		return grabBestRelease(ctx, rec, s.searchReleases(rec.MediaID), s.grabRelease, func(ctx context.Context) error {
+			return s.SearchEpisodes(ctx, rec.MediaID)
+		})
+	case StepSubtitleSearch:
+		if s.bazarr == nil {
+			return searchSubtitles(ctx, rec, nil)
+		}
+		return searchSubtitles(ctx, rec, func(ctx context.Context, opts bazarr.SearchOptions) error {
+			return s.bazarr.SearchEpisode(ctx, rec.Subtitles.SeriesID, rec.MediaID, opts)
+		})
+	case StepSubtitleRecheck:
+		var sidecars []tracks.Track
+		if s.sidecarSubs && rec.Subtitles != nil {
+			sidecars = readSidecars(s.paths.ToLocal(rec.Subtitles.FilePath))
+		}
+		if s.bazarr == nil {
+			return recheckSubtitles(ctx, rec, nil, sidecars)
+		}
+		return recheckSubtitles(ctx, rec, func(ctx context.Context) ([]bazarr.Subtitle, error) {
+			ep, err := s.bazarr.Episode(ctx, rec.MediaID)
+			if err != nil {
+				return nil, err
+			}
+			return ep.Subtitles, nil
+		}, sidecars)
+	case StepBlocklist:
+		return s.blocklistEpisode(ctx, rec.MediaID)
+	case StepQuarantine:
+		_, err := s.quarantine.Add(rec, s.paths.ToLocal(rec.FilePath))
+		return err
+	case StepRescan:
+		seriesID, err := s.seriesID(ctx, rec.SeriesID, rec.MediaID)
+		if err != nil {
+			return err
+		}
+		rec.SeriesID = seriesID
+		return s.rescanSeries(ctx, seriesID)
+	case StepTag:
+		return s.tagSeries(ctx, rec)
+	case StepUnmonitor:
+		err := s.api.MonitorEpisodes(ctx, []int{rec.MediaID}, false)
+		if err != nil {
+			return fmt.Errorf("failed to unmonitor episode %d: %w", rec.MediaID, err)
+		}
+		return nil
+	case StepNotify:
+		return notifyRemediation(ctx, rec)
+	case StepExec:
+		return execRemediation(ctx, rec, s.paths.ToLocal(rec.FilePath))
+	default:
+		return fmt.Errorf("unknown remediation step %s", step)
+	}
+}
+
+// SearchEpisodes triggers an indexer search for the episode
+func (s *SonarrInst) SearchEpisodes(ctx context.Context, epID int) error {
+	_, err := s.api.EpisodeSearch(ctx, epID)
+	if err != nil {
+		return fmt.Errorf("failed to search episode %d: %w", epID, err)
+	}
 	return nil
 }
 
-func (s *SonarrInst) matchProfile(info *SonarMediaInfo) *Profile {
-	for _, tag := range info.Tags {
-		prof, ok := s.getProfile(tag)
-		if ok {
-			return prof
+// searchReleases returns a function running an interactive search for the episode
+func (s *SonarrInst) searchReleases(epID int) func(context.Context) ([]releaseCandidate, error) {
+	return func(ctx context.Context) ([]releaseCandidate, error) {
+		releases, err := s.api.GetReleases(ctx, epID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search releases for episode %d: %w", epID, err)
 		}
+		return sonarrCandidates(releases), nil
 	}
-	// if no tag was matched use the media path
-	prof, ok := s.getProfile(info.MediaPath)
-	if ok {
-		return prof
+}
+
+func (s *SonarrInst) grabRelease(ctx context.Context, release releaseCandidate) error {
+	err := s.api.GrabRelease(ctx, arr.GrabRequest{GUID: release.GUID, IndexerID: release.IndexerID})
+	if err != nil {
+		return fmt.Errorf("failed to grab release %s: %w", release.Title, err)
 	}
 	return nil
 }
 
-func (s *SonarrInst) deleteEpisode(episodeID string) error {
-	res, err := s.client.R().Delete("/api/v3/episodefile/" + episodeID)
+// blocklistEpisode marks the latest grab of the episode as failed, files that were never grabbed are skipped
+func (s *SonarrInst) blocklistEpisode(ctx context.Context, epID int) error {
+	history, err := s.api.GetHistory(ctx, sonarr.HistoryOptions{
+		PageOptions: arr.PageOptions{PageSize: 50, SortKey: "date", SortDirection: "descending"},
+		EpisodeID:   epID,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to load history of episode %d: %w", epID, err)
 	}
-	if res.IsError() {
-		return fmt.Errorf("failed to delete episode "+episodeID+"\nReason: %s", res.String())
+
+	id, ok := latestGrab(history.Records, func(rec sonarr.HistoryRecord) (int, string, time.Time) {
+		return rec.ID, rec.EventType, rec.Date
+	})
+	if !ok {
+		log.Warn().Msgf("Episode %d has no grab in its history, nothing to blocklist", epID)
+		return nil
+	}
+	if err = s.api.MarkHistoryFailed(ctx, id); err != nil {
+		return fmt.Errorf("failed to blocklist grab %d of episode %d: %w", id, epID, err)
 	}
+	return nil
+}
 
+// tagSeries adds the tag of the remediation to the series of the episode
+func (s *SonarrInst) tagSeries(ctx context.Context, rec *PendingRemediation) error {
+	seriesID, err := s.seriesID(ctx, rec.SeriesID, rec.MediaID)
+	if err != nil {
+		return err
+	}
+	rec.SeriesID = seriesID
+	label := remediationTag(rec)
+	tagID, err := ensureTag(ctx, label, s.api.GetTags, s.api.CreateTag)
+	if err != nil {
+		return err
+	}
+	if err = s.api.AddTags(ctx, []int{seriesID}, []int{tagID}); err != nil {
+		return fmt.Errorf("failed to tag series %d with %s: %w", seriesID, label, err)
+	}
 	return nil
 }
 
-func (s *SonarrInst) monitorEpisode(episodeIds []int) error {
-	bodyMap := map[string]interface{}{
-		"episodeIds": episodeIds,
-		"monitored":  true,
+// rescanSeries rescans the series and waits for sonarr to finish
+func (s *SonarrInst) rescanSeries(ctx context.Context, seriesID int) error {
+	cmd, err := s.api.RescanSeries(ctx, seriesID)
+	if err != nil {
+		return fmt.Errorf("failed to rescan series %d: %w", seriesID, err)
 	}
-	res, err := s.client.R().
-		SetBody(bodyMap).
-		Put("/api/v3/episode/monitor")
+	if err = waitForCommand(ctx, s.commandDelay, cmd, s.api.GetCommand); err != nil {
+		return fmt.Errorf("rescan of series %d did not finish: %w", seriesID, err)
+	}
+	return nil
+}
+
+// rescanQuarantined makes sonarr import a restored file
+func (s *SonarrInst) rescanQuarantined(ctx context.Context, item QuarantinedFile) error {
+	seriesID, err := s.seriesID(ctx, item.SeriesID, item.MediaID)
 	if err != nil {
 		return err
 	}
-	if res.IsError() {
-		return fmt.Errorf("failed to re-monitor episode %s", res.String())
+	return s.rescanSeries(ctx, seriesID)
+}
+
+// seriesID returns the series of the episode, it is looked up when the webhook did not include it
+func (s *SonarrInst) seriesID(ctx context.Context, seriesID, epID int) (int, error) {
+	if seriesID != 0 {
+		return seriesID, nil
+	}
+	ep, err := s.api.GetEpisode(ctx, epID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load episode %d: %w", epID, err)
 	}
+	return ep.SeriesID, nil
+}
 
+func (s *SonarrInst) fetchTags(ctx context.Context) ([]arr.Tag, error) {
+	return s.api.GetTags(ctx)
+}
+
+func (s *SonarrInst) deleteEpisode(ctx context.Context, episodeFileID string) error {
+	id, err := strconv.Atoi(episodeFileID)
+	if err != nil {
+		return fmt.Errorf("invalid episode file id %s: %w", episodeFileID, err)
+	}
+
+	err = s.api.DeleteEpisodeFile(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete episode file %s: %w", episodeFileID, err)
+	}
+	return nil
+}
+
+func (s *SonarrInst) monitorEpisode(ctx context.Context, episodeIds []int) error {
+	err := s.api.MonitorEpisodes(ctx, episodeIds, true)
+	if err != nil {
+		return fmt.Errorf("failed to re-monitor episodes %v: %w", episodeIds, err)
+	}
 	return nil
 }
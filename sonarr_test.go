@@ -1,14 +1,24 @@
 package main
 
 import (
-	"github.com/stretchr/testify/assert"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSonarr_ParseWebhook(t *testing.T) {
 	testPayload := `{
   "series": {
     "path": "/media/anime/I'm Getting Married to a Girl I Hate in My Class",
+    "tvdbId": 433563,
+    "imdbId": "tt31337513",
     "tags": [],
     "originalLanguage": {
       "id": 8,
@@ -63,17 +73,19 @@ func TestSonarr_ParseWebhook(t *testing.T) {
 	assert.Equal(t, webhook.MediaPath, "/media/anime")
 	assert.Equal(t, webhook.EpisodeFileID, "11729")
 	assert.Equal(t, webhook.Tags, []string{})
+	assert.Equal(t, webhook.TvdbID, 433563)
+	assert.Equal(t, webhook.ImdbID, "tt31337513")
 }
 
 func TestDelete_Remonitor(t *testing.T) {
 	cli := NewSonarrWithEmptyCallback("https://sonar.dumbapps.org", "0d79c87bb0fc4cdd9039d2266519cde3")
-	err := cli.deleteEpisode("11593")
+	err := cli.deleteEpisode(context.Background(), "11593")
 	if err != nil {
 		t.Fatalf("deleteEpisode failed: %v", err)
 		return
 	}
 
-	err = cli.monitorEpisode([]int{13947})
+	err = cli.monitorEpisode(context.Background(), []int{13947})
 	if err != nil {
 		t.Fatalf("Remonitor failed: %v", err)
 		return
@@ -82,7 +94,7 @@ func TestDelete_Remonitor(t *testing.T) {
 
 func TestSonarrInst_SearchEpisodes(t *testing.T) {
 	cli := NewSonarrWithEmptyCallback("https://sonar.dumbapps.org", "0d79c87bb0fc4cdd9039d2266519cde3")
-	err := cli.SearchEpisodes(13523)
+	err := cli.SearchEpisodes(context.Background(), 13523)
 	if err != nil {
 		t.Fatalf("Remonitor failed: %v", err)
 		return
@@ -95,3 +107,61 @@ func TestSonarrInst_SearchEpisodes(t *testing.T) {
 //		DisallowedLanguages: []string{},
 //	}, true
 //}
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// payloads captured from sonarr v3 and v4, the parsed media info is compared against <name>.golden.json
+func TestSonarr_ParseWebhookGolden(t *testing.T) {
+	payloads, err := filepath.Glob("testdata/sonarr_*.json")
+	require.NoError(t, err)
+
+	cli := NewSonarrWithEmptyCallback("http://localhost:8080", "sdsd")
+	for _, payloadPath := range payloads {
+		if strings.HasSuffix(payloadPath, ".golden.json") {
+			continue
+		}
+
+		t.Run(filepath.Base(payloadPath), func(t *testing.T) {
+			payload, err := os.ReadFile(payloadPath)
+			require.NoError(t, err)
+
+			info, err := cli.ParseJson(payload)
+			require.NoError(t, err)
+
+			got, err := json.MarshalIndent(info, "", "  ")
+			require.NoError(t, err)
+			got = append(got, '\n')
+
+			goldenPath := strings.TrimSuffix(payloadPath, ".json") + ".golden.json"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(goldenPath, got, 0644))
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func TestSonarr_ParseWebhookOriginalLanguage(t *testing.T) {
+	cli := NewSonarrWithEmptyCallback("http://localhost:8080", "sdsd")
+
+	var payload map[string]any
+	data, err := os.ReadFile("testdata/sonarr_v4_download.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &payload))
+	delete(payload["series"].(map[string]any), "originalLanguage")
+	data, err = json.Marshal(payload)
+	require.NoError(t, err)
+
+	_, err = cli.ParseJson(data)
+	assert.ErrorContains(t, err, "missing original language")
+
+	// v3 never sends it
+	data, err = os.ReadFile("testdata/sonarr_v3_download.json")
+	require.NoError(t, err)
+	info, err := cli.ParseJson(data)
+	require.NoError(t, err)
+	assert.Empty(t, info.OriginalLanguage)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultStateDir = "./config/state"
+
+// State holds everything warden needs to remember across restarts
+type State struct {
+	Pending *jsonStore[PendingRemediation]
+	// Skipped are files that failed their profile but were kept because no compliant release was found
+	Skipped *jsonStore[SkippedRemediation]
+	// Grabs are downloads whose files are inspected in the download client
+	Grabs *jsonStore[TrackedGrab]
+	// Checks are delayed checks of files that failed their profile during its grace period
+	Checks *jsonStore[ScheduledCheck]
+}
+
+func NewState(dir string) *State {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create state directory")
+	}
+
+	return &State{
+		Pending: openJsonStore[PendingRemediation](filepath.Join(dir, "pending_remediations.json")),
+		Skipped: openJsonStore[SkippedRemediation](filepath.Join(dir, "skipped_remediations.json")),
+		Grabs:   openJsonStore[TrackedGrab](filepath.Join(dir, "tracked_grabs.json")),
+		Checks:  openJsonStore[ScheduledCheck](filepath.Join(dir, "scheduled_checks.json")),
+	}
+}
+
+// newMemoryState returns a State that is never written to disk
+func newMemoryState() *State {
+	return &State{
+		Pending: &jsonStore[PendingRemediation]{items: map[string]PendingRemediation{}},
+		Skipped: &jsonStore[SkippedRemediation]{items: map[string]SkippedRemediation{}},
+		Grabs:   &jsonStore[TrackedGrab]{items: map[string]TrackedGrab{}},
+		Checks:  &jsonStore[ScheduledCheck]{items: map[string]ScheduledCheck{}},
+	}
+}
+
+// jsonStore is a map persisted to a json file, every write rewrites the file,
+// a store without a path only lives in memory
+type jsonStore[V any] struct {
+	path  string
+	mu    sync.Mutex
+	items map[string]V
+}
+
+func openJsonStore[V any](path string) *jsonStore[V] {
+	store := &jsonStore[V]{path: path, items: map[string]V{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to read %s, starting empty", path)
+		return store
+	}
+	if err = json.Unmarshal(data, &store.items); err != nil {
+		log.Error().Err(err).Msgf("Unable to parse %s, starting empty", path)
+		store.items = map[string]V{}
+	}
+
+	return store
+}
+
+func (s *jsonStore[V]) Get(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.items[key]
+	return val, ok
+}
+
+func (s *jsonStore[V]) Put(key string, val V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = val
+	s.save()
+}
+
+func (s *jsonStore[V]) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[key]; !ok {
+		return
+	}
+	delete(s.items, key)
+	s.save()
+}
+
+// Keys returns the sorted keys of all items
+func (s *jsonStore[V]) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// save writes the items to a temp file first so a crash never leaves a truncated file behind,
+// must be called with mu held
+func (s *jsonStore[V]) save() {
+	if s.path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to encode %s", s.path)
+		return
+	}
+
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Error().Err(err).Msgf("Unable to write %s", tmp)
+		return
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		log.Error().Err(err).Msgf("Unable to replace %s", s.path)
+	}
+}
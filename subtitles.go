@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RA341/warden/api/bazarr"
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/tracks"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultSubtitleWait is how long bazarr gets to download the subtitles before the file is rechecked
+const defaultSubtitleWait = 15 * time.Minute
+
+// BazarrConfig is the bazarr instance managing the subtitles of an *arr instance
+type BazarrConfig struct {
+	URL    string `json:"url"`
+	ApiKey string `json:"api_key"`
+}
+
+func newBazarrClient(conf *BazarrConfig) *bazarr.Client {
+	if conf == nil || conf.URL == "" {
+		return nil
+	}
+	return bazarr.New(conf.URL, conf.ApiKey)
+}
+
+// SubtitleCriteria is what a subtitle search is rechecked against,
+// it is stored with the remediation so a resumed recheck does not need the profile
+type SubtitleCriteria struct {
+	// Missing are the required subtitle languages the file did not have
+	Missing []string `json:"missing"`
+	// SeriesID is the sonarr series id, bazarr needs it along with the episode id
+	SeriesID int `json:"series_id,omitempty"`
+	// FilePath is the path of the file as reported by the *arr app, used to find sidecar subtitles
+	FilePath        string        `json:"file_path,omitempty"`
+	RequireFullSubs bool          `json:"require_full_subs,omitempty"`
+	IgnoreSdhSubs   bool          `json:"ignore_sdh_subs,omitempty"`
+	Wait            time.Duration `json:"wait"`
+}
+
+func newSubtitleCriteria(prof *Profile, missing []string, seriesID int, filePath string) *SubtitleCriteria {
+	wait := prof.SubtitleWait
+	if wait <= 0 {
+		wait = defaultSubtitleWait
+	}
+	return &SubtitleCriteria{
+		Missing:         missing,
+		SeriesID:        seriesID,
+		FilePath:        filePath,
+		RequireFullSubs: prof.RequireFullSubs,
+		IgnoreSdhSubs:   prof.IgnoreSdhSubs,
+		Wait:            wait,
+	}
+}
+
+// missingSubtitles returns the required subtitle languages missing from a file whose audio satisfies the profile,
+// false when the audio is missing too or nothing is missing
+func (p *Profile) missingSubtitles(audios, subs []string) ([]string, bool) {
+	if !isSubset(lang.NormalizeAll(audios), lang.NormalizeAll(p.RequiredLanguagesAudio)) {
+		return nil, false
+	}
+	have := lang.NormalizeAll(subs)
+	var missing []string
+	for _, required := range lang.NormalizeAll(p.RequiredLanguagesSubs) {
+		if !slices.Contains(have, required) {
+			missing = append(missing, required)
+		}
+	}
+	return missing, len(missing) > 0
+}
+
+// searchSubtitles asks bazarr for every missing language and schedules the recheck
+func searchSubtitles(ctx context.Context, rec *PendingRemediation, search func(context.Context, bazarr.SearchOptions) error) error {
+	crit := rec.Subtitles
+	if crit == nil {
+		return errors.New("remediation has no subtitle criteria")
+	}
+
+	if search == nil {
+		log.Warn().Msgf("Bazarr is not configured, not searching subtitles for file %s", rec.FileID)
+	} else {
+		for _, code := range crit.Missing {
+			opts := bazarr.SearchOptions{Language: bazarrLanguage(code)}
+			if err := search(ctx, opts); err != nil {
+				return fmt.Errorf("bazarr search for %s subtitles failed: %w", code, err)
+			}
+		}
+		log.Info().Strs("languages", crit.Missing).Msgf("Searched subtitles for file %s, rechecking in %s", rec.FileID, crit.Wait)
+	}
+
+	rec.NotBefore = time.Now().Add(crit.Wait)
+	return nil
+}
+
+// recheckSubtitles resolves the remediation once the missing subtitles are known to bazarr or found next to the file,
+// otherwise the remaining steps replace the file
+func recheckSubtitles(ctx context.Context, rec *PendingRemediation, known func(context.Context) ([]bazarr.Subtitle, error), sidecars []tracks.Track) error {
+	crit := rec.Subtitles
+	if crit == nil {
+		return errors.New("remediation has no subtitle criteria")
+	}
+	if time.Now().Before(rec.NotBefore) {
+		return errStepWaiting
+	}
+
+	available := sidecars
+	if known != nil {
+		subs, err := known(ctx)
+		if err != nil && !errors.Is(err, bazarr.ErrNotFound) {
+			return fmt.Errorf("unable to load subtitles from bazarr: %w", err)
+		}
+		for _, sub := range subs {
+			available = append(available, tracks.Track{
+				Kind:            tracks.Subtitle,
+				Language:        sub.Code3,
+				Forced:          sub.Forced,
+				HearingImpaired: sub.HI,
+			})
+		}
+	}
+
+	rules := &Profile{RequireFullSubs: crit.RequireFullSubs, IgnoreSdhSubs: crit.IgnoreSdhSubs}
+	var found []string
+	for _, track := range available {
+		if rules.countsSubtitle(track) {
+			found = append(found, track.Language)
+		}
+	}
+	if isSubset(lang.NormalizeAll(found), crit.Missing) {
+		log.Info().Strs("languages", crit.Missing).Msgf("Subtitles found for file %s, keeping it", rec.FileID)
+		return errRemediationResolved
+	}
+
+	log.Info().Strs("missing", crit.Missing).Strs("found", found).Msgf("Subtitles are still missing for file %s, replacing it", rec.FileID)
+	return nil
+}
+
+// bazarrLanguage returns the ISO 639-1 code bazarr expects
+func bazarrLanguage(code string) string {
+	if l, ok := lang.Lookup(code); ok && l.Alpha2 != "" {
+		return l.Alpha2
+	}
+	return code
+}
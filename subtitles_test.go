@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/api/arr/arrtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSubtitleSonarr returns a sonarr instance whose file has japanese audio and no subtitles,
+// bazarr reports english subtitles once found is set
+func newSubtitleSonarr(t *testing.T, found *atomic.Bool) (*SonarrInst, *arrtest.Server, *arrtest.Server) {
+	server := arrtest.NewServer(t)
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{AudioLanguages: "jpn", AudioStreamCount: 1},
+	})
+	server.JSON("GET /api/v3/system/status", http.StatusOK, arr.SystemStatus{AppName: "Sonarr"})
+	server.JSON("DELETE /api/v3/episodefile/{id}", http.StatusOK, nil)
+	server.JSON("PUT /api/v3/episode/monitor", http.StatusAccepted, nil)
+	server.JSON("POST /api/v3/command", http.StatusCreated, arr.Command{ID: 1})
+
+	bazarrServer := arrtest.NewServer(t)
+	bazarrServer.JSON("PATCH /api/episodes/subtitles", http.StatusNoContent, nil)
+	bazarrServer.Handle("GET /api/episodes", func(w http.ResponseWriter, r *http.Request) {
+		var subs []map[string]any
+		if found.Load() {
+			subs = append(subs, map[string]any{"name": "English", "code2": "en", "code3": "eng", "forced": false, "hi": false})
+		}
+		arrtest.WriteJSON(w, http.StatusOK, map[string]any{"data": []any{
+			map[string]any{"sonarrSeriesId": 118, "sonarrEpisodeId": 13947, "subtitles": subs},
+		}})
+	})
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   arrtest.APIKey,
+		Bazarr:   &BazarrConfig{URL: bazarrServer.URL, ApiKey: arrtest.APIKey},
+		LanguageMap: map[string]*Profile{
+			"/media/anime": {
+				RequiredLanguagesAudio: []string{"jpn"},
+				RequiredLanguagesSubs:  []string{"eng"},
+				SubtitleSearch:         true,
+				SubtitleWait:           time.Hour,
+			},
+		},
+	})
+	cli.api.SetRetryPolicy(arr.NoRetry)
+	cli.mediaInfoDelay = time.Millisecond
+	return cli, server, bazarrServer
+}
+
+func runSubtitleCheck(t *testing.T, cli *SonarrInst) {
+	cli.RunCheck(context.Background(), &SonarMediaInfo{
+		SeriesID:      118,
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+	})
+}
+
+// expireWait moves the recheck of the pending remediation into the past
+func expireWait(t *testing.T, cli *SonarrInst) {
+	rec, ok := cli.remediator.store.Get(cli.remediator.instance + ":11729")
+	require.True(t, ok)
+	rec.NotBefore = time.Now().Add(-time.Second)
+	cli.remediator.store.Put(rec.Key(), rec)
+}
+
+func TestSonarr_SubtitleSearchFindsSubtitles(t *testing.T) {
+	var found atomic.Bool
+	cli, server, bazarrServer := newSubtitleSonarr(t, &found)
+
+	runSubtitleCheck(t, cli)
+
+	searches := bazarrServer.RequestsTo(http.MethodPatch, "/api/episodes/subtitles")
+	require.Len(t, searches, 1)
+	query, err := url.ParseQuery(searches[0].Query)
+	require.NoError(t, err)
+	assert.Equal(t, "118", query.Get("seriesid"))
+	assert.Equal(t, "13947", query.Get("episodeid"))
+	assert.Equal(t, "en", query.Get("language"))
+
+	rec, ok := cli.remediator.store.Get(cli.remediator.instance + ":11729")
+	require.True(t, ok, "remediation waits for bazarr")
+	assert.Equal(t, []RemediationStep{StepSubtitleRecheck, StepDelete, StepMonitor, StepSearch}, rec.Steps)
+
+	// the wait has not passed yet
+	cli.remediator.resume(context.Background())
+	assert.Empty(t, bazarrServer.RequestsTo(http.MethodGet, "/api/episodes"))
+
+	found.Store(true)
+	expireWait(t, cli)
+	cli.remediator.resume(context.Background())
+
+	_, ok = cli.remediator.store.Get(cli.remediator.instance + ":11729")
+	assert.False(t, ok)
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+}
+
+func TestSonarr_SubtitleSearchFallsBack(t *testing.T) {
+	var found atomic.Bool
+	cli, server, _ := newSubtitleSonarr(t, &found)
+
+	runSubtitleCheck(t, cli)
+	expireWait(t, cli)
+	cli.remediator.resume(context.Background())
+
+	_, ok := cli.remediator.store.Get(cli.remediator.instance + ":11729")
+	assert.False(t, ok)
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), 1)
+	assert.Len(t, server.RequestsTo(http.MethodPost, "/api/v3/command"), 1)
+}
+
+func TestProfile_MissingSubtitles(t *testing.T) {
+	prof := &Profile{RequiredLanguagesAudio: []string{"jpn"}, RequiredLanguagesSubs: []string{"eng", "spa"}}
+
+	missing, ok := prof.missingSubtitles([]string{"jpn"}, []string{"English"})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"spa"}, missing)
+
+	_, ok = prof.missingSubtitles([]string{"eng"}, nil)
+	assert.False(t, ok, "audio is missing too")
+
+	_, ok = prof.missingSubtitles([]string{"jpn"}, []string{"eng", "spa"})
+	assert.False(t, ok)
+}
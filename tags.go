@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/rs/zerolog/log"
+)
+
+const tagRefreshInterval = 15 * time.Minute
+
+// webhookTags decodes the tags of a webhook, depending on the version
+// they are sent either as labels or as tag ids
+type webhookTags struct {
+	Labels []string
+	IDs    []int
+}
+
+func (w *webhookTags) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+
+	w.Labels = make([]string, 0, len(raw))
+	for _, item := range raw {
+		var label string
+		if err := json.Unmarshal(item, &label); err == nil {
+			w.Labels = append(w.Labels, label)
+			continue
+		}
+
+		var id int
+		if err := json.Unmarshal(item, &id); err == nil {
+			w.IDs = append(w.IDs, id)
+			continue
+		}
+
+		var tag arr.Tag
+		if err := json.Unmarshal(item, &tag); err != nil {
+			return fmt.Errorf("invalid tag %s: %w", item, err)
+		}
+		w.Labels = append(w.Labels, tag.Label)
+	}
+
+	return nil
+}
+
+// tagCache maps tag ids of an instance to their lowercase labels
+type tagCache struct {
+	mu     sync.RWMutex
+	byID   map[int]string
+	loaded bool
+}
+
+// update replaces the cached tags, returns true if the set of labels changed
+func (c *tagCache) update(tags []arr.Tag) bool {
+	byID := make(map[int]string, len(tags))
+	for _, tag := range tags {
+		byID[tag.ID] = strings.ToLower(tag.Label)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := !c.loaded || !maps.Equal(c.byID, byID)
+	c.byID = byID
+	c.loaded = true
+	return changed
+}
+
+// labels resolves tag ids, unknown ids are skipped
+func (c *tagCache) labels(ids []int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var res []string
+	for _, id := range ids {
+		label, ok := c.byID[id]
+		if !ok {
+			log.Warn().Msgf("Unknown tag id %d, tags may be out of date", id)
+			continue
+		}
+		res = append(res, label)
+	}
+	return res
+}
+
+// unknown returns the keys that do not exist as tag labels
+func (c *tagCache) unknown(keys []string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	known := slices.Collect(maps.Values(c.byID))
+	var res []string
+	for _, key := range keys {
+		if !slices.Contains(known, strings.ToLower(key)) {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// watch refreshes the cache every tagRefreshInterval until ctx is cancelled,
+// tagKeys are validated whenever the tags on the instance change
+func (c *tagCache) watch(ctx context.Context, fetch func(context.Context) ([]arr.Tag, error), tagKeys []string) {
+	ticker := time.NewTicker(tagRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		c.refresh(ctx, fetch, tagKeys)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *tagCache) refresh(ctx context.Context, fetch func(context.Context) ([]arr.Tag, error), tagKeys []string) {
+	tags, err := fetch(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to refresh tags")
+		return
+	}
+
+	if !c.update(tags) {
+		return
+	}
+	log.Debug().Int("count", len(tags)).Msg("Loaded tags")
+
+	for _, key := range c.unknown(tagKeys) {
+		log.Warn().Msgf("language map key %q looks like a tag but no such tag exists on the instance", key)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookTags_Unmarshal(t *testing.T) {
+	var tags webhookTags
+	require.NoError(t, json.Unmarshal([]byte(`["Anime", 3, {"id": 4, "label": "kids"}]`), &tags))
+
+	assert.Equal(t, []string{"Anime", "kids"}, tags.Labels)
+	assert.Equal(t, []int{3}, tags.IDs)
+}
+
+func TestSonarr_ResolveTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/tag", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"id": 1, "label": "Anime"}, {"id": 2, "label": "kids"}]`))
+	}))
+	defer server.Close()
+
+	cli := NewSonarr(&ArrInstance{
+		BasePath: server.URL,
+		ApiKey:   "key",
+		LanguageMap: map[string]*Profile{
+			"anime": {RequiredLanguagesAudio: []string{"jpn"}},
+		},
+	})
+	cli.tags.refresh(context.Background(), cli.fetchTags, []string{"anime", "anmie"})
+
+	assert.Equal(t, []string{"anime", "kids"}, cli.tags.labels([]int{1, 2, 9}))
+	assert.Equal(t, []string{"anmie"}, cli.tags.unknown([]string{"anime", "anmie", "KIDS"}))
+	assert.False(t, cli.tags.update([]arr.Tag{{ID: 1, Label: "anime"}, {ID: 2, Label: "Kids"}}))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/tracks"
+	"github.com/rs/zerolog/log"
+)
+
+// tracksRules reports whether the profile has rules that need the file tracks
+func (p *Profile) tracksRules() bool {
+	return p.RequireFullSubs || p.IgnoreSdhSubs || p.ExcludeCommentary
+}
+
+// availableLanguages returns the audio and subtitle languages that count toward the profile, sidecar subtitles included.
+// Without file tracks the media info languages are used as is, it cannot tell forced, SDH or commentary tracks apart
+func (p *Profile) availableLanguages(all, sidecars []tracks.Track, audios, subs []string) ([]string, []string) {
+	if len(all) == 0 {
+		if p.tracksRules() {
+			log.Debug().Msg("Forced, SDH and commentary rules need inspect_files, counting every track")
+		}
+	} else {
+		audios, subs = nil, nil
+		for _, track := range all {
+			switch track.Kind {
+			case tracks.Audio:
+				if p.ExcludeCommentary && (track.IsCommentary() || track.IsDescriptive()) {
+					continue
+				}
+				audios = append(audios, track.Language)
+			case tracks.Subtitle:
+				if p.countsSubtitle(track) {
+					subs = append(subs, track.Language)
+				}
+			}
+		}
+	}
+
+	for _, sidecar := range sidecars {
+		if p.countsSubtitle(sidecar) {
+			subs = append(subs, sidecar.Language)
+		}
+	}
+	return lang.NormalizeAll(audios), lang.NormalizeAll(subs)
+}
+
+// countsSubtitle applies the forced and SDH rules to a subtitle track
+func (p *Profile) countsSubtitle(track tracks.Track) bool {
+	if p.RequireFullSubs && track.IsForced() {
+		return false
+	}
+	return !p.IgnoreSdhSubs || !track.IsSDH()
+}
+
+// noDefaultTrack in DefaultSubs allows files without a default subtitle
+const noDefaultTrack = "none"
+
+// defaultTrack returns the track players select for the kind, the first one flagged as default.
+// Players fall back to the first audio track when none is flagged while subtitles stay off
+func defaultTrack(all []tracks.Track, kind tracks.Kind) (tracks.Track, bool) {
+	candidates := tracks.Filter(all, kind)
+	for _, track := range candidates {
+		if track.Default {
+			return track, true
+		}
+	}
+	if kind == tracks.Audio && len(candidates) > 0 {
+		return candidates[0], true
+	}
+	return tracks.Track{}, false
+}
+
+// defaultTrackViolation returns why the default tracks of the file do not match the profile
+func (p *Profile) defaultTrackViolation(all []tracks.Track) (string, bool) {
+	if len(p.DefaultAudio) > 0 {
+		track, ok := defaultTrack(all, tracks.Audio)
+		if ok && !slices.Contains(lang.NormalizeAll(p.DefaultAudio), lang.Normalize(track.Language)) {
+			return fmt.Sprintf("default audio track %d is %s, expected one of %v", track.Number, track.Language, p.DefaultAudio), true
+		}
+	}
+
+	if len(p.DefaultSubs) > 0 {
+		allowed := lang.NormalizeAll(p.DefaultSubs)
+		track, ok := defaultTrack(all, tracks.Subtitle)
+		if !ok && !slices.Contains(allowed, noDefaultTrack) {
+			return fmt.Sprintf("file has no default subtitle, expected one of %v", p.DefaultSubs), true
+		}
+		if ok && !slices.Contains(allowed, lang.Normalize(track.Language)) {
+			return fmt.Sprintf("default subtitle track %d is %s, expected one of %v", track.Number, track.Language, p.DefaultSubs), true
+		}
+	}
+	return "", false
+}
+
+const defaultTracksMismatch = "default tracks do not match the profile"
+
+// rejectsDefaultTracks checks the default tracks of the file, returns true when the file should be replaced.
+// In report mode a mismatch is only logged
+func (p *Profile) rejectsDefaultTracks(all []tracks.Track, mediaPath string) bool {
+	if len(p.DefaultAudio) == 0 && len(p.DefaultSubs) == 0 {
+		return false
+	}
+	if len(all) == 0 {
+		log.Debug().Msg("Default track rules need inspect_files, skipping them")
+		return false
+	}
+
+	reason, violated := p.defaultTrackViolation(all)
+	if !violated {
+		return false
+	}
+	if p.DefaultTrackAction != DefaultTrackReject {
+		log.Warn().Str("path", mediaPath).Msgf("Keeping file, %s", reason)
+		return false
+	}
+	log.Info().Str("path", mediaPath).Msgf("Replacing file, %s", reason)
+	return true
+}
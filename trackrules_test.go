@@ -0,0 +1,200 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RA341/warden/api/arr"
+	"github.com/RA341/warden/tracks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile_AvailableLanguages(t *testing.T) {
+	all := []tracks.Track{
+		{Kind: tracks.Video, Language: "und"},
+		{Kind: tracks.Audio, Language: "jpn", Default: true},
+		{Kind: tracks.Audio, Language: "eng", Name: "Commentary"},
+		{Kind: tracks.Audio, Language: "fre", VisualImpaired: true},
+		{Kind: tracks.Subtitle, Language: "eng", Name: "Signs & Songs", Forced: true},
+		{Kind: tracks.Subtitle, Language: "spa", Name: "Spanish (SDH)"},
+		{Kind: tracks.Subtitle, Language: "ger"},
+	}
+
+	tests := []struct {
+		name   string
+		prof   Profile
+		audios []string
+		subs   []string
+	}{
+		{"no rules", Profile{}, []string{"jpn", "eng", "fre"}, []string{"eng", "spa", "ger"}},
+		{"full subs", Profile{RequireFullSubs: true}, []string{"jpn", "eng", "fre"}, []string{"spa", "ger"}},
+		{"ignore sdh", Profile{IgnoreSdhSubs: true}, []string{"jpn", "eng", "fre"}, []string{"eng", "ger"}},
+		{"exclude commentary", Profile{ExcludeCommentary: true}, []string{"jpn"}, []string{"eng", "spa", "ger"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audios, subs := tt.prof.availableLanguages(all, nil, nil, nil)
+			assert.Equal(t, tt.audios, audios)
+			assert.Equal(t, tt.subs, subs)
+		})
+	}
+}
+
+func TestProfile_AvailableLanguagesWithoutTracks(t *testing.T) {
+	prof := &Profile{RequireFullSubs: true, ExcludeCommentary: true}
+	audios, subs := prof.availableLanguages(nil, nil, []string{"jpn", "eng"}, []string{"eng"})
+	assert.Equal(t, []string{"jpn", "eng"}, audios)
+	assert.Equal(t, []string{"eng"}, subs)
+}
+
+func TestSonarr_ForcedSubsDoNotCount(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesSubs: []string{"eng"}, RequireFullSubs: true})
+	cli.inspectFiles = true
+
+	// the only english subtitle of testdata/tracks.mkv is forced
+	info := &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		FilePath:      "testdata/tracks.mkv",
+	}
+	cli.RunCheck(t.Context(), info)
+
+	assert.NotEmpty(t, server.RequestsTo("DELETE", "/api/v3/episodefile/11729"))
+}
+
+func TestProfile_DefaultTrackViolation(t *testing.T) {
+	flagged := []tracks.Track{
+		{Kind: tracks.Audio, Number: 1, Language: "rus"},
+		{Kind: tracks.Audio, Number: 2, Language: "eng", Default: true},
+		{Kind: tracks.Subtitle, Number: 3, Language: "en", Default: true},
+	}
+	unflagged := []tracks.Track{
+		{Kind: tracks.Audio, Number: 1, Language: "rus"},
+		{Kind: tracks.Audio, Number: 2, Language: "eng"},
+		{Kind: tracks.Subtitle, Number: 3, Language: "eng"},
+	}
+
+	tests := []struct {
+		name   string
+		prof   Profile
+		tracks []tracks.Track
+		want   bool
+	}{
+		{"no rules", Profile{}, unflagged, false},
+		{"flagged default audio", Profile{DefaultAudio: []string{"eng"}}, flagged, false},
+		{"first audio track without flags", Profile{DefaultAudio: []string{"eng"}}, unflagged, true},
+		{"default audio by name", Profile{DefaultAudio: []string{"English", "Japanese"}}, flagged, false},
+		{"default subtitle", Profile{DefaultSubs: []string{"eng"}}, flagged, false},
+		{"missing default subtitle", Profile{DefaultSubs: []string{"eng"}}, unflagged, true},
+		{"none allowed", Profile{DefaultSubs: []string{"eng", "none"}}, unflagged, false},
+		{"only none allowed", Profile{DefaultSubs: []string{"none"}}, flagged, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, violated := tt.prof.defaultTrackViolation(tt.tracks)
+			assert.Equal(t, tt.want, violated)
+		})
+	}
+}
+
+func TestSonarr_DefaultTrackAction(t *testing.T) {
+	// the default audio track of testdata/tracks.mkv is japanese
+	for _, action := range []DefaultTrackAction{DefaultTrackReport, DefaultTrackReject} {
+		t.Run(action, func(t *testing.T) {
+			cli, server := newGrabCheckSonarr(t, &Profile{
+				RequiredLanguagesAudio: []string{"eng"},
+				DefaultAudio:           []string{"eng"},
+				DefaultTrackAction:     action,
+			})
+			cli.inspectFiles = true
+
+			cli.RunCheck(t.Context(), &SonarMediaInfo{
+				EpisodeID:     13947,
+				EpisodeFileID: "11729",
+				SeriesPath:    "/media/anime/Show",
+				FilePath:      "testdata/tracks.mkv",
+			})
+
+			deletes := server.RequestsTo("DELETE", "/api/v3/episodefile/11729")
+			if action == DefaultTrackReject {
+				assert.NotEmpty(t, deletes)
+			} else {
+				assert.Empty(t, deletes)
+			}
+		})
+	}
+}
+
+func TestSonarr_DefaultTrackReplacementsRunOut(t *testing.T) {
+	cli, server := newGrabCheckSonarr(t, &Profile{
+		RequiredLanguagesAudio: []string{"eng"},
+		DefaultAudio:           []string{"eng"},
+		DefaultTrackAction:     DefaultTrackReject,
+	})
+	cli.inspectFiles = true
+	check := func() {
+		cli.RunCheck(t.Context(), &SonarMediaInfo{
+			EpisodeID:     13947,
+			EpisodeFileID: "11729",
+			SeriesPath:    "/media/anime/Show",
+			FilePath:      "testdata/tracks.mkv",
+		})
+	}
+
+	// every replacement of the episode has a japanese default audio track
+	for range maxDefaultTrackReplacements + 1 {
+		check()
+	}
+
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), maxDefaultTrackReplacements)
+	skipped, ok := cli.remediator.skipped.Get(cli.remediator.instance + ":11729")
+	require.True(t, ok)
+	assert.Contains(t, skipped.Reason, defaultTracksMismatch)
+
+	// a file with matching default tracks starts the count over
+	cli.remediator.defaultTracksMatched(13947)
+	check()
+	assert.Len(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"), maxDefaultTrackReplacements+1)
+}
+
+func TestProfile_AvailableLanguagesWithSidecars(t *testing.T) {
+	sidecars := []tracks.Track{
+		{Kind: tracks.Subtitle, Language: "eng", Forced: true},
+		{Kind: tracks.Subtitle, Language: "spa", HearingImpaired: true},
+		{Kind: tracks.Subtitle, Language: "ger"},
+	}
+
+	_, subs := (&Profile{}).availableLanguages(nil, sidecars, []string{"jpn"}, []string{"fre"})
+	assert.Equal(t, []string{"fre", "eng", "spa", "ger"}, subs)
+
+	_, subs = (&Profile{RequireFullSubs: true, IgnoreSdhSubs: true}).availableLanguages(nil, sidecars, []string{"jpn"}, nil)
+	assert.Equal(t, []string{"ger"}, subs)
+}
+
+func TestSonarr_CountsSidecarSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Show - S01E01.mkv", "Show - S01E01.en.srt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	cli, server := newGrabCheckSonarr(t, &Profile{RequiredLanguagesSubs: []string{"eng"}})
+	server.JSON("GET /api/v3/episodefile/{id}", http.StatusOK, map[string]any{
+		"id":        11729,
+		"mediaInfo": arr.MediaInfo{AudioLanguages: "jpn", AudioStreamCount: 1},
+	})
+	cli.sidecarSubs = true
+	cli.paths = PathMappings{{Remote: "/media/anime/Show/Season 01", Local: dir}}
+
+	cli.RunCheck(t.Context(), &SonarMediaInfo{
+		EpisodeID:     13947,
+		EpisodeFileID: "11729",
+		SeriesPath:    "/media/anime/Show",
+		FilePath:      "/media/anime/Show/Season 01/Show - S01E01.mkv",
+	})
+
+	assert.Empty(t, server.RequestsTo(http.MethodDelete, "/api/v3/episodefile/11729"))
+}
@@ -0,0 +1,50 @@
+package tracks
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// title words that mark a track when the container flag is not set, most muxers only set the title
+var (
+	forcedWords      = [][]string{{"forced"}, {"signs"}, {"foreign"}}
+	sdhWords         = [][]string{{"sdh"}, {"cc"}, {"hoh"}, {"hearing", "impaired"}, {"deaf"}}
+	commentaryWords  = [][]string{{"commentary"}, {"commentaire"}, {"kommentar"}, {"commento"}, {"comentario"}}
+	descriptiveWords = [][]string{{"audio", "description"}, {"descriptive"}, {"described"}, {"audiodescription"}, {"ad"}}
+)
+
+// IsForced reports whether the subtitle only covers signs, songs or foreign dialogue
+func (t Track) IsForced() bool {
+	return t.Forced || t.nameHas(forcedWords)
+}
+
+// IsSDH reports whether the subtitle is meant for the deaf and hard of hearing
+func (t Track) IsSDH() bool {
+	return t.HearingImpaired || t.nameHas(sdhWords)
+}
+
+// IsCommentary reports whether the audio is a commentary track
+func (t Track) IsCommentary() bool {
+	return t.Commentary || t.nameHas(commentaryWords)
+}
+
+// IsDescriptive reports whether the audio describes the picture for the visually impaired
+func (t Track) IsDescriptive() bool {
+	return t.VisualImpaired || t.nameHas(descriptiveWords)
+}
+
+// nameHas reports whether any phrase appears as consecutive words of the track name
+func (t Track) nameHas(phrases [][]string) bool {
+	words := strings.FieldsFunc(strings.ToLower(t.Name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, phrase := range phrases {
+		for i := range words {
+			if i+len(phrase) <= len(words) && slices.Equal(words[i:i+len(phrase)], phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package tracks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrack_Classify(t *testing.T) {
+	tests := []struct {
+		track       Track
+		forced      bool
+		sdh         bool
+		commentary  bool
+		descriptive bool
+	}{
+		{track: Track{Name: "English"}},
+		{track: Track{Forced: true}, forced: true},
+		{track: Track{Name: "Signs & Songs"}, forced: true},
+		{track: Track{Name: "English [Forced]"}, forced: true},
+		{track: Track{Name: "English (SDH)"}, sdh: true},
+		{track: Track{Name: "English CC"}, sdh: true},
+		{track: Track{HearingImpaired: true}, sdh: true},
+		{track: Track{Name: "Director's Commentary"}, commentary: true},
+		{track: Track{Commentary: true}, commentary: true},
+		{track: Track{Name: "English - Audio Description"}, descriptive: true},
+		{track: Track{Name: "English AD"}, descriptive: true},
+		{track: Track{VisualImpaired: true}, descriptive: true},
+		// words must match whole, not as part of another word
+		{track: Track{Name: "Headcase Accent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.track.Name, func(t *testing.T) {
+			assert.Equal(t, tt.forced, tt.track.IsForced(), "forced")
+			assert.Equal(t, tt.sdh, tt.track.IsSDH(), "sdh")
+			assert.Equal(t, tt.commentary, tt.track.IsCommentary(), "commentary")
+			assert.Equal(t, tt.descriptive, tt.track.IsDescriptive(), "descriptive")
+		})
+	}
+}
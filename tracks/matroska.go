@@ -0,0 +1,350 @@
+package tracks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+// matroska element ids, see https://www.matroska.org/technical/elements.html
+const (
+	idEBML      = 0x1A45DFA3
+	idSegment   = 0x18538067
+	idSeekHead  = 0x114D9B74
+	idSeek      = 0x4DBB
+	idSeekID    = 0x53AB
+	idSeekPos   = 0x53AC
+	idTracks    = 0x1654AE6B
+	idCluster   = 0x1F43B675
+	idTrackEnt  = 0xAE
+	idTrackNum  = 0xD7
+	idTrackType = 0x83
+	idCodecID   = 0x86
+	idName      = 0x536E
+	idLanguage  = 0x22B59C
+	idLangBCP47 = 0x22B59D
+	idDefault   = 0x88
+	idForced    = 0x55AA
+	idHearing   = 0x55AB
+	idVisual    = 0x55AC
+	idComment   = 0x55AF
+)
+
+const (
+	mkvTypeVideo    = 1
+	mkvTypeAudio    = 2
+	mkvTypeSubtitle = 0x11
+)
+
+// maxTracksSize caps the Tracks element read into memory, real files stay well below a few KB
+const maxTracksSize = 16 << 20
+
+var errNoTracks = errors.New("no tracks element found")
+
+// readMatroska walks the top level elements of the segment until it reaches the Tracks element,
+// falling back to the SeekHead when the tracks are stored after the clusters
+func readMatroska(r io.ReadSeeker) ([]Track, error) {
+	id, size, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if id != idEBML {
+		return nil, ErrUnsupported
+	}
+	if _, err = r.Seek(int64(size), io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	if id, _, err = readHeader(r); err != nil {
+		return nil, err
+	}
+	if id != idSegment {
+		return nil, fmt.Errorf("expected a segment, got element %x", id)
+	}
+	segmentStart, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	tracksPos := int64(-1)
+	for {
+		id, size, err = readHeader(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch id {
+		case idTracks:
+			return readTracksElement(r, size)
+		case idSeekHead:
+			data, err := readData(r, size)
+			if err != nil {
+				return nil, err
+			}
+			if pos, ok := seekPosition(data, idTracks); ok {
+				tracksPos = segmentStart + int64(pos)
+			}
+		case idCluster:
+			// clusters hold the media data, the tracks can only be found through the seek head now
+			if tracksPos < 0 {
+				return nil, errNoTracks
+			}
+			if _, err = r.Seek(tracksPos, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if id, size, err = readHeader(r); err != nil {
+				return nil, err
+			}
+			if id != idTracks {
+				return nil, fmt.Errorf("seek head points to element %x instead of the tracks", id)
+			}
+			return readTracksElement(r, size)
+		default:
+			if size == unknownSize {
+				return nil, fmt.Errorf("element %x has an unknown size", id)
+			}
+			if _, err = r.Seek(int64(size), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, errNoTracks
+}
+
+func readTracksElement(r io.Reader, size uint64) ([]Track, error) {
+	data, err := readData(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Track
+	err = walkElements(data, func(id uint32, entry []byte) error {
+		if id != idTrackEnt {
+			return nil
+		}
+		track, ok, err := parseTrackEntry(entry)
+		if ok {
+			res = append(res, track)
+		}
+		return err
+	})
+	return res, err
+}
+
+// parseTrackEntry reads a TrackEntry, returns false for track types other than video, audio and subtitles
+func parseTrackEntry(data []byte) (Track, bool, error) {
+	// defaults from the matroska spec
+	track := Track{Language: "eng", Default: true}
+	var trackType uint64
+	var bcp47 string
+
+	err := walkElements(data, func(id uint32, val []byte) error {
+		switch id {
+		case idTrackNum:
+			track.Number = int(readUint(val))
+		case idTrackType:
+			trackType = readUint(val)
+		case idCodecID:
+			track.Codec = readString(val)
+		case idName:
+			track.Name = readString(val)
+		case idLanguage:
+			track.Language = readString(val)
+		case idLangBCP47:
+			bcp47 = readString(val)
+		case idDefault:
+			track.Default = readUint(val) == 1
+		case idForced:
+			track.Forced = readUint(val) == 1
+		case idHearing:
+			track.HearingImpaired = readUint(val) == 1
+		case idVisual:
+			track.VisualImpaired = readUint(val) == 1
+		case idComment:
+			track.Commentary = readUint(val) == 1
+		}
+		return nil
+	})
+	if err != nil {
+		return track, false, err
+	}
+
+	// LanguageBCP47 takes precedence over Language when both are present
+	if bcp47 != "" {
+		track.Language = primaryLanguage(bcp47)
+	}
+
+	switch trackType {
+	case mkvTypeVideo:
+		track.Kind = Video
+	case mkvTypeAudio:
+		track.Kind = Audio
+	case mkvTypeSubtitle:
+		track.Kind = Subtitle
+	default:
+		return track, false, nil
+	}
+	return track, true, nil
+}
+
+// seekPosition finds the position of the element with the id in a SeekHead, relative to the segment data
+func seekPosition(data []byte, target uint32) (uint64, bool) {
+	var pos uint64
+	var found bool
+	_ = walkElements(data, func(id uint32, seek []byte) error {
+		if id != idSeek || found {
+			return nil
+		}
+		var seekID uint32
+		var seekPos uint64
+		_ = walkElements(seek, func(id uint32, val []byte) error {
+			switch id {
+			case idSeekID:
+				seekID = uint32(readUint(val))
+			case idSeekPos:
+				seekPos = readUint(val)
+			}
+			return nil
+		})
+		if seekID == target {
+			pos, found = seekPos, true
+		}
+		return nil
+	})
+	return pos, found
+}
+
+// unknownSize marks elements whose size is not known when they are written, e.g. live streamed segments
+const unknownSize = ^uint64(0)
+
+// readHeader reads the id and data size of the next element
+func readHeader(r io.Reader) (uint32, uint64, error) {
+	id, _, err := readVint(r, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, _, err := readVint(r, false)
+	if err != nil {
+		return 0, 0, noEOF(err)
+	}
+	return uint32(id), size, nil
+}
+
+// readVint reads a variable length integer, ids keep their length marker while sizes drop it
+func readVint(r io.Reader, keepMarker bool) (uint64, int, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:1]); err != nil {
+		return 0, 0, err
+	}
+	length := vintLength(buf[0])
+	if length == 0 {
+		return 0, 0, fmt.Errorf("invalid variable length integer %x", buf[0])
+	}
+	if _, err := io.ReadFull(r, buf[1:length]); err != nil {
+		return 0, 0, noEOF(err)
+	}
+	val, ok := decodeVint(buf[:length], keepMarker)
+	if !ok {
+		return unknownSize, length, nil
+	}
+	return val, length, nil
+}
+
+// walkElements calls fn with the id and data of every element in data
+func walkElements(data []byte, fn func(id uint32, val []byte) error) error {
+	for len(data) > 0 {
+		idLen := vintLength(data[0])
+		if idLen == 0 || idLen > len(data) {
+			return errors.New("truncated element id")
+		}
+		id, _ := decodeVint(data[:idLen], true)
+		data = data[idLen:]
+
+		if len(data) == 0 {
+			return errors.New("truncated element size")
+		}
+		sizeLen := vintLength(data[0])
+		if sizeLen == 0 || sizeLen > len(data) {
+			return errors.New("truncated element size")
+		}
+		size, ok := decodeVint(data[:sizeLen], false)
+		data = data[sizeLen:]
+		if !ok || size > uint64(len(data)) {
+			return fmt.Errorf("element %x is larger than its parent", id)
+		}
+
+		if err := fn(uint32(id), data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// vintLength returns the length of a variable length integer from its first byte, 0 if it is invalid
+func vintLength(first byte) int {
+	for i := 0; i < 8; i++ {
+		if first&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// decodeVint returns false when every value bit is set, which marks an unknown size
+func decodeVint(buf []byte, keepMarker bool) (uint64, bool) {
+	val := uint64(buf[0])
+	if !keepMarker {
+		val &= 0xFF >> len(buf)
+	}
+	allOnes := val == uint64(0xFF>>len(buf))
+	for _, b := range buf[1:] {
+		val = val<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return val, keepMarker || !allOnes
+}
+
+func readData(r io.Reader, size uint64) ([]byte, error) {
+	if size > maxTracksSize {
+		return nil, fmt.Errorf("element of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, noEOF(err)
+	}
+	return data, nil
+}
+
+func readUint(val []byte) uint64 {
+	if len(val) > 8 {
+		return 0
+	}
+	var buf [8]byte
+	copy(buf[8-len(val):], val)
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+// readString drops the null padding matroska allows after strings
+func readString(val []byte) string {
+	for i, b := range val {
+		if b == 0 {
+			return string(val[:i])
+		}
+	}
+	return string(val)
+}
+
+// noEOF turns an EOF in the middle of an element into io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
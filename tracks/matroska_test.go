@@ -0,0 +1,155 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// element encodes a matroska element with an 8 byte size
+func element(id uint32, children ...[]byte) []byte {
+	data := bytes.Join(children, nil)
+
+	var idBuf [4]byte
+	binary.BigEndian.PutUint32(idBuf[:], id)
+	head := bytes.TrimLeft(idBuf[:], "\x00")
+
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+	size[0] = 0x01
+	return append(append(head, size[:]...), data...)
+}
+
+func uintElement(id uint32, val uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], val)
+	return element(id, bytes.TrimLeft(buf[:], "\x00"))
+}
+
+func stringElement(id uint32, val string) []byte {
+	return element(id, []byte(val))
+}
+
+func testMatroska(seekHead bool) []byte {
+	tracks := element(idTracks,
+		element(idTrackEnt,
+			uintElement(idTrackNum, 1),
+			uintElement(idTrackType, mkvTypeVideo),
+			stringElement(idCodecID, "V_MPEG4/ISO/AVC"),
+			stringElement(idLanguage, "und"),
+		),
+		element(idTrackEnt,
+			uintElement(idTrackNum, 2),
+			uintElement(idTrackType, mkvTypeAudio),
+			stringElement(idCodecID, "A_AAC"),
+			stringElement(idLanguage, "jpn"),
+		),
+		element(idTrackEnt,
+			uintElement(idTrackNum, 3),
+			uintElement(idTrackType, mkvTypeAudio),
+			stringElement(idCodecID, "A_AC3"),
+			stringElement(idLangBCP47, "en-US"),
+			stringElement(idName, "Director's Commentary\x00"),
+			uintElement(idDefault, 0),
+			uintElement(idComment, 1),
+		),
+		element(idTrackEnt,
+			uintElement(idTrackNum, 4),
+			uintElement(idTrackType, mkvTypeSubtitle),
+			stringElement(idCodecID, "S_TEXT/ASS"),
+			stringElement(idName, "Signs & Songs"),
+			uintElement(idDefault, 0),
+			uintElement(idForced, 1),
+		),
+		element(idTrackEnt,
+			uintElement(idTrackNum, 5),
+			uintElement(idTrackType, mkvTypeSubtitle),
+			stringElement(idCodecID, "S_TEXT/UTF8"),
+			stringElement(idLanguage, "spa"),
+			uintElement(idDefault, 0),
+			uintElement(idHearing, 1),
+		),
+	)
+	info := element(0x1549A966, uintElement(0x2AD7B1, 1000000))
+	cluster := element(idCluster, uintElement(0xE7, 0), element(0xA3, make([]byte, 64)))
+
+	var segment [][]byte
+	if seekHead {
+		// the seek head comes first, the tracks are written after the cluster
+		var seekHead []byte
+		for {
+			pos := len(seekHead) + len(info) + len(cluster)
+			next := element(idSeekHead, element(idSeek,
+				element(idSeekID, []byte{0x16, 0x54, 0xAE, 0x6B}),
+				uintElement(idSeekPos, uint64(pos)),
+			))
+			done := len(next) == len(seekHead)
+			seekHead = next
+			if done {
+				break
+			}
+		}
+		segment = [][]byte{seekHead, info, cluster, tracks}
+	} else {
+		segment = [][]byte{info, tracks, cluster}
+	}
+
+	header := element(idEBML, stringElement(0x4282, "matroska"))
+	return append(header, element(idSegment, segment...)...)
+}
+
+var wantMatroska = []Track{
+	{Kind: Video, Number: 1, Language: "und", Codec: "V_MPEG4/ISO/AVC", Default: true},
+	{Kind: Audio, Number: 2, Language: "jpn", Codec: "A_AAC", Default: true},
+	{Kind: Audio, Number: 3, Language: "en", Name: "Director's Commentary", Codec: "A_AC3", Commentary: true},
+	{Kind: Subtitle, Number: 4, Language: "eng", Name: "Signs & Songs", Codec: "S_TEXT/ASS", Forced: true},
+	{Kind: Subtitle, Number: 5, Language: "spa", Codec: "S_TEXT/UTF8", HearingImpaired: true},
+}
+
+func TestReadMatroska(t *testing.T) {
+	res, err := ReadFrom(bytes.NewReader(testMatroska(false)))
+	require.NoError(t, err)
+	assert.Equal(t, wantMatroska, res)
+
+	assert.Equal(t, []string{"jpn", "en"}, Languages(res, Audio))
+	assert.Equal(t, []string{"eng", "spa"}, Languages(res, Subtitle))
+}
+
+func TestReadMatroska_TracksAfterClusters(t *testing.T) {
+	res, err := ReadFrom(bytes.NewReader(testMatroska(true)))
+	require.NoError(t, err)
+	assert.Equal(t, wantMatroska, res)
+}
+
+func TestReadMatroska_Truncated(t *testing.T) {
+	file := testMatroska(false)
+	_, err := ReadFrom(bytes.NewReader(file[:len(file)/2]))
+	assert.Error(t, err)
+}
+
+func TestReadFrom_Unsupported(t *testing.T) {
+	_, err := ReadFrom(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI LIST")))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = ReadFrom(bytes.NewReader(nil))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestDecodeVint(t *testing.T) {
+	val, ok := decodeVint([]byte{0x81}, false)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), val)
+
+	val, ok = decodeVint([]byte{0x40, 0x02}, false)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), val)
+
+	_, ok = decodeVint([]byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, false)
+	assert.False(t, ok)
+
+	val, _ = decodeVint([]byte{0x1A, 0x45, 0xDF, 0xA3}, true)
+	assert.Equal(t, uint64(idEBML), val)
+}
@@ -0,0 +1,247 @@
+package tracks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxMoovSize caps the moov box read into memory, its sample tables grow with the length of the file
+const maxMoovSize = 128 << 20
+
+var errNoMoov = errors.New("no moov box found")
+
+// isMP4Box reports whether the file starts with a box that only ISO base media files begin with
+func isMP4Box(boxType string) bool {
+	switch boxType {
+	case "ftyp", "moov", "free", "skip", "wide", "mdat":
+		return true
+	}
+	return false
+}
+
+// readMP4 finds the moov box and lists the tracks in it.
+// MP4 has no default or forced flags per track, the enabled flag of the track header is used as the default flag
+func readMP4(r io.ReadSeeker) ([]Track, error) {
+	for {
+		boxType, size, err := readBoxHeader(r)
+		if errors.Is(err, io.EOF) {
+			return nil, errNoMoov
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if boxType == "moov" {
+			if size > maxMoovSize {
+				return nil, fmt.Errorf("moov box of %d bytes is too large", size)
+			}
+			data := make([]byte, size)
+			if _, err = io.ReadFull(r, data); err != nil {
+				return nil, noEOF(err)
+			}
+			return parseMoov(data)
+		}
+
+		if size == math.MaxInt64 {
+			return nil, errNoMoov
+		}
+		if _, err = r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readBoxHeader returns the type and data size of the next box, boxes extending to the end of the file get math.MaxInt64
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	var head [8]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(head[:4]))
+	boxType := string(head[4:])
+
+	switch size {
+	case 0:
+		return boxType, math.MaxInt64, nil
+	case 1:
+		var large [8]byte
+		if _, err := io.ReadFull(r, large[:]); err != nil {
+			return "", 0, noEOF(err)
+		}
+		size = int64(binary.BigEndian.Uint64(large[:])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, fmt.Errorf("invalid size of box %q", boxType)
+	}
+	return boxType, size, nil
+}
+
+// walkBoxes calls fn with the type and data of every box in data
+func walkBoxes(data []byte, fn func(boxType string, val []byte)) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return errors.New("truncated box header")
+		}
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		boxType := string(data[4:8])
+		headerLen := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return errors.New("truncated box header")
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return fmt.Errorf("box %q is larger than its parent", boxType)
+		}
+
+		fn(boxType, data[headerLen:size])
+		data = data[size:]
+	}
+	return nil
+}
+
+func parseMoov(data []byte) ([]Track, error) {
+	var res []Track
+	var errs []error
+	err := walkBoxes(data, func(boxType string, trak []byte) {
+		if boxType != "trak" {
+			return
+		}
+		track, ok, err := parseTrak(trak)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if ok {
+			res = append(res, track)
+		}
+	})
+	return res, errors.Join(append(errs, err)...)
+}
+
+// parseTrak reads a track box, returns false for handlers other than video, sound and subtitles
+func parseTrak(data []byte) (Track, bool, error) {
+	track := Track{Language: "und"}
+	var handler, elng string
+
+	err := walkBoxes(data, func(boxType string, val []byte) {
+		switch boxType {
+		case "tkhd":
+			track.Number, track.Default = parseTkhd(val)
+		case "udta":
+			_ = walkBoxes(val, func(boxType string, val []byte) {
+				if boxType == "name" {
+					track.Name = readString(val)
+				}
+			})
+		case "mdia":
+			_ = walkBoxes(val, func(boxType string, val []byte) {
+				switch boxType {
+				case "mdhd":
+					track.Language = parseMdhdLanguage(val)
+				case "elng":
+					if len(val) > 4 {
+						elng = readString(val[4:])
+					}
+				case "hdlr":
+					if len(val) >= 12 {
+						handler = string(val[8:12])
+					}
+				case "minf":
+					track.Codec = sampleEntry(val)
+				}
+			})
+		}
+	})
+	if err != nil {
+		return track, false, err
+	}
+
+	// the extended language box holds a BCP 47 tag and overrides the packed ISO 639-2 code
+	if elng != "" {
+		track.Language = primaryLanguage(elng)
+	}
+
+	switch handler {
+	case "vide":
+		track.Kind = Video
+	case "soun":
+		track.Kind = Audio
+	case "sbtl", "subt", "text", "clcp":
+		track.Kind = Subtitle
+	default:
+		return track, false, nil
+	}
+	return track, true, nil
+}
+
+// parseTkhd returns the track id and whether the track is enabled
+func parseTkhd(val []byte) (int, bool) {
+	if len(val) < 4 {
+		return 0, false
+	}
+	enabled := val[3]&0x1 != 0
+
+	// version 1 uses 64 bit creation and modification times
+	offset := 12
+	if val[0] == 1 {
+		offset = 20
+	}
+	if len(val) < offset+4 {
+		return 0, enabled
+	}
+	return int(binary.BigEndian.Uint32(val[offset : offset+4])), enabled
+}
+
+// parseMdhdLanguage decodes the packed ISO 639-2/T code of a media header
+func parseMdhdLanguage(val []byte) string {
+	if len(val) < 4 {
+		return "und"
+	}
+	offset := 20
+	if val[0] == 1 {
+		offset = 32
+	}
+	if len(val) < offset+2 {
+		return "und"
+	}
+
+	packed := binary.BigEndian.Uint16(val[offset : offset+2])
+	// values below 0x400 are Macintosh language codes used by old QuickTime files
+	if packed < 0x400 || packed&0x7FFF == 0x7FFF {
+		return "und"
+	}
+	return string([]byte{
+		byte(packed>>10&0x1F) + 0x60,
+		byte(packed>>5&0x1F) + 0x60,
+		byte(packed&0x1F) + 0x60,
+	})
+}
+
+// sampleEntry returns the format of the first sample description, e.g. "mp4a", "avc1" or "tx3g"
+func sampleEntry(minf []byte) string {
+	var codec string
+	_ = walkBoxes(minf, func(boxType string, stbl []byte) {
+		if boxType != "stbl" {
+			return
+		}
+		_ = walkBoxes(stbl, func(boxType string, stsd []byte) {
+			// version and flags, entry count, then the first entry size and format
+			if boxType == "stsd" && len(stsd) >= 16 {
+				codec = string(stsd[12:16])
+			}
+		})
+	})
+	return codec
+}
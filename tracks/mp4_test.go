@@ -0,0 +1,96 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func box(boxType string, children ...[]byte) []byte {
+	data := bytes.Join(children, nil)
+	head := make([]byte, 8)
+	binary.BigEndian.PutUint32(head, uint32(len(data)+8))
+	copy(head[4:], boxType)
+	return append(head, data...)
+}
+
+func tkhd(id uint32, enabled bool) []byte {
+	val := make([]byte, 84)
+	if enabled {
+		val[3] = 0x1
+	}
+	binary.BigEndian.PutUint32(val[12:], id)
+	return box("tkhd", val)
+}
+
+func mdhd(language string) []byte {
+	val := make([]byte, 24)
+	var packed uint16
+	for _, c := range []byte(language) {
+		packed = packed<<5 | uint16(c-0x60)
+	}
+	binary.BigEndian.PutUint16(val[20:], packed)
+	return box("mdhd", val)
+}
+
+func hdlr(handler string) []byte {
+	val := make([]byte, 24)
+	copy(val[8:], handler)
+	return box("hdlr", append(val, "Handler\x00"...))
+}
+
+func stsd(format string) []byte {
+	val := make([]byte, 16)
+	val[7] = 1
+	copy(val[12:], format)
+	return box("minf", box("stbl", box("stsd", val)))
+}
+
+func trak(id uint32, enabled bool, handler, format, language string, extra ...[]byte) []byte {
+	children := [][]byte{tkhd(id, enabled), box("mdia", append([][]byte{mdhd(language), hdlr(handler), stsd(format)}, extra...)...)}
+	return box("trak", children...)
+}
+
+func TestReadMP4(t *testing.T) {
+	moov := box("moov",
+		box("mvhd", make([]byte, 100)),
+		trak(1, true, "vide", "avc1", "und"),
+		trak(2, true, "soun", "mp4a", "jpn"),
+		trak(3, false, "soun", "ac-3", "eng", box("elng", append(make([]byte, 4), "pt-BR\x00"...))),
+		box("trak", tkhd(4, false), box("udta", box("name", []byte("English SDH"))),
+			box("mdia", mdhd("eng"), hdlr("sbtl"), stsd("tx3g"))),
+		trak(5, true, "hint", "rtp ", "und"),
+	)
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41")),
+		box("mdat", make([]byte, 256)),
+		moov,
+	}, nil)
+
+	res, err := ReadFrom(bytes.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, []Track{
+		{Kind: Video, Number: 1, Language: "und", Codec: "avc1", Default: true},
+		{Kind: Audio, Number: 2, Language: "jpn", Codec: "mp4a", Default: true},
+		{Kind: Audio, Number: 3, Language: "pt", Codec: "ac-3"},
+		{Kind: Subtitle, Number: 4, Language: "eng", Name: "English SDH", Codec: "tx3g"},
+	}, res)
+}
+
+func TestReadMP4_NoMoov(t *testing.T) {
+	file := append(box("ftyp", []byte("isom")), box("mdat", make([]byte, 16))...)
+	_, err := ReadFrom(bytes.NewReader(file))
+	assert.ErrorIs(t, err, errNoMoov)
+}
+
+func TestParseMdhdLanguage(t *testing.T) {
+	val := make([]byte, 22)
+	binary.BigEndian.PutUint16(val[20:], 0x15C7) // "eng"
+	assert.Equal(t, "eng", parseMdhdLanguage(val))
+
+	binary.BigEndian.PutUint16(val[20:], 0)
+	assert.Equal(t, "und", parseMdhdLanguage(val))
+}
@@ -0,0 +1,74 @@
+package tracks
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/RA341/warden/lang"
+	"github.com/RA341/warden/release"
+)
+
+// SubtitleExtensions are the sidecar subtitle formats media servers pick up next to a video
+var SubtitleExtensions = []string{".srt", ".ass", ".ssa", ".sub", ".idx", ".sup", ".vtt"}
+
+// sidecar flags follow the language, e.g. movie.en.forced.srt or movie.eng.sdh.srt
+var (
+	sidecarForced = []string{"forced", "foreign"}
+	sidecarSDH    = []string{"sdh", "cc", "hi"}
+)
+
+// Sidecars lists the subtitle files next to the video that belong to it, their name starts with the name of the video
+func Sidecars(videoPath string) ([]Track, error) {
+	dir := filepath.Dir(videoPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Track
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if track, ok := Sidecar(videoPath, entry.Name()); ok {
+			res = append(res, track)
+		}
+	}
+	return res, nil
+}
+
+// Sidecar parses the language and flags of a subtitle file named after the video,
+// files of other videos or without a subtitle extension return false
+func Sidecar(videoPath, name string) (Track, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if !slices.Contains(SubtitleExtensions, ext) {
+		return Track{}, false
+	}
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	rest, ok := cutPrefixFold(strings.TrimSuffix(name, filepath.Ext(name)), base)
+	if !ok || (rest != "" && rest[0] != '.') {
+		return Track{}, false
+	}
+
+	// the name is left empty, words of the title must not mark the subtitle as forced or SDH
+	track := Track{Kind: Subtitle, Language: lang.Undetermined, Codec: strings.TrimPrefix(ext, ".")}
+	_, langs, flags := release.SplitSidecar(rest)
+	if len(langs) > 0 {
+		// the first language after the video name
+		track.Language = langs[len(langs)-1]
+	}
+	for _, flag := range flags {
+		track.Forced = track.Forced || slices.Contains(sidecarForced, flag)
+		track.HearingImpaired = track.HearingImpaired || slices.Contains(sidecarSDH, flag)
+	}
+	return track, true
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,68 @@
+package tracks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSidecar(t *testing.T) {
+	video := "/media/movies/Movie (2019)/Movie (2019).mkv"
+
+	tests := []struct {
+		name string
+		want Track
+		ok   bool
+	}{
+		{"Movie (2019).srt", Track{Language: "und", Codec: "srt"}, true},
+		{"Movie (2019).en.srt", Track{Language: "eng", Codec: "srt"}, true},
+		{"Movie (2019).eng.forced.ass", Track{Language: "eng", Codec: "ass", Forced: true}, true},
+		{"Movie (2019).en.forced.ass", Track{Language: "eng", Codec: "ass", Forced: true}, true},
+		{"Movie (2019).English.SDH.srt", Track{Language: "eng", Codec: "srt", HearingImpaired: true}, true},
+		{"Movie (2019).pt-BR.srt", Track{Language: "por", Codec: "srt"}, true},
+		{"Movie (2019).hi.srt", Track{Language: "hin", Codec: "srt"}, true},
+		{"Movie (2019).en.hi.srt", Track{Language: "eng", Codec: "srt", HearingImpaired: true}, true},
+		{"movie (2019).de.default.srt", Track{Language: "ger", Codec: "srt"}, true},
+		{"Movie (2019).1.spa.srt", Track{Language: "spa", Codec: "srt"}, true},
+		{"Movie (2019).nfo", Track{}, false},
+		{"Movie (2019)-trailer.en.srt", Track{}, false},
+		{"Other.en.srt", Track{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Sidecar(video, tt.name)
+			require.Equal(t, tt.ok, ok)
+			if !ok {
+				return
+			}
+			tt.want.Kind = Subtitle
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSidecar_TitleWords(t *testing.T) {
+	got, ok := Sidecar("/media/movies/Signs (2002)/Signs (2002).mkv", "Signs (2002).en.srt")
+	require.True(t, ok)
+	assert.False(t, got.IsForced(), "words of the title are not flags")
+
+	got, ok = Sidecar("/media/tv/Deaf U/Deaf U - S01E01 - Forced.mkv", "Deaf U - S01E01 - Forced.en.sdh.srt")
+	require.True(t, ok)
+	assert.False(t, got.IsForced())
+	assert.True(t, got.IsSDH())
+}
+
+func TestSidecars(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Show - S01E01.mkv", "Show - S01E01.en.srt", "Show - S01E01.ja.ass", "Show - S01E02.en.srt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	res, err := Sidecars(filepath.Join(dir, "Show - S01E01.mkv"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eng", "jpn"}, Languages(res, Subtitle))
+}
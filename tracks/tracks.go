@@ -0,0 +1,104 @@
+// Package tracks lists the audio and subtitle tracks of Matroska/WebM and MP4 files by reading their headers,
+// it keeps the per track detail the *arr media info summary drops such as titles and the default and forced flags
+package tracks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Video    Kind = "video"
+	Audio    Kind = "audio"
+	Subtitle Kind = "subtitle"
+)
+
+// ErrUnsupported is returned for files that are neither Matroska nor MP4
+var ErrUnsupported = errors.New("unsupported container")
+
+// Track is a single stream of a media file
+type Track struct {
+	Kind Kind `json:"kind"`
+	// Number is the track number in the container, starting at 1
+	Number int `json:"number"`
+	// Language is the language tag as stored in the file, "und" when it is not set
+	Language string `json:"language"`
+	// Name is the title of the track, e.g. "Commentary" or "Signs & Songs"
+	Name    string `json:"name,omitempty"`
+	Codec   string `json:"codec,omitempty"`
+	Default bool   `json:"default"`
+	Forced  bool   `json:"forced"`
+	// HearingImpaired, VisualImpaired and Commentary are only flagged by Matroska, other containers leave them to the title
+	HearingImpaired bool `json:"hearing_impaired,omitempty"`
+	// VisualImpaired marks audio description tracks
+	VisualImpaired bool `json:"visual_impaired,omitempty"`
+	Commentary     bool `json:"commentary,omitempty"`
+}
+
+// Read lists the tracks of the file at path
+func Read(path string) ([]Track, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res, err := ReadFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
+
+// ReadFrom detects the container from its first bytes and lists its tracks
+func ReadFrom(r io.ReadSeeker) ([]Track, error) {
+	var head [12]byte
+	n, err := io.ReadFull(r, head[:])
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case n >= 4 && bytes.Equal(head[:4], ebmlMagic):
+		return readMatroska(r)
+	case n >= 8 && isMP4Box(string(head[4:8])):
+		return readMP4(r)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Filter returns the tracks of the kind
+func Filter(all []Track, kind Kind) []Track {
+	var res []Track
+	for _, t := range all {
+		if t.Kind == kind {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Languages returns the language of every track of the kind, in track order
+func Languages(all []Track, kind Kind) []string {
+	var res []string
+	for _, t := range Filter(all, kind) {
+		res = append(res, t.Language)
+	}
+	return res
+}
+
+// primaryLanguage reduces a BCP 47 tag like "en-US" or "pt-BR" to its language subtag
+func primaryLanguage(tag string) string {
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	return tag
+}
@@ -9,10 +9,14 @@ import (
 	"slices"
 )
 
-type GetProfileCallback = func(string) (*Profile, bool)
-
 type ArrClient interface {
 	ProcessWebhook(payload []byte) error
+	// Start launches any background work of the client
+	Start()
+	// Stop cancels the background work started by Start
+	Stop()
+	// Quarantine returns the quarantined files of the instance, nil when it has no quarantine_dir
+	Quarantine() *quarantine
 }
 
 func isSubset[T comparable](a, b []T) bool {